@@ -0,0 +1,30 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+//
+// rdl-lsp is a Language Server Protocol server for RDL schemas. It talks JSON-RPC on stdin and
+// stdout, and provides diagnostics, hover, go to definition, completion, and document symbols.
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: rdl-lsp\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := newServer(os.Stdout).serve(os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "rdl-lsp: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,510 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+var keywords = []string{
+	"name", "namespace", "version", "include", "use", "type", "resource",
+	"authenticate", "authorize", "expected", "exceptions", "async",
+	"optional", "default", "required", "out", "header", "context",
+	"pattern", "values", "minsize", "maxsize", "size", "min", "max", "closed",
+}
+
+var includePattern = regexp.MustCompile(`^\s*(include|use)\s+"([^"]*)"`)
+
+//document is an open file. Its schema is the last one parsed from it without errors, with the
+//spans of its elements, so that hover, definition and the others keep working while the text is
+//being edited.
+type document struct {
+	uri     string
+	path    string
+	lines   []string
+	schema  *rdl.Schema
+	sources *rdl.SourceMap
+
+	//other files with diagnostics from the last parse, i.e. included ones
+	published []string
+}
+
+type server struct {
+	out      io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+type handler func(s *server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                  (*server).initialize,
+	"initialized":                 nil,
+	"shutdown":                    (*server).shutdownRequest,
+	"textDocument/didOpen":        (*server).didOpen,
+	"textDocument/didChange":      (*server).didChange,
+	"textDocument/didClose":       (*server).didClose,
+	"textDocument/hover":          (*server).hover,
+	"textDocument/definition":     (*server).definition,
+	"textDocument/completion":     (*server).completion,
+	"textDocument/documentSymbol": (*server).documentSymbol,
+}
+
+func newServer(out io.Writer) *server {
+	return &server{out: out, docs: make(map[string]*document)}
+}
+
+//serve handles the messages read from in until the client sends "exit", or the input ends. It is an
+//error to exit without a shutdown request first.
+func (s *server) serve(in io.Reader) error {
+	r := bufio.NewReader(in)
+	for {
+		data, err := readMessage(r)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var req request
+		if err = json.Unmarshal(data, &req); err != nil {
+			s.write(&errorResponse{JSONRPC: "2.0", Error: &responseError{Code: errorParse, Message: err.Error()}})
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+		s.handle(&req)
+	}
+}
+
+func (s *server) handle(req *request) {
+	h, ok := handlers[req.Method]
+	if !ok {
+		//unknown notifications, i.e. "$/cancelRequest", are ignored
+		if req.ID != nil {
+			s.write(&errorResponse{JSONRPC: "2.0", ID: req.ID, Error: &responseError{Code: errorMethodNotFound, Message: "method not found: " + req.Method}})
+		}
+		return
+	}
+	var result interface{}
+	var err error
+	if h != nil {
+		result, err = h(s, req.Params)
+	}
+	if req.ID == nil {
+		return
+	}
+	if err != nil {
+		rerr, ok := err.(*responseError)
+		if !ok {
+			rerr = &responseError{Code: errorInvalidParams, Message: err.Error()}
+		}
+		s.write(&errorResponse{JSONRPC: "2.0", ID: req.ID, Error: rerr})
+	} else {
+		s.write(&response{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}
+}
+
+func (s *server) write(msg interface{}) {
+	writeMessage(s.out, msg)
+}
+
+func (s *server) notify(method string, params interface{}) {
+	s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *server) initialize(params json.RawMessage) (interface{}, error) {
+	return &initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:       1,
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			CompletionProvider:     completionOptions{TriggerCharacters: []string{"."}},
+			DocumentSymbolProvider: true,
+		},
+		ServerInfo: serverInfo{Name: "rdl-lsp", Version: rdl.Version},
+	}, nil
+}
+
+func (s *server) shutdownRequest(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p didOpenParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc := &document{uri: p.TextDocument.URI, path: uriPath(p.TextDocument.URI)}
+	s.docs[doc.uri] = doc
+	s.update(doc, p.TextDocument.Text)
+	return nil, nil
+}
+
+func (s *server) didChange(params json.RawMessage) (interface{}, error) {
+	var p didChangeParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(p.ContentChanges); n > 0 {
+		s.update(doc, p.ContentChanges[n-1].Text)
+	}
+	return nil, nil
+}
+
+func (s *server) didClose(params json.RawMessage) (interface{}, error) {
+	var p didCloseParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if doc, ok := s.docs[p.TextDocument.URI]; ok {
+		delete(s.docs, doc.uri)
+		for _, uri := range append(doc.published, doc.uri) {
+			s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: uri, Diagnostics: []diagnostic{}})
+		}
+	}
+	return nil, nil
+}
+
+func (s *server) document(uri string) (*document, error) {
+	if doc, ok := s.docs[uri]; ok {
+		return doc, nil
+	}
+	return nil, &responseError{Code: errorInvalidParams, Message: "document is not open: " + uri}
+}
+
+//update parses the new text of the document, and publishes its diagnostics. Those in the files it
+//includes or uses are published for those files.
+func (s *server) update(doc *document, text string) {
+	doc.lines = strings.Split(text, "\n")
+	diags := make(map[string][]diagnostic)
+	diags[doc.uri] = []diagnostic{}
+	options := &rdl.ParseOptions{
+		DiagnosticHandler: func(d *rdl.Diagnostic) {
+			uri := doc.uri
+			if d.File != "" && d.File != doc.path {
+				uri = pathURI(d.File)
+			}
+			diags[uri] = append(diags[uri], diagnostic{
+				Range:    doc.spanRange(&d.Span),
+				Severity: int(d.Severity),
+				Code:     d.Code,
+				Source:   "rdl",
+				Message:  d.Message,
+			})
+		},
+	}
+	schema, sources, err := rdl.ParseRDLWithSourceMap(doc.path, strings.NewReader(text), options)
+	if err == nil {
+		doc.schema = schema
+		doc.sources = sources
+	}
+	uris := []string{}
+	for uri := range diags {
+		if uri != doc.uri {
+			uris = append(uris, uri)
+		}
+	}
+	for _, uri := range doc.published {
+		if _, ok := diags[uri]; !ok {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+	s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: doc.uri, Diagnostics: diags[doc.uri]})
+	doc.published = nil
+	for _, uri := range uris {
+		d := diags[uri]
+		if d == nil {
+			d = []diagnostic{}
+		} else {
+			doc.published = append(doc.published, uri)
+		}
+		s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: uri, Diagnostics: d})
+	}
+}
+
+func (s *server) positionParams(params json.RawMessage) (*document, position, error) {
+	var p textDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, position{}, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	return doc, p.Position, err
+}
+
+func (s *server) hover(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.positionParams(params)
+	if err != nil || doc.schema == nil {
+		return nil, err
+	}
+	word, rng := doc.wordAt(pos)
+	reg := rdl.NewTypeRegistry(doc.schema)
+	t := reg.FindType(rdl.TypeRef(word))
+	if t == nil {
+		return nil, nil
+	}
+	name, super, comment := rdl.TypeInfo(t)
+	value := fmt.Sprintf("```rdl\ntype %s %s\n```\nBase type: %s", name, super, reg.BaseType(t))
+	if t.Variant == rdl.TypeVariantBaseType {
+		value = fmt.Sprintf("```rdl\n%s\n```\nBase type", name)
+	}
+	if comment != "" {
+		value += "\n\n" + comment
+	}
+	return &hover{Contents: markupContent{Kind: "markdown", Value: value}, Range: &rng}, nil
+}
+
+//definition finds the definition of the type at the position, or the file named by an include or
+//use statement
+func (s *server) definition(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if pos.Line >= 0 && pos.Line < len(doc.lines) {
+		if m := includePattern.FindStringSubmatch(doc.lines[pos.Line]); m != nil && m[2] != "rdl" {
+			return &location{URI: pathURI(filepath.Join(filepath.Dir(doc.path), m[2]))}, nil
+		}
+	}
+	word, _ := doc.wordAt(pos)
+	if doc.schema == nil {
+		return nil, nil
+	}
+	t := rdl.NewTypeRegistry(doc.schema).FindType(rdl.TypeRef(word))
+	if t == nil {
+		return nil, nil
+	}
+	span := doc.sources.Position(t)
+	if span == nil {
+		return nil, nil
+	}
+	path := span.File
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return &location{URI: pathURI(path), Range: doc.spanRange(span)}, nil
+}
+
+//completion offers the keywords, and the names of the types known to the schema
+func (s *server) completion(params json.RawMessage) (interface{}, error) {
+	doc, _, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	schema := doc.schema
+	if schema == nil {
+		schema = rdl.NewSchema()
+	}
+	reg := rdl.NewTypeRegistry(schema)
+	items := []completionItem{}
+	names := make([]string, 0, len(schema.Types))
+	for _, bt := range rdl.BaseTypeAny.SymbolSet() {
+		if bt != "" {
+			names = append(names, bt)
+		}
+	}
+	for _, t := range schema.Types {
+		name, _, _ := rdl.TypeInfo(t)
+		names = append(names, string(name))
+	}
+	for _, name := range names {
+		t := reg.FindType(rdl.TypeRef(name))
+		if t == nil {
+			continue
+		}
+		_, super, comment := rdl.TypeInfo(t)
+		item := completionItem{Label: name, Kind: completionClass, Documentation: comment}
+		if t.Variant != rdl.TypeVariantBaseType {
+			item.Detail = string(super)
+		}
+		switch t.Variant {
+		case rdl.TypeVariantStructTypeDef:
+			item.Kind = completionStruct
+		case rdl.TypeVariantEnumTypeDef:
+			item.Kind = completionEnum
+		}
+		items = append(items, item)
+	}
+	for _, kw := range keywords {
+		items = append(items, completionItem{Label: kw, Kind: completionKeyword})
+	}
+	return items, nil
+}
+
+//documentSymbol lists the types and resources defined in the document itself
+func (s *server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p documentSymbolParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	symbols := []documentSymbol{}
+	if doc.schema == nil {
+		return symbols, nil
+	}
+	schema := doc.schema
+	symbol := func(elem interface{}, name string, detail string, kind int) *documentSymbol {
+		span := doc.sources.Position(elem)
+		if span == nil || span.File != doc.path {
+			return nil
+		}
+		rng := doc.spanRange(span)
+		return &documentSymbol{Name: name, Detail: detail, Kind: kind, Range: rng, SelectionRange: rng}
+	}
+	addChild := func(parent *documentSymbol, child *documentSymbol) {
+		if child != nil {
+			parent.Children = append(parent.Children, *child)
+		}
+	}
+	for _, t := range schema.Types {
+		name, super, _ := rdl.TypeInfo(t)
+		kind := symbolTypeParameter
+		switch t.Variant {
+		case rdl.TypeVariantStructTypeDef:
+			kind = symbolStruct
+		case rdl.TypeVariantEnumTypeDef:
+			kind = symbolEnum
+		case rdl.TypeVariantUnionTypeDef:
+			kind = symbolClass
+		}
+		sym := symbol(t, string(name), string(super), kind)
+		if sym == nil {
+			continue
+		}
+		if t.StructTypeDef != nil {
+			for _, f := range t.StructTypeDef.Fields {
+				addChild(sym, symbol(f, string(f.Name), string(f.Type), symbolField))
+			}
+		}
+		if t.EnumTypeDef != nil {
+			for _, e := range t.EnumTypeDef.Elements {
+				addChild(sym, symbol(e, string(e.Symbol), "", symbolEnumMember))
+			}
+		}
+		symbols = append(symbols, *sym)
+	}
+	for _, r := range schema.Resources {
+		sym := symbol(r, r.Method+" "+r.Path, string(r.Type), symbolMethod)
+		if sym == nil {
+			continue
+		}
+		for _, in := range r.Inputs {
+			addChild(sym, symbol(in, string(in.Name), string(in.Type), symbolProperty))
+		}
+		for _, out := range r.Outputs {
+			addChild(sym, symbol(out, string(out.Name), string(out.Type), symbolProperty))
+		}
+		symbols = append(symbols, *sym)
+	}
+	return symbols, nil
+}
+
+func isWordRune(ch rune) bool {
+	return ch == '_' || ch == '.' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
+
+//wordAt returns the identifier at the position, which may be qualified with the name of a used schema
+func (doc *document) wordAt(pos position) (string, lspRange) {
+	if pos.Line < 0 || pos.Line >= len(doc.lines) {
+		return "", lspRange{}
+	}
+	line := []rune(doc.lines[pos.Line])
+	begin := runeOffset(line, pos.Character)
+	end := begin
+	for begin > 0 && isWordRune(line[begin-1]) {
+		begin--
+	}
+	for end < len(line) && isWordRune(line[end]) {
+		end++
+	}
+	word := strings.Trim(string(line[begin:end]), ".")
+	return word, lspRange{Start: position{pos.Line, utf16Offset(line, begin)}, End: position{pos.Line, utf16Offset(line, end)}}
+}
+
+//spanRange converts the span, whose columns count runes from 1, to a range, whose characters count
+//UTF-16 code units from 0. The span may be in a file the document includes or uses.
+func (doc *document) spanRange(span *rdl.Span) lspRange {
+	lines := doc.lines
+	if span.File != "" && span.File != doc.path {
+		lines = nil
+		if data, err := ioutil.ReadFile(span.File); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+	}
+	start := lspPosition(lines, span.Line, span.Column)
+	if span.EndLine == 0 {
+		return lspRange{Start: start, End: start}
+	}
+	return lspRange{Start: start, End: lspPosition(lines, span.EndLine, span.EndColumn)}
+}
+
+func lspPosition(lines []string, line int, column int) position {
+	pos := position{line - 1, column - 1}
+	if pos.Line >= 0 && pos.Line < len(lines) {
+		pos.Character = utf16Offset([]rune(lines[pos.Line]), pos.Character)
+	}
+	return pos
+}
+
+//utf16Offset is the number of UTF-16 code units in the first n runes of the line, which may be past
+//its end
+func utf16Offset(line []rune, n int) int {
+	units := n
+	for i := 0; i < n && i < len(line); i++ {
+		if line[i] >= 0x10000 {
+			units++
+		}
+	}
+	return units
+}
+
+//runeOffset is the number of runes in the line before the UTF-16 offset, which may be past its end
+func runeOffset(line []rune, units int) int {
+	n := 0
+	for n < len(line) && units > 0 {
+		units--
+		if line[n] >= 0x10000 {
+			units--
+		}
+		n++
+	}
+	return n
+}
+
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,351 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSchema = `name pictures;
+use "polyline.rdl";
+include "names.rdl";
+
+// the colors of a picture
+type Color Enum { RED, GREEN }
+type Picture Struct {
+    SimpleName name;
+    test.Polyline outline (optional);
+    Color color;
+}
+resource Picture GET "/pictures/{name}" {
+    SimpleName name;
+    exceptions {
+        ResourceError NOT_FOUND;
+    }
+}
+`
+
+//client scripts a session, which the server then handles in process
+type client struct {
+	test   *testing.T
+	in     bytes.Buffer
+	nextID int
+}
+
+func (c *client) send(id int, method string, params interface{}) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if id > 0 {
+		msg["id"] = id
+	}
+	if err := writeMessage(&c.in, msg); err != nil {
+		c.test.Fatalf("Cannot write %s: %v", method, err)
+	}
+}
+
+func (c *client) request(method string, params interface{}) int {
+	c.nextID++
+	c.send(c.nextID, method, params)
+	return c.nextID
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(0, method, params)
+}
+
+func (c *client) at(uri string, line int, character int) interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": character},
+	}
+}
+
+type received struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+//run has the server handle the session, and returns the responses by id, and the notifications in order
+func (c *client) run() (map[int]*received, []*received) {
+	var out bytes.Buffer
+	if err := newServer(&out).serve(&c.in); err != nil {
+		c.test.Fatalf("The server failed: %v", err)
+	}
+	responses := make(map[int]*received)
+	var notifications []*received
+	r := bufio.NewReader(&out)
+	for {
+		data, err := readMessage(r)
+		if err != nil {
+			break
+		}
+		var msg received
+		if err = json.Unmarshal(data, &msg); err != nil {
+			c.test.Fatalf("Bad message from the server: %s", data)
+		}
+		if msg.ID != nil {
+			responses[*msg.ID] = &msg
+		} else {
+			notifications = append(notifications, &msg)
+		}
+	}
+	return responses, notifications
+}
+
+func decode(test *testing.T, msg *received, v interface{}) {
+	if msg == nil {
+		test.Fatalf("Missing response")
+	}
+	if msg.Error != nil {
+		test.Fatalf("Unexpected error: %v", msg.Error)
+	}
+	if err := json.Unmarshal(msg.Result, v); err != nil {
+		test.Fatalf("Cannot decode %s: %v", msg.Result, err)
+	}
+}
+
+func TestServer(test *testing.T) {
+	dir, err := filepath.Abs("../../testdata")
+	if err != nil {
+		test.Fatal(err)
+	}
+	uri := pathURI(filepath.Join(dir, "pictures.rdl"))
+	c := &client{test: test}
+	initialize := c.request("initialize", map[string]interface{}{"processId": nil, "rootUri": nil, "capabilities": map[string]interface{}{}})
+	c.notify("initialized", map[string]interface{}{})
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "rdl", "version": 1, "text": testSchema},
+	})
+	hoverColor := c.request("textDocument/hover", c.at(uri, 9, 5))
+	hoverUsed := c.request("textDocument/hover", c.at(uri, 8, 10))
+	hoverNothing := c.request("textDocument/hover", c.at(uri, 8, 28))
+	defUsed := c.request("textDocument/definition", c.at(uri, 8, 6))
+	defIncluded := c.request("textDocument/definition", c.at(uri, 7, 4))
+	defFile := c.request("textDocument/definition", c.at(uri, 2, 12))
+	completion := c.request("textDocument/completion", c.at(uri, 9, 0))
+	symbols := c.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": uri}})
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{map[string]string{"text": strings.Replace(testSchema, "Color color", "Colour color", 1)}},
+	})
+	hoverStale := c.request("textDocument/hover", c.at(uri, 5, 6))
+	unknown := c.request("textDocument/formatting", map[string]interface{}{})
+	notOpen := c.request("textDocument/hover", c.at("file:///nonexistent.rdl", 0, 0))
+	c.notify("$/cancelRequest", map[string]int{"id": 1})
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+	responses, notifications := c.run()
+
+	var init initializeResult
+	decode(test, responses[initialize], &init)
+	caps := init.Capabilities
+	if caps.TextDocumentSync != 1 || !caps.HoverProvider || !caps.DefinitionProvider || !caps.DocumentSymbolProvider {
+		test.Errorf("Unexpected capabilities: %+v", caps)
+	}
+
+	//the diagnostics are published when the document is opened, and when it is changed
+	if len(notifications) != 2 {
+		test.Fatalf("Expected 2 notifications, got %d", len(notifications))
+	}
+	var diags publishDiagnosticsParams
+	if json.Unmarshal(notifications[0].Params, &diags); notifications[0].Method != "textDocument/publishDiagnostics" || diags.URI != uri || len(diags.Diagnostics) != 0 {
+		test.Errorf("Expected no diagnostics for the opened document, got %s", notifications[0].Params)
+	}
+	json.Unmarshal(notifications[1].Params, &diags)
+	expected := diagnostic{Range: lspRange{position{9, 4}, position{9, 10}}, Severity: 1, Code: "undefined-type", Source: "rdl", Message: "No such type: Colour"}
+	if diags.URI != uri || len(diags.Diagnostics) != 1 || diags.Diagnostics[0] != expected {
+		test.Errorf("Expected the undefined type to be reported, got %s", notifications[1].Params)
+	}
+
+	//hover shows the type, and its comment
+	var h hover
+	decode(test, responses[hoverColor], &h)
+	if h.Contents.Value != "```rdl\ntype Color Enum\n```\nBase type: Enum\n\nthe colors of a picture" || *h.Range != (lspRange{position{9, 4}, position{9, 9}}) {
+		test.Errorf("Unexpected hover for Color: %+v", h)
+	}
+	decode(test, responses[hoverUsed], &h)
+	if !strings.HasPrefix(h.Contents.Value, "```rdl\ntype test.Polyline Struct\n```") {
+		test.Errorf("Unexpected hover for test.Polyline: %q", h.Contents.Value)
+	}
+	if string(responses[hoverNothing].Result) != "null" {
+		test.Errorf("Expected no hover for a field name, got %s", responses[hoverNothing].Result)
+	}
+	//after an edit that does not parse, the last schema is used
+	h = hover{}
+	decode(test, responses[hoverStale], &h)
+	if !strings.Contains(h.Contents.Value, "type Color Enum") {
+		test.Errorf("Unexpected hover after the change: %q", h.Contents.Value)
+	}
+
+	//definitions are found in the used and included files
+	var loc location
+	decode(test, responses[defUsed], &loc)
+	if loc.URI != pathURI(filepath.Join(dir, "polyline.rdl")) || loc.Range != (lspRange{position{7, 0}, position{9, 1}}) {
+		test.Errorf("Unexpected definition of test.Polyline: %+v", loc)
+	}
+	decode(test, responses[defIncluded], &loc)
+	if loc.URI != pathURI(filepath.Join(dir, "names.rdl")) || loc.Range.Start != (position{0, 0}) {
+		test.Errorf("Unexpected definition of SimpleName: %+v", loc)
+	}
+	decode(test, responses[defFile], &loc)
+	if loc.URI != pathURI(filepath.Join(dir, "names.rdl")) || loc.Range != (lspRange{}) {
+		test.Errorf("Unexpected definition of the included file: %+v", loc)
+	}
+
+	//completion offers the types from the registry, and the keywords
+	var items []completionItem
+	decode(test, responses[completion], &items)
+	labels := make(map[string]completionItem)
+	for _, item := range items {
+		labels[item.Label] = item
+	}
+	for _, label := range []string{"String", "Int32", "Color", "Picture", "SimpleName", "test.Polyline", "resource", "exceptions", "authorize"} {
+		if _, ok := labels[label]; !ok {
+			test.Errorf("Expected %s to be completed", label)
+		}
+	}
+	if item := labels["Color"]; item.Kind != completionEnum || item.Detail != "Enum" || item.Documentation != "the colors of a picture" {
+		test.Errorf("Unexpected completion for Color: %+v", item)
+	}
+	if item := labels["authorize"]; item.Kind != completionKeyword {
+		test.Errorf("Unexpected completion for authorize: %+v", item)
+	}
+
+	//the symbols are those defined in the document, and not those it includes or uses
+	var syms []documentSymbol
+	decode(test, responses[symbols], &syms)
+	var names []string
+	for _, sym := range syms {
+		names = append(names, sym.Name)
+	}
+	if strings.Join(names, ",") != "Color,Picture,GET /pictures/{name}" {
+		test.Fatalf("Unexpected symbols: %v", names)
+	}
+	if len(syms[0].Children) != 2 || syms[0].Children[1].Name != "GREEN" || syms[0].Children[1].Kind != symbolEnumMember || syms[0].Kind != symbolEnum {
+		test.Errorf("Unexpected symbol for Color: %+v", syms[0])
+	}
+	if len(syms[1].Children) != 3 || syms[1].Children[1].Name != "outline" || syms[1].Children[1].Detail != "test.Polyline" {
+		test.Errorf("Unexpected symbol for Picture: %+v", syms[1])
+	}
+	if syms[2].Kind != symbolMethod || syms[2].Range != (lspRange{position{11, 0}, position{16, 1}}) || len(syms[2].Children) != 1 {
+		test.Errorf("Unexpected symbol for the resource: %+v", syms[2])
+	}
+
+	if e := responses[unknown].Error; e == nil || e.Code != errorMethodNotFound {
+		test.Errorf("Expected an error for an unknown method, got %+v", responses[unknown])
+	}
+	if e := responses[notOpen].Error; e == nil || e.Code != errorInvalidParams {
+		test.Errorf("Expected an error for a document that is not open, got %+v", responses[notOpen])
+	}
+}
+
+func TestIncludedDiagnostics(test *testing.T) {
+	dir, err := filepath.Abs("../../testdata")
+	if err != nil {
+		test.Fatal(err)
+	}
+	uri := pathURI(filepath.Join(dir, "broken.rdl"))
+	included := pathURI(filepath.Join(dir, "unterminated_struct.rdl"))
+	c := &client{test: test}
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "rdl", "version": 1, "text": "include \"unterminated_struct.rdl\";\n"},
+	})
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{map[string]string{"text": "type Foo String;\n"}},
+	})
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+	_, notifications := c.run()
+
+	//the error is in the included file, and is cleared when the file is no longer included
+	var uris []string
+	counts := make(map[string]int)
+	for _, n := range notifications {
+		var diags publishDiagnosticsParams
+		json.Unmarshal(n.Params, &diags)
+		uris = append(uris, diags.URI)
+		counts[diags.URI] += len(diags.Diagnostics)
+	}
+	if strings.Join(uris, " ") != strings.Join([]string{uri, included, uri, included}, " ") || counts[uri] != 0 || counts[included] != 1 {
+		test.Errorf("Unexpected diagnostics: %v %v", uris, counts)
+	}
+}
+
+func TestSurrogatePairs(test *testing.T) {
+	//the emoji is one rune, and two UTF-16 code units, which is what LSP characters count
+	uri := "file:///tmp/emoji.rdl"
+	text := "type Color Enum { RED, GREEN }\ntype Label String (pattern=\"\U0001F3A8\"); type Tint Struct { Color color; }\n"
+	c := &client{test: test}
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "rdl", "version": 1, "text": text},
+	})
+	hoverColor := c.request("textDocument/hover", c.at(uri, 1, 58))
+	symbols := c.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": uri}})
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{map[string]string{"text": strings.Replace(text, "{ Color", "{ Colour", 1)}},
+	})
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+	responses, notifications := c.run()
+
+	var h hover
+	decode(test, responses[hoverColor], &h)
+	if !strings.Contains(h.Contents.Value, "type Color Enum") || *h.Range != (lspRange{position{1, 53}, position{1, 58}}) {
+		test.Errorf("Unexpected hover for Color after the emoji: %+v", h)
+	}
+	var syms []documentSymbol
+	decode(test, responses[symbols], &syms)
+	if len(syms) != 3 || syms[2].Name != "Tint" || syms[2].Range.Start != (position{1, 34}) {
+		test.Errorf("Unexpected symbol for Tint after the emoji: %+v", syms)
+	}
+	var diags publishDiagnosticsParams
+	if len(notifications) != 2 {
+		test.Fatalf("Expected 2 notifications, got %d", len(notifications))
+	}
+	json.Unmarshal(notifications[1].Params, &diags)
+	if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Range != (lspRange{position{1, 53}, position{1, 59}}) {
+		test.Errorf("Unexpected diagnostics after the emoji: %s", notifications[1].Params)
+	}
+}
+
+func TestExitWithoutShutdown(test *testing.T) {
+	c := &client{test: test}
+	c.notify("exit", nil)
+	var out bytes.Buffer
+	if err := newServer(&out).serve(&c.in); err == nil {
+		test.Errorf("Expected an error for exit without shutdown")
+	}
+	if err := newServer(&out).serve(strings.NewReader("Content-Type: text/plain\r\n\r\n{}")); err == nil {
+		test.Errorf("Expected an error for a message without a length")
+	}
+	if err := newServer(&out).serve(strings.NewReader("Content-Length: 1000000000000\r\n\r\n{}")); err == nil || !strings.Contains(err.Error(), "limit") {
+		test.Errorf("Expected an error for a message longer than the limit, got %v", err)
+	}
+}
+
+func TestWordAt(test *testing.T) {
+	doc := &document{lines: []string{"test.Polyline outline;"}}
+	for _, character := range []int{-5, 0, 5, 13} {
+		if word, _ := doc.wordAt(position{0, character}); word != "test.Polyline" {
+			test.Errorf("Expected test.Polyline at %d, got %q", character, word)
+		}
+	}
+	if word, rng := doc.wordAt(position{0, 100}); word != "" || rng.Start.Character != 22 {
+		test.Errorf("Expected no word past the end of the line, got %q at %v", word, rng)
+	}
+	//characters are UTF-16 code units, of which the emoji has two
+	doc = &document{lines: []string{"// \U0001F3A8 test.Polyline"}}
+	if word, rng := doc.wordAt(position{0, 6}); word != "test.Polyline" || rng != (lspRange{position{0, 6}, position{0, 19}}) {
+		test.Errorf("Expected test.Polyline after the emoji, got %q at %v", word, rng)
+	}
+}
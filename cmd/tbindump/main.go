@@ -0,0 +1,43 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+//
+// tbindump prints an annotated listing of a TBin stream, read from the named file or stdin.
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ardielle/ardielle-go/tbin"
+)
+
+func main() {
+	pHex := flag.Bool("x", false, "show the bytes of each element beside it")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: tbindump [-x] [file]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	var data []byte
+	var err error
+	switch flag.NArg() {
+	case 0:
+		data, err = ioutil.ReadAll(os.Stdin)
+	case 1:
+		data, err = ioutil.ReadFile(flag.Arg(0))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err == nil {
+		err = tbin.Dump(os.Stdout, data, *pHex)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tbindump: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -593,7 +593,6 @@ func (pTypeDef *Test) Validate() error {
 	if pTypeDef.My_str_array == nil {
 		return fmt.Errorf("Test: Missing required field: my_str_array")
 	}
-	fmt.Println("myuuid:", pTypeDef.Myuuid)
 	if pTypeDef.Myuuid == nil {
 		return fmt.Errorf("Test: Missing required field: myuuid")
 	}
@@ -0,0 +1,171 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//
+// Origin - where a type of a bundle was defined: the file, and the name of the schema it was
+// defined in. Types of an included file without a name are in the schema that includes it. Span is
+// nil for the types the parser generates, i.e. ArrayOfPoint, and for the types of the rdl schema.
+//
+type Origin struct {
+	File   string
+	Schema Identifier
+	Span   *Span
+}
+
+//
+// Collision - a type name with more than one definition, that differ. The definitions are in the
+// order they were parsed, and the last is the one the bundle has.
+//
+type Collision struct {
+	Name        TypeName
+	Definitions []*Origin
+}
+
+func (c *Collision) String() string {
+	var where []string
+	for _, o := range c.Definitions {
+		if o.Span != nil {
+			where = append(where, o.Span.String())
+		} else {
+			where = append(where, o.File)
+		}
+	}
+	return fmt.Sprintf("%s has conflicting definitions in %s", c.Name, strings.Join(where, ", "))
+}
+
+//
+// Bundle - a schema with all of its include and use directives resolved, so that it stands alone.
+// The types of used schemas have names qualified by the schema name, i.e. "geo.Point". Write it
+// with WriteJSON, or ExportToJSON, to have a single self-contained file.
+//
+type Bundle struct {
+	Schema *Schema
+
+	//Origins has the origin of each type of the schema, by name
+	Origins map[TypeName]*Origin
+
+	//Collisions are the type names with conflicting definitions. Unless parsing is pedantic, when
+	//such a collision is an error, the last definition replaces the others.
+	Collisions []*Collision
+}
+
+//
+// BundleRDLFile - parse the file, with the files it includes and uses, into a Bundle
+//
+func BundleRDLFile(path string, options *ParseOptions) (*Bundle, error) {
+	return bundleRDL(func(b *bundler) (*Schema, *SourceMap, error) {
+		return parseRDLFile(path, nil, b, options)
+	})
+}
+
+//
+// BundleRDL - parse the schema read from the reader, with the files it includes and uses, into a
+// Bundle. The source names it, and the files are found relative to it.
+//
+func BundleRDL(source string, reader io.Reader, options *ParseOptions) (*Bundle, error) {
+	return bundleRDL(func(b *bundler) (*Schema, *SourceMap, error) {
+		return parseRDLWithOptions(nil, b, source, reader, options)
+	})
+}
+
+func bundleRDL(parse func(b *bundler) (*Schema, *SourceMap, error)) (*Bundle, error) {
+	b := &bundler{
+		origins:   make(map[interface{}]string),
+		names:     make(map[string]Identifier),
+		includers: make(map[string]string),
+	}
+	schema, sources, err := parse(b)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{Schema: schema, Origins: make(map[TypeName]*Origin)}
+	for _, t := range schema.Types {
+		name, _, _ := TypeInfo(t)
+		bundle.Origins[name] = b.origin(sources, typeDef(t))
+	}
+	for _, c := range b.collisions {
+		collision := &Collision{Name: c.name}
+		for _, def := range c.defs {
+			collision.Definitions = append(collision.Definitions, b.origin(sources, def))
+		}
+		bundle.Collisions = append(bundle.Collisions, collision)
+	}
+	return bundle, nil
+}
+
+//bundler is what the parser records while bundling. The definitions of types are keyed by their
+//address, which is the same after the types of used schemas are renamed.
+type bundler struct {
+	origins    map[interface{}]string //the file each definition was first registered in
+	names      map[string]Identifier  //the name of the schema in each file
+	includers  map[string]string      //the file that includes each included file
+	collisions []*bundleCollision
+}
+
+type bundleCollision struct {
+	name TypeName
+	defs []interface{}
+}
+
+//define records the file of a type when it is first registered, which is in the parser of the
+//file that defines it, as included and used files are parsed before their types are registered
+func (b *bundler) define(file string, t *Type) {
+	if def := typeDef(t); def != nil {
+		if _, ok := b.origins[def]; !ok {
+			b.origins[def] = file
+		}
+	}
+}
+
+//use records the types of a schema that was not parsed, i.e. the rdl schema
+func (b *bundler) use(file string, schema *Schema) {
+	b.names[file] = schema.Name
+	for _, t := range schema.Types {
+		b.define(file, t)
+	}
+}
+
+//collide records conflicting definitions of a name. Types from included and used files are
+//registered again by the parsers of the files that include them, and so can collide more than once.
+func (b *bundler) collide(name TypeName, prev *Type, t *Type) {
+	def := typeDef(t)
+	for _, c := range b.collisions {
+		if c.name == name {
+			for _, d := range c.defs {
+				if d == def {
+					return
+				}
+			}
+			c.defs = append(c.defs, def)
+			return
+		}
+	}
+	b.collisions = append(b.collisions, &bundleCollision{name: name, defs: []interface{}{typeDef(prev), def}})
+}
+
+func (b *bundler) origin(sources *SourceMap, def interface{}) *Origin {
+	file := b.origins[def]
+	return &Origin{File: file, Schema: b.schemaName(file), Span: sources.Position(def)}
+}
+
+//schemaName is the name of the schema in the file, or else in the file that includes it
+func (b *bundler) schemaName(file string) Identifier {
+	for {
+		if name := b.names[file]; name != "" {
+			return name
+		}
+		includer, ok := b.includers[file]
+		if !ok {
+			return ""
+		}
+		file = includer
+	}
+}
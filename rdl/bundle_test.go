@@ -0,0 +1,101 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"strings"
+	"testing"
+)
+
+func checkOrigin(test *testing.T, bundle *Bundle, name TypeName, file string, schema Identifier, line int) {
+	o := bundle.Origins[name]
+	if o == nil {
+		test.Errorf("No origin for %s", name)
+		return
+	}
+	if o.File != file || o.Schema != schema {
+		test.Errorf("Wrong origin for %s: %s in %s, expected %s in %s", name, o.Schema, o.File, schema, file)
+	}
+	if line != 0 && (o.Span == nil || o.Span.Line != line) {
+		test.Errorf("Wrong span for %s: %v, expected line %d", name, o.Span, line)
+	}
+}
+
+func TestBundleIncludes(test *testing.T) {
+	bundle, err := BundleRDLFile("../testdata/drawing.rdl", nil)
+	if err != nil {
+		test.Fatalf("Cannot bundle: %v", err)
+	}
+	checkOrigin(test, bundle, "Point", "../testdata/polyline.rdl", "test", 3)
+	checkOrigin(test, bundle, "Polyline", "../testdata/polyline.rdl", "test", 8)
+	checkOrigin(test, bundle, "Rect", "../testdata/drawing.rdl", "test", 5)
+	checkOrigin(test, bundle, "Drawing", "../testdata/drawing.rdl", "test", 12)
+	if len(bundle.Origins) != len(bundle.Schema.Types) {
+		test.Errorf("Expected an origin for each of the %d types, got %d", len(bundle.Schema.Types), len(bundle.Origins))
+	}
+	if len(bundle.Collisions) != 0 {
+		test.Errorf("Unexpected collisions: %v", bundle.Collisions)
+	}
+}
+
+func TestBundleUnnamedInclude(test *testing.T) {
+	bundle, err := BundleRDL("../testdata/ids.rdl", strings.NewReader("name ids;\ninclude \"names.rdl\";\n"), &ParseOptions{NoWarn: true})
+	if err != nil {
+		test.Fatalf("Cannot bundle: %v", err)
+	}
+	checkOrigin(test, bundle, "SimpleName", "../testdata/names.rdl", "ids", 1)
+	checkOrigin(test, bundle, "CompoundName", "../testdata/names.rdl", "ids", 3)
+}
+
+func TestBundleUses(test *testing.T) {
+	src := `name shapes;
+use "point3d.rdl";
+type Box Struct {
+    geo.Point min;
+    geo.Point max;
+}
+`
+	bundle, err := BundleRDL("../testdata/shapes.rdl", strings.NewReader(src), nil)
+	if err != nil {
+		test.Fatalf("Cannot bundle: %v", err)
+	}
+	checkOrigin(test, bundle, "geo.Point", "../testdata/point3d.rdl", "geo", 3)
+	checkOrigin(test, bundle, "Box", "../testdata/shapes.rdl", "shapes", 3)
+
+	//the bundle stands alone, with qualified names
+	reg := NewTypeRegistry(bundle.Schema)
+	box := reg.FindType("Box")
+	if box == nil || box.StructTypeDef.Fields[0].Type != "geo.Point" || reg.FindType("geo.Point") == nil {
+		test.Errorf("Bundle does not resolve the used types: %v", bundle.Schema)
+	}
+}
+
+func TestBundleCollisions(test *testing.T) {
+	src := "name shapes;\ninclude \"polyline.rdl\";\ninclude \"point3d.rdl\";\n"
+	bundle, err := BundleRDL("../testdata/shapes.rdl", strings.NewReader(src), &ParseOptions{NoWarn: true})
+	if err != nil {
+		test.Fatalf("Cannot bundle: %v", err)
+	}
+	if len(bundle.Collisions) != 1 {
+		test.Fatalf("Expected 1 collision, got %v", bundle.Collisions)
+	}
+	c := bundle.Collisions[0]
+	if c.Name != "Point" || len(c.Definitions) != 2 || c.Definitions[0].File != "../testdata/polyline.rdl" || c.Definitions[1].File != "../testdata/point3d.rdl" {
+		test.Errorf("Wrong collision: %v", c)
+	}
+	expected := "Point has conflicting definitions in ../testdata/polyline.rdl:3:1, ../testdata/point3d.rdl:3:1"
+	if c.String() != expected {
+		test.Errorf("Wrong description of the collision: %q, expected %q", c.String(), expected)
+	}
+	//the last definition is the one the bundle has
+	checkOrigin(test, bundle, "Point", "../testdata/point3d.rdl", "geo", 3)
+	if p := NewTypeRegistry(bundle.Schema).FindType("Point"); p == nil || len(p.StructTypeDef.Fields) != 3 {
+		test.Errorf("Wrong definition of Point in the bundle")
+	}
+
+	_, err = BundleRDL("../testdata/shapes.rdl", strings.NewReader(src), &ParseOptions{Pedantic: true, NoWarn: true})
+	if err == nil || !strings.Contains(err.Error(), "conflicting definitions of Point") {
+		test.Errorf("Expected an error for the collision in pedantic mode, got %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+//
+// DependencyGraph - the dependencies of the types and resources of a schema on its types. A type
+// depends on its supertype, the types of its fields, the items and keys of arrays and maps, and
+// the variants of unions. A resource depends on its type, and the types of its inputs, outputs,
+// and exceptions. Base types, and types the schema does not define, are not in the graph.
+//
+type DependencyGraph struct {
+	schema   *Schema
+	registry TypeRegistry
+	names    []TypeName
+	deps     map[TypeName][]TypeName
+}
+
+//
+// NewDependencyGraph - create the dependency graph of the schema
+//
+func NewDependencyGraph(schema *Schema) *DependencyGraph {
+	g := &DependencyGraph{schema: schema, registry: NewTypeRegistry(schema), deps: make(map[TypeName][]TypeName)}
+	for _, t := range schema.Types {
+		name, _, _ := TypeInfo(t)
+		g.names = append(g.names, name)
+		g.deps[name] = g.resolve(typeReferences(t))
+	}
+	return g
+}
+
+//typeReferences returns the names of the types a type refers to
+func typeReferences(t *Type) []TypeRef {
+	_, super, _ := TypeInfo(t)
+	refs := []TypeRef{super}
+	switch t.Variant {
+	case TypeVariantArrayTypeDef:
+		refs = append(refs, t.ArrayTypeDef.Items)
+	case TypeVariantMapTypeDef:
+		refs = append(refs, t.MapTypeDef.Keys, t.MapTypeDef.Items)
+	case TypeVariantStructTypeDef:
+		for _, f := range t.StructTypeDef.Fields {
+			refs = append(refs, f.Type, f.Items, f.Keys)
+		}
+	case TypeVariantUnionTypeDef:
+		refs = append(refs, t.UnionTypeDef.Variants...)
+	}
+	return refs
+}
+
+//resourceReferences returns the names of the types a resource refers to
+func resourceReferences(r *Resource) []TypeRef {
+	refs := []TypeRef{r.Type}
+	for _, in := range r.Inputs {
+		refs = append(refs, in.Type)
+	}
+	for _, out := range r.Outputs {
+		refs = append(refs, out.Type)
+	}
+	for _, e := range r.Exceptions {
+		refs = append(refs, TypeRef(e.Type))
+	}
+	return refs
+}
+
+//resolve finds the types of the schema that are referred to, without duplicates
+func (g *DependencyGraph) resolve(refs []TypeRef) []TypeName {
+	var names []TypeName
+	seen := make(map[TypeName]bool)
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		t := g.registry.FindType(ref)
+		if t == nil || t.Variant == TypeVariantBaseType {
+			continue
+		}
+		name, _, _ := TypeInfo(t)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+//
+// Types - the names of the types in the graph, in the order of the schema
+//
+func (g *DependencyGraph) Types() []TypeName {
+	return g.names
+}
+
+//
+// Dependencies - the types the named type refers to directly
+//
+func (g *DependencyGraph) Dependencies(name TypeName) []TypeName {
+	return g.deps[g.canonical(name)]
+}
+
+//
+// Dependents - the types that refer to the named type directly, in the order of the schema
+//
+func (g *DependencyGraph) Dependents(name TypeName) []TypeName {
+	name = g.canonical(name)
+	var result []TypeName
+	for _, n := range g.names {
+		for _, dep := range g.deps[n] {
+			if dep == name {
+				result = append(result, n)
+				break
+			}
+		}
+	}
+	return result
+}
+
+//
+// ResourceDependencies - the types the resource refers to directly
+//
+func (g *DependencyGraph) ResourceDependencies(r *Resource) []TypeName {
+	return g.resolve(resourceReferences(r))
+}
+
+//canonical returns the name as the schema has it, as type names are case insensitive
+func (g *DependencyGraph) canonical(name TypeName) TypeName {
+	if t := g.registry.FindType(TypeRef(name)); t != nil {
+		name, _, _ = TypeInfo(t)
+	}
+	return name
+}
+
+//
+// Reachable - the transitive closure of the named types: the types themselves, and all the types
+// they depend on, directly or indirectly, in the order they are found
+//
+func (g *DependencyGraph) Reachable(roots ...TypeName) []TypeName {
+	var result []TypeName
+	seen := make(map[TypeName]bool)
+	var visit func(name TypeName)
+	visit = func(name TypeName) {
+		if _, ok := g.deps[name]; !ok || seen[name] {
+			return
+		}
+		seen[name] = true
+		result = append(result, name)
+		for _, dep := range g.deps[name] {
+			visit(dep)
+		}
+	}
+	for _, root := range roots {
+		visit(g.canonical(root))
+	}
+	return result
+}
+
+//
+// ResourceReachable - all the types the resources depend on, directly or indirectly
+//
+func (g *DependencyGraph) ResourceReachable(resources ...*Resource) []TypeName {
+	var roots []TypeName
+	for _, r := range resources {
+		roots = append(roots, g.ResourceDependencies(r)...)
+	}
+	return g.Reachable(roots...)
+}
+
+//
+// Components - the strongly connected components of the graph, each a group of types that depend
+// on each other, or a single type. Each component comes after the components it depends on.
+//
+func (g *DependencyGraph) Components() [][]TypeName {
+	//Tarjan's algorithm, which finds a component after those it depends on
+	index := make(map[TypeName]int)
+	lowlink := make(map[TypeName]int)
+	onStack := make(map[TypeName]bool)
+	var stack []TypeName
+	var components [][]TypeName
+	var connect func(name TypeName)
+	connect = func(name TypeName) {
+		index[name] = len(index)
+		lowlink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+		for _, dep := range g.deps[name] {
+			if _, visited := index[dep]; !visited {
+				connect(dep)
+				lowlink[name] = min(lowlink[name], lowlink[dep])
+			} else if onStack[dep] {
+				lowlink[name] = min(lowlink[name], index[dep])
+			}
+		}
+		if lowlink[name] == index[name] {
+			var component []TypeName
+			for {
+				n := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[n] = false
+				component = append(component, n)
+				if n == name {
+					break
+				}
+			}
+			//the members of a component are in the order of the schema
+			ordered := make([]TypeName, 0, len(component))
+			for _, n := range g.names {
+				for _, c := range component {
+					if c == n {
+						ordered = append(ordered, n)
+					}
+				}
+			}
+			components = append(components, ordered)
+		}
+	}
+	for _, name := range g.names {
+		if _, visited := index[name]; !visited {
+			connect(name)
+		}
+	}
+	return components
+}
+
+//
+// Recursive - the components of types that depend on themselves, directly or indirectly
+//
+func (g *DependencyGraph) Recursive() [][]TypeName {
+	var result [][]TypeName
+	for _, c := range g.Components() {
+		if len(c) > 1 || g.dependsOn(c[0], c[0]) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (g *DependencyGraph) dependsOn(name TypeName, dep TypeName) bool {
+	for _, d := range g.deps[name] {
+		if d == dep {
+			return true
+		}
+	}
+	return false
+}
+
+//
+// TopologicalOrder - the types, each after the types it depends on. Types that depend on each other
+// are together, in the order of the schema.
+//
+func (g *DependencyGraph) TopologicalOrder() []TypeName {
+	var result []TypeName
+	for _, c := range g.Components() {
+		result = append(result, c...)
+	}
+	return result
+}
+
+//
+// WriteDOT - write the graph in the DOT language of Graphviz. Resources are boxes, named by their
+// method and path.
+//
+func (g *DependencyGraph) WriteDOT(out io.Writer) error {
+	name := string(g.schema.Name)
+	if name == "" {
+		name = "schema"
+	}
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(out, format, args...)
+		}
+	}
+	printf("digraph %s {\n", strconv.Quote(name))
+	for _, n := range g.names {
+		printf("    %s;\n", strconv.Quote(string(n)))
+	}
+	for _, r := range g.schema.Resources {
+		printf("    %s [shape=box];\n", strconv.Quote(r.Method+" "+r.Path))
+	}
+	for _, n := range g.names {
+		for _, dep := range g.deps[n] {
+			printf("    %s -> %s;\n", strconv.Quote(string(n)), strconv.Quote(string(dep)))
+		}
+	}
+	for _, r := range g.schema.Resources {
+		for _, dep := range g.ResourceDependencies(r) {
+			printf("    %s -> %s;\n", strconv.Quote(r.Method+" "+r.Path), strconv.Quote(string(dep)))
+		}
+	}
+	printf("}\n")
+	return err
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const depgraphTestSchema = `name graph;
+type Label String;
+type Leaf Union<Label,Int32>;
+type Node Struct {
+    Label label;
+    Array<Node> children;
+    Map<Label,Leaf> leaves (optional);
+}
+type Tree Struct {
+    Node root;
+}
+type Base Struct {
+    String id;
+}
+type Labels Array<Label>;
+type Derived Base {
+    Labels labels;
+}
+type Unused String;
+type Problem Struct {
+    String message;
+}
+resource Tree GET "/trees/{id}" {
+    Label id;
+    exceptions {
+        Problem NOT_FOUND;
+    }
+}
+`
+
+func TestDependencyGraph(test *testing.T) {
+	schema, err := parseRDL(nil, "graph.rdl", strings.NewReader(depgraphTestSchema), false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse the schema: %v", err)
+	}
+	g := NewDependencyGraph(schema)
+	check := func(what string, got interface{}, expected string) {
+		if s := fmt.Sprint(got); s != expected {
+			test.Errorf("Expected %s to be %s, got %s", what, expected, s)
+		}
+	}
+	check("the types", g.Types(), "[Label Leaf Node Tree Base Labels Derived Unused Problem]")
+	check("the dependencies of Node", g.Dependencies("Node"), "[Label Node Leaf]")
+	check("the dependencies of Derived", g.Dependencies("derived"), "[Base Labels]")
+	check("the dependencies of Unused", g.Dependencies("Unused"), "[]")
+	check("the dependents of Label", g.Dependents("Label"), "[Leaf Node Labels]")
+	check("the resource dependencies", g.ResourceDependencies(schema.Resources[0]), "[Tree Label Problem]")
+	check("the types reachable from Derived", g.Reachable("Derived"), "[Derived Base Labels Label]")
+	check("the types reachable from the resource", g.ResourceReachable(schema.Resources...), "[Tree Node Label Leaf Problem]")
+	check("the components", g.Components(), "[[Label] [Leaf] [Node] [Tree] [Base] [Labels] [Derived] [Unused] [Problem]]")
+
+	//the order of the schema need not be a topological one, i.e. for a schema that is built
+	reversed := NewSchema()
+	for i := len(schema.Types) - 1; i >= 0; i-- {
+		reversed.Types = append(reversed.Types, schema.Types[i])
+	}
+	check("the topological order", NewDependencyGraph(reversed).TopologicalOrder(), "[Problem Unused Base Label Labels Derived Leaf Node Tree]")
+	check("the recursive components", g.Recursive(), "[[Node]]")
+
+	var out bytes.Buffer
+	if err = g.WriteDOT(&out); err != nil {
+		test.Fatalf("Cannot write DOT: %v", err)
+	}
+	for _, s := range []string{"digraph \"graph\" {\n", "    \"Tree\";\n", "    \"GET /trees/{id}\" [shape=box];\n", "    \"Node\" -> \"Node\";\n", "    \"GET /trees/{id}\" -> \"Problem\";\n"} {
+		if !strings.Contains(out.String(), s) {
+			test.Errorf("Expected the DOT output to contain %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestDependencyGraphCycles(test *testing.T) {
+	schema := loadTestSchema(test, "recursive.rdl")
+	g := NewDependencyGraph(schema)
+	if s := fmt.Sprint(g.Recursive()); s != "[[RouteRule]]" {
+		test.Errorf("Unexpected recursive components of recursive.rdl: %s", s)
+	}
+	//the parser only accepts references to types defined earlier, so mutual recursion needs a schema
+	//that is built
+	schema = NewSchemaBuilder("cycle").
+		AddType(NewStructTypeBuilder("Struct", "A").Field("b", "B", true, nil, "").Build()).
+		AddType(NewStructTypeBuilder("Struct", "B").Field("c", "C", true, nil, "").Build()).
+		AddType(NewStructTypeBuilder("Struct", "C").Field("a", "A", true, nil, "").Build()).
+		AddType(NewStructTypeBuilder("Struct", "D").Field("a", "A", false, nil, "").Build()).
+		Build()
+	g = NewDependencyGraph(schema)
+	if s := fmt.Sprint(g.Components()); s != "[[A B C] [D]]" {
+		test.Errorf("Unexpected components: %s", s)
+	}
+	if s := fmt.Sprint(g.Recursive()); s != "[[A B C]]" {
+		test.Errorf("Unexpected recursive components: %s", s)
+	}
+}
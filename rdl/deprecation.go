@@ -0,0 +1,145 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//
+// Annotations that deprecate a type, a struct field, an enum element, or a resource, i.e.
+// (x_deprecated="use Color", x_replacement="Color", x_deprecated_since="2026-01-01T00:00:00Z")
+//
+const (
+	AnnotationDeprecated      ExtendedAnnotation = "x_deprecated"       // the element is deprecated, with an optional message
+	AnnotationReplacement     ExtendedAnnotation = "x_replacement"      // the name of what replaces the element
+	AnnotationDeprecatedSince ExtendedAnnotation = "x_deprecated_since" // the Timestamp of the deprecation
+)
+
+//
+// Deprecation - the deprecation of an element of a schema, from its annotations
+//
+type Deprecation struct {
+	Message     string
+	Replacement string
+	Since       string
+}
+
+//
+// Deprecated - the deprecation the annotations declare, or nil if they have no x_deprecated
+//
+func Deprecated(annotations map[ExtendedAnnotation]string) *Deprecation {
+	message, ok := annotations[AnnotationDeprecated]
+	if !ok {
+		return nil
+	}
+	return &Deprecation{Message: message, Replacement: annotations[AnnotationReplacement], Since: annotations[AnnotationDeprecatedSince]}
+}
+
+//
+// TypeDeprecation - the deprecation of the type, or nil if it is not deprecated
+//
+func TypeDeprecation(t *Type) *Deprecation {
+	return Deprecated(typeAnnotations(t))
+}
+
+func (d *Deprecation) String() string {
+	var parts []string
+	if d.Message != "" {
+		parts = append(parts, d.Message)
+	}
+	if d.Replacement != "" {
+		parts = append(parts, "replaced by "+d.Replacement)
+	}
+	if d.Since != "" {
+		parts = append(parts, "since "+d.Since)
+	}
+	return strings.Join(parts, ", ")
+}
+
+//explain adds the message, replacement, and date of the deprecation, if there are any
+func (d *Deprecation) explain(msg string) string {
+	if s := d.String(); s != "" {
+		return msg + ": " + s
+	}
+	return msg
+}
+
+//
+// CheckDeprecations - find the references to deprecated types, by types, struct fields, and
+// resources that are not themselves deprecated. The diagnostics are warnings, at the positions of
+// the references in the source map, which may be nil.
+//
+func CheckDeprecations(schema *Schema, sources *SourceMap) []*Diagnostic {
+	var diags []*Diagnostic
+	registry := NewTypeRegistry(schema)
+	check := func(referrer string, refs []TypeRef, elems ...interface{}) {
+		seen := make(map[TypeName]bool)
+		for _, ref := range refs {
+			if ref == "" {
+				continue
+			}
+			t := registry.FindType(ref)
+			if t == nil || t.Variant == TypeVariantBaseType {
+				continue
+			}
+			name, _, _ := TypeInfo(t)
+			d := TypeDeprecation(t)
+			if d == nil || seen[name] {
+				continue
+			}
+			seen[name] = true
+			diag := &Diagnostic{Severity: SeverityWarning, Code: DiagnosticDeprecated}
+			diag.Message = d.explain(fmt.Sprintf("%s refers to the deprecated type %s", referrer, name))
+			for _, elem := range elems {
+				if span := sources.Position(elem); span != nil {
+					diag.Span = *span
+					break
+				}
+			}
+			diags = append(diags, diag)
+		}
+	}
+	for _, t := range schema.Types {
+		if TypeDeprecation(t) != nil {
+			continue
+		}
+		name, super, _ := TypeInfo(t)
+		if t.StructTypeDef == nil {
+			check("type "+string(name), typeReferences(t), t)
+			continue
+		}
+		check("type "+string(name), []TypeRef{super}, t)
+		for _, f := range t.StructTypeDef.Fields {
+			if Deprecated(f.Annotations) == nil {
+				check(fmt.Sprintf("field %s.%s", name, f.Name), []TypeRef{f.Type, f.Items, f.Keys}, f, t)
+			}
+		}
+	}
+	for _, r := range schema.Resources {
+		if Deprecated(r.Annotations) != nil {
+			continue
+		}
+		what := r.Method + " " + r.Path
+		check(what, []TypeRef{r.Type}, r)
+		for _, in := range r.Inputs {
+			check(fmt.Sprintf("input %s of %s", in.Name, what), []TypeRef{in.Type}, in, r)
+		}
+		for _, out := range r.Outputs {
+			check(fmt.Sprintf("output %s of %s", out.Name, what), []TypeRef{out.Type}, out, r)
+		}
+		var syms []string
+		for sym := range r.Exceptions {
+			syms = append(syms, sym)
+		}
+		sort.Strings(syms)
+		for _, sym := range syms {
+			e := r.Exceptions[sym]
+			check(fmt.Sprintf("exception %s of %s", sym, what), []TypeRef{TypeRef(e.Type)}, e, r)
+		}
+	}
+	return diags
+}
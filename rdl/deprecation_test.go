@@ -0,0 +1,116 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"strings"
+	"testing"
+)
+
+const deprecationTestSchema = `name colors;
+type Colour String (x_deprecated="use Color", x_replacement="Color");
+type Color String;
+type Shade Enum {
+    LIGHT
+    PALE (x_deprecated, x_replacement="LIGHT")
+    DARK
+}
+type Paint Struct {
+    Color color;
+    Colour colour (optional, x_deprecated);
+    Shade shade (optional);
+    Colour tint (optional);
+}
+type OldPaint Paint (x_deprecated) {
+    Colour trim;
+}
+type Palette Array<Colour>;
+resource Paint GET "/paints/{name}" {
+    Colour name;
+}
+resource Paint GET "/v1/paints/{name}" (x_deprecated="use /paints", x_deprecated_since="2026-01-01T00:00:00Z") {
+    Colour name;
+}
+`
+
+func TestDeprecatedAnnotations(test *testing.T) {
+	d := Deprecated(map[ExtendedAnnotation]string{"x_deprecated": "use Color", "x_replacement": "Color"})
+	if d == nil || d.String() != "use Color, replaced by Color" {
+		test.Errorf("Wrong deprecation: %v", d)
+	}
+	if d = Deprecated(map[ExtendedAnnotation]string{"x_deprecated": ""}); d == nil || d.String() != "" {
+		test.Errorf("Wrong deprecation without a message: %v", d)
+	}
+	if d = Deprecated(map[ExtendedAnnotation]string{"x_replacement": "Color"}); d != nil {
+		test.Errorf("Unexpected deprecation: %v", d)
+	}
+}
+
+func TestCheckDeprecations(test *testing.T) {
+	diags, err := collectDiagnostics("colors.rdl", deprecationTestSchema, nil)
+	if err != nil {
+		test.Fatalf("Cannot parse: %v", err)
+	}
+	expected := []string{
+		"colors.rdl:13:5: field Paint.tint refers to the deprecated type Colour: use Color, replaced by Color",
+		"colors.rdl:18:1: type Palette refers to the deprecated type Colour: use Color, replaced by Color",
+		"colors.rdl:20:5: input name of GET /paints/{name} refers to the deprecated type Colour: use Color, replaced by Color",
+	}
+	if len(diags) != len(expected) {
+		test.Fatalf("Expected %d diagnostics, got %v", len(expected), diags)
+	}
+	for i, d := range diags {
+		if s := d.Span.String() + ": " + d.Message; s != expected[i] || d.Code != DiagnosticDeprecated || d.Severity != SeverityWarning {
+			test.Errorf("Wrong diagnostic: %q (%s), expected %q", s, d.Code, expected[i])
+		}
+	}
+	diags, _ = collectDiagnostics("colors.rdl", deprecationTestSchema, &ParseOptions{NoWarn: true})
+	if len(diags) != 0 {
+		test.Errorf("Unexpected diagnostics with NoWarn: %v", diags)
+	}
+}
+
+func TestValidateDeprecations(test *testing.T) {
+	schema, err := parseRDL(nil, "colors.rdl", strings.NewReader(deprecationTestSchema), false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse: %v", err)
+	}
+	data := map[string]interface{}{"color": "red", "colour": "red", "shade": "PALE"}
+	v := Validate(schema, "Paint", data)
+	if !v.Valid || v.Deprecated != nil {
+		test.Errorf("Expected valid data, without deprecations: %v", v)
+	}
+	v = ValidateWithOptions(schema, "Paint", data, &ValidateOptions{ReportDeprecated: true})
+	expected := []string{
+		"Paint.colour: the field colour of Paint is deprecated",
+		"Paint.shade: the value PALE of Shade is deprecated: replaced by LIGHT",
+	}
+	if !v.Valid || len(v.Deprecated) != len(expected) {
+		test.Fatalf("Expected valid data, with %d deprecations: %v", len(expected), v)
+	}
+	for i, s := range v.Deprecated {
+		if s != expected[i] {
+			test.Errorf("Wrong deprecation: %q, expected %q", s, expected[i])
+		}
+	}
+	v = ValidateWithOptions(schema, "Paint", map[string]interface{}{"color": "red", "shade": "DARK"}, &ValidateOptions{ReportDeprecated: true})
+	if !v.Valid || v.Deprecated != nil {
+		test.Errorf("Expected valid data, without deprecations: %v", v)
+	}
+}
+
+func TestDecodeJSONDeprecated(test *testing.T) {
+	schema, err := parseRDL(nil, "colors.rdl", strings.NewReader(deprecationTestSchema), false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse: %v", err)
+	}
+	val, err := DecodeJSON(schema, "Paint", strings.NewReader(`{"color": "red", "colour": "red", "shade": "PALE"}`))
+	if err != nil {
+		test.Fatalf("Cannot decode data with deprecations: %v", err)
+	}
+	data := val.(map[string]interface{})
+	if data["colour"] != "red" || data["shade"] != "PALE" {
+		test.Errorf("Wrong data: %v", data)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+//
+// DiagnosticSeverity - how serious a Diagnostic is. The values are those of the Language Server Protocol.
+//
+type DiagnosticSeverity int
+
+//
+// DiagnosticSeverity constants
+//
+const (
+	_               DiagnosticSeverity = iota
+	SeverityError                      // the schema is invalid, and parsing stops
+	SeverityWarning                    // the schema is accepted, but should be changed
+	SeverityInfo                       // a remark about the schema
+)
+
+var namesDiagnosticSeverity = []string{
+	SeverityError:   "error",
+	SeverityWarning: "warning",
+	SeverityInfo:    "info",
+}
+
+func (s DiagnosticSeverity) String() string {
+	if s > 0 && int(s) < len(namesDiagnosticSeverity) {
+		return namesDiagnosticSeverity[s]
+	}
+	return fmt.Sprintf("DiagnosticSeverity(%d)", int(s))
+}
+
+//
+// MarshalJSON is defined for proper JSON encoding of a DiagnosticSeverity.
+//
+func (s DiagnosticSeverity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+//
+// UnmarshalJSON is defined for proper JSON decoding of a DiagnosticSeverity.
+//
+func (s *DiagnosticSeverity) UnmarshalJSON(b []byte) error {
+	var j string
+	err := json.Unmarshal(b, &j)
+	if err == nil {
+		for v, s2 := range namesDiagnosticSeverity {
+			if v > 0 && j == s2 {
+				*s = DiagnosticSeverity(v)
+				return nil
+			}
+		}
+		err = fmt.Errorf("Bad enum symbol for type DiagnosticSeverity: %s", j)
+	}
+	return err
+}
+
+//
+// Diagnostic codes produced by the parser
+//
+const (
+	DiagnosticSyntax       = "syntax"          // the input is malformed
+	DiagnosticInvalid      = "invalid"         // a definition is well formed, but not valid
+	DiagnosticUndefined    = "undefined-type"  // a type is referred to, but not defined
+	DiagnosticInclude      = "include"         // an included or used file cannot be read
+	DiagnosticRedefinition = "redefinition"    // a type is defined more than once
+	DiagnosticLegacy       = "legacy"          // legacy syntax is used
+	DiagnosticDeprecated   = "deprecated"      // a deprecated feature, or a deprecated type, is used
+	DiagnosticStray        = "stray-semicolon" // a ';' where no statement ends
+)
+
+//
+// Diagnostic codes produced by CheckRoutes
+//
+const (
+	DiagnosticAmbiguousRoute = "ambiguous-route" // two resources match some of the same paths
+	DiagnosticShadowedRoute  = "shadowed-route"  // an earlier resource matches all the paths of a later one
+	DiagnosticDuplicateParam = "duplicate-param" // a query parameter is named more than once
+	DiagnosticPathParam      = "path-param"      // a path parameter has no typed input
+	DiagnosticRoutePattern   = "route-pattern"   // a pattern does not compile, or differs from its type's
+)
+
+//
+// Diagnostic - an error or warning about a schema, at a span of its source
+//
+type Diagnostic struct {
+	Severity DiagnosticSeverity `json:"severity"`
+	Code     string             `json:"code"`
+	Message  string             `json:"message"`
+	Span
+}
+
+func (d *Diagnostic) String() string {
+	return RenderDiagnostic(d)
+}
+
+//
+// DiagnosticHandler - receives each Diagnostic as the parser produces it
+//
+type DiagnosticHandler func(d *Diagnostic)
+
+//
+// DiagnosticRenderer - formats a Diagnostic as text. TextRenderer is the standard one.
+//
+type DiagnosticRenderer interface {
+	Render(d *Diagnostic) string
+}
+
+//
+// TextRenderer - renders a Diagnostic as a single line, i.e. "Error(types.rdl:12): message". With
+// Context lines, the source lines around the diagnostic are shown as well, read from its file, and
+// with Color, ANSI color codes highlight the message and the line it is about.
+//
+type TextRenderer struct {
+	Color   bool
+	Context int
+}
+
+//
+// RenderDiagnostic - render the diagnostic as a single line, with the plain TextRenderer
+//
+func RenderDiagnostic(d *Diagnostic) string {
+	return (&TextRenderer{}).Render(d)
+}
+
+const (
+	ansiRed    = "\033[0;31m"
+	ansiYellow = "\033[0;33m"
+	ansiReset  = "\033[0;0m"
+)
+
+//
+// Render - format the diagnostic
+//
+func (r *TextRenderer) Render(d *Diagnostic) string {
+	severity := d.Severity.String()
+	prefix := strings.ToUpper(severity[:1]) + severity[1:]
+	color, reset := "", ""
+	if r.Color {
+		color, reset = ansiRed, ansiReset
+		if d.Severity != SeverityError {
+			color = ansiYellow
+		}
+	}
+	if d.File == "" {
+		return fmt.Sprintf("%s%s(line %d): %s%s", color, prefix, d.Line, d.Message, reset)
+	}
+	if r.Context > 0 {
+		if data, err := ioutil.ReadFile(d.File); err == nil {
+			lines := strings.Split(string(data), "\n")
+			line := d.Line - 1
+			begin := max(0, line-r.Context)
+			end := min(len(lines), line+r.Context)
+			tmp := ""
+			for i := begin; i < end; i++ {
+				if i == line {
+					tmp += fmt.Sprintf("%s%3d\t%v\n%s", color, i+1, lines[i], reset)
+				} else {
+					tmp += fmt.Sprintf("%3d\t%v\n", i+1, lines[i])
+				}
+			}
+			return fmt.Sprintf("%s%s (%s, line %d): %s%s\n%s", color, prefix, filepath.Base(d.File), d.Line, d.Message, reset, tmp)
+		}
+	}
+	return fmt.Sprintf("%s%s(%s:%d): %s%s", color, prefix, filepath.Base(d.File), d.Line, d.Message, reset)
+}
+
+//
+// ParseError - the error returned when a schema cannot be parsed. Its text is the rendering of the
+// Diagnostic that stopped the parser.
+//
+type ParseError struct {
+	Diagnostic *Diagnostic
+	Text       string
+}
+
+func (e *ParseError) Error() string {
+	return e.Text
+}
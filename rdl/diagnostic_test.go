@@ -0,0 +1,80 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"strings"
+	"testing"
+)
+
+func collectDiagnostics(source string, src string, options *ParseOptions) ([]*Diagnostic, error) {
+	var diags []*Diagnostic
+	if options == nil {
+		options = &ParseOptions{}
+	}
+	options.DiagnosticHandler = func(d *Diagnostic) {
+		diags = append(diags, d)
+	}
+	_, err := ParseRDLWithOptions(source, strings.NewReader(src), options)
+	return diags, err
+}
+
+func TestDiagnostics(test *testing.T) {
+	src := "name test;\n;\ntype Foo Struct {\n  integer x;\n  Bar y;\n}\n"
+	diags, err := collectDiagnostics("test.rdl", src, nil)
+	if len(diags) != 3 {
+		test.Fatalf("Expected 3 diagnostics, got %v", diags)
+	}
+	expected := []Diagnostic{
+		{Severity: SeverityWarning, Code: DiagnosticStray, Span: Span{File: "test.rdl", Line: 2, Column: 1, EndLine: 2, EndColumn: 2}},
+		{Severity: SeverityWarning, Code: DiagnosticLegacy, Span: Span{File: "test.rdl", Line: 4, Column: 3, EndLine: 4, EndColumn: 10}},
+		{Severity: SeverityError, Code: DiagnosticUndefined, Span: Span{File: "test.rdl", Line: 5, Column: 3, EndLine: 5, EndColumn: 6}},
+	}
+	for i, d := range diags {
+		e := expected[i]
+		e.Message = d.Message
+		if *d != e {
+			test.Errorf("Expected diagnostic %v, got %v", e, *d)
+		}
+	}
+	perr, ok := err.(*ParseError)
+	if !ok || perr.Diagnostic != diags[2] || err.Error() != "Error(test.rdl:5): No such type: Bar" {
+		test.Errorf("Expected the last diagnostic as a ParseError, got %v", err)
+	}
+
+	//warnings are suppressed with NoWarn, and are errors when pedantic
+	if diags, _ = collectDiagnostics("test.rdl", src, &ParseOptions{NoWarn: true}); len(diags) != 1 {
+		test.Errorf("Expected only the error without warnings, got %v", diags)
+	}
+	if diags, _ = collectDiagnostics("test.rdl", "type Foo Struct { integer x; }", &ParseOptions{Pedantic: true}); len(diags) != 1 || diags[0].Severity != SeverityError {
+		test.Errorf("Expected an error for legacy syntax when pedantic, got %v", diags)
+	}
+}
+
+func TestIncludeDiagnostics(test *testing.T) {
+	diags, err := collectDiagnostics("../testdata/test.rdl", "name test;\ninclude \"unterminated_struct.rdl\";\n", nil)
+	if err == nil || len(diags) != 1 || !strings.HasSuffix(diags[0].File, "unterminated_struct.rdl") || diags[0].Code != DiagnosticSyntax {
+		test.Errorf("Expected an error in the included file, got %v (%v)", diags, err)
+	}
+	diags, err = collectDiagnostics("../testdata/test.rdl", "name test;\nuse \"nonexistent.rdl\";\n", nil)
+	if err == nil || len(diags) != 1 || diags[0].Code != DiagnosticInclude || diags[0].Line != 2 || diags[0].File != "../testdata/test.rdl" {
+		test.Errorf("Expected an error for the missing file, got %v (%v)", diags, err)
+	}
+}
+
+func TestRenderDiagnostic(test *testing.T) {
+	d := &Diagnostic{Severity: SeverityWarning, Code: DiagnosticStray, Message: "stray ';' character", Span: Span{File: "../testdata/names.rdl", Line: 3, Column: 1}}
+	if s := RenderDiagnostic(d); s != "Warning(names.rdl:3): stray ';' character" {
+		test.Errorf("Unexpected rendering: %q", s)
+	}
+	s := (&TextRenderer{Color: true, Context: 1}).Render(d)
+	expected := "\033[0;33mWarning (names.rdl, line 3): stray ';' character\033[0;0m\n  2\t\n\033[0;33m  3\ttype CompoundName String (pattern=\"({SimpleName}\\\\.)*{SimpleName}\");\n\033[0;0m"
+	if s != expected {
+		test.Errorf("Unexpected rendering with context: %q", s)
+	}
+	d.File = ""
+	if s = RenderDiagnostic(d); s != "Warning(line 3): stray ';' character" {
+		test.Errorf("Unexpected rendering without a file: %q", s)
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//
+// DiffKind - the kind of a Difference between two values.
+//
+type DiffKind int
+
+//
+// DiffKind constants
+//
+const (
+	_           DiffKind = iota
+	Added                // the value is only present in the second value
+	Removed              // the value is only present in the first value
+	Changed              // the values are of the same type, but not equal
+	TypeChanged          // the values are of different types
+)
+
+var namesDiffKind = []string{
+	Added:       "added",
+	Removed:     "removed",
+	Changed:     "changed",
+	TypeChanged: "type changed",
+}
+
+func (k DiffKind) String() string {
+	if k > 0 && int(k) < len(namesDiffKind) {
+		return namesDiffKind[k]
+	}
+	return fmt.Sprintf("DiffKind(%d)", int(k))
+}
+
+//
+// Difference - a single difference found by Diff. The path addresses the differing value within the
+// compared values: field names and map keys separated by dots, and bracketed array indices, for
+// example "items[2].x". A map key with characters other than letters, digits, and underscores is
+// quoted in brackets instead, for example "labels[\"a.b\"]". The path of the values themselves is
+// empty. A is nil when the value was added, and B when it was removed.
+//
+type Difference struct {
+	Path string
+	Kind DiffKind
+	A    interface{}
+	B    interface{}
+}
+
+func (d Difference) String() string {
+	path := d.Path
+	if path == "" {
+		path = "."
+	}
+	switch d.Kind {
+	case Added:
+		return fmt.Sprintf("%s: added %v", path, d.B)
+	case Removed:
+		return fmt.Sprintf("%s: removed %v", path, d.A)
+	case TypeChanged:
+		return fmt.Sprintf("%s: type changed from %s %v to %s %v", path, diffTypeName(diffNormalize(d.A)), d.A, diffTypeName(diffNormalize(d.B)), d.B)
+	default:
+		return fmt.Sprintf("%s: changed from %v to %v", path, d.A, d.B)
+	}
+}
+
+//
+// Diff - compare two values, and return where they differ, in the order found. The values can be
+// generic (as decoded from JSON or TBin), RDL types (Struct, Array, Symbol, and so on), or generated
+// model types, in any combination: structs and maps are compared by field name or key, arrays
+// element by element, and unions by their variant value. As for an optional field, a null field
+// or map value is the same as a missing one. Numbers of different types differ in type, as they
+// do for Equal. An empty result means the values are equal.
+//
+func Diff(a interface{}, b interface{}) []Difference {
+	var diffs []Difference
+	diffValues("", a, b, &diffs)
+	return diffs
+}
+
+//a diffRecord is the normalized form of a struct or a map, with the keys in a stable order
+type diffRecord struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (r *diffRecord) add(key string, val interface{}) {
+	if diffIsNull(val) {
+		return
+	}
+	if _, ok := r.values[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.values[key] = diffDeref(val)
+}
+
+func diffValues(path string, a interface{}, b interface{}, diffs *[]Difference) {
+	a, b = diffDeref(a), diffDeref(b)
+	na, nb := diffNormalize(a), diffNormalize(b)
+	if na == nil && nb == nil {
+		return
+	}
+	if diffTypeName(na) != diffTypeName(nb) {
+		*diffs = append(*diffs, Difference{Path: path, Kind: TypeChanged, A: a, B: b})
+		return
+	}
+	switch va := na.(type) {
+	case *diffRecord:
+		vb := nb.(*diffRecord)
+		for _, k := range va.keys {
+			if vbk, ok := vb.values[k]; ok {
+				diffValues(diffPath(path, k), va.values[k], vbk, diffs)
+			} else {
+				*diffs = append(*diffs, Difference{Path: diffPath(path, k), Kind: Removed, A: va.values[k]})
+			}
+		}
+		for _, k := range vb.keys {
+			if _, ok := va.values[k]; !ok {
+				*diffs = append(*diffs, Difference{Path: diffPath(path, k), Kind: Added, B: vb.values[k]})
+			}
+		}
+	case []interface{}:
+		vb := nb.([]interface{})
+		for i, item := range va {
+			ipath := fmt.Sprintf("%s[%d]", path, i)
+			if i < len(vb) {
+				diffValues(ipath, item, vb[i], diffs)
+			} else {
+				*diffs = append(*diffs, Difference{Path: ipath, Kind: Removed, A: diffDeref(item)})
+			}
+		}
+		for i := len(va); i < len(vb); i++ {
+			*diffs = append(*diffs, Difference{Path: fmt.Sprintf("%s[%d]", path, i), Kind: Added, B: diffDeref(vb[i])})
+		}
+	default:
+		if !diffLeafEqual(na, nb) {
+			*diffs = append(*diffs, Difference{Path: path, Kind: Changed, A: a, B: b})
+		}
+	}
+}
+
+//diffPath appends the key to the path, quoted if it is not a plain word, so that every path is
+//unambiguous
+func diffPath(path string, key string) string {
+	if !diffPlainKey(key) {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func diffPlainKey(key string) bool {
+	for _, c := range key {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return key != ""
+}
+
+func diffLeafEqual(a interface{}, b interface{}) bool {
+	switch a.(type) {
+	case UUID, Timestamp, Symbol, bool, string, []byte, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return Equal(a, b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+//diffNormalize returns nil for a null value, a *diffRecord for a struct or map, an []interface{} for
+//an array, and otherwise the value itself, with pointers followed, unions unwrapped, and enums as
+//their symbol strings.
+func diffNormalize(x interface{}) interface{} {
+	switch v := x.(type) {
+	case nil:
+		return nil
+	case UUID, Timestamp, []byte:
+		return x
+	case Struct:
+		r := &diffRecord{values: make(map[string]interface{}, len(v))}
+		for _, k := range sortedKeys(reflect.ValueOf(v)) {
+			r.add(fmt.Sprint(k.Interface()), v[k.Interface().(Symbol)])
+		}
+		return r
+	case map[string]interface{}:
+		r := &diffRecord{values: make(map[string]interface{}, len(v))}
+		for _, k := range sortedKeys(reflect.ValueOf(v)) {
+			r.add(k.String(), v[k.String()])
+		}
+		return r
+	case []interface{}:
+		return v
+	case Array:
+		return []interface{}(v)
+	}
+	return diffNormalizeReflect(reflect.ValueOf(x))
+}
+
+func diffNormalizeReflect(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.CanInterface() {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		if t.NumField() > 0 && t.Field(0).Tag.Get("rdl") == "union" {
+			n := int(v.Field(0).Int())
+			if n <= 0 || n >= t.NumField() {
+				return nil
+			}
+			return diffNormalize(v.Field(n).Interface())
+		}
+		if _, ok := v.Interface().(Timestamp); ok {
+			return v.Interface()
+		}
+		r := &diffRecord{values: make(map[string]interface{}, t.NumField())}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue //unexported
+			}
+			name := f.Name
+			if tag := f.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				if n := strings.Split(tag, ",")[0]; n != "" {
+					name = n
+				}
+			}
+			r.add(name, v.Field(i).Interface())
+		}
+		return r
+	case reflect.Map:
+		r := &diffRecord{values: make(map[string]interface{}, v.Len())}
+		for _, k := range sortedKeys(v) {
+			r.add(fmt.Sprint(k.Interface()), v.MapIndex(k).Interface())
+		}
+		return r
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			if _, ok := v.Interface().(UUID); ok {
+				return v.Interface()
+			}
+			return v.Bytes()
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).Interface()
+		}
+		return items
+	}
+	if v.Type().PkgPath() == "" {
+		return v.Interface()
+	}
+	//a named type, such as an enum or a string subtype, compares as the type it is based on
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			return s.String() //an enum
+		}
+	case reflect.String:
+		if _, ok := v.Interface().(Symbol); ok {
+			return v.Interface()
+		}
+	}
+	if bt, ok := diffBaseTypes[v.Kind()]; ok {
+		return v.Convert(bt).Interface()
+	}
+	return v.Interface()
+}
+
+var diffBaseTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.String:  reflect.TypeOf(""),
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+}
+
+//diffDeref follows pointers, so that differences report values rather than addresses
+func diffDeref(x interface{}) interface{} {
+	v := reflect.ValueOf(x)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return x
+	}
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+//diffIsNull is true for nil, and for a nil pointer, which is how a missing optional field is represented
+func diffIsNull(x interface{}) bool {
+	if x == nil {
+		return true
+	}
+	v := reflect.ValueOf(x)
+	return (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+}
+
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+//diffTypeName names the type of a normalized value, so that structs and maps, and arrays of any
+//kind, are considered to be of the same type.
+func diffTypeName(x interface{}) string {
+	switch v := x.(type) {
+	case nil:
+		return "Null"
+	case *diffRecord:
+		return "Struct"
+	case []interface{}:
+		return "Array"
+	case UUID:
+		return "UUID"
+	case Timestamp:
+		return "Timestamp"
+	case Symbol:
+		return "Symbol"
+	case []byte:
+		return "Bytes"
+	default:
+		kind := reflect.TypeOf(v).Kind().String()
+		return strings.ToUpper(kind[:1]) + kind[1:]
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"strings"
+	"testing"
+)
+
+func diffStrings(a interface{}, b interface{}) string {
+	var lines []string
+	for _, d := range Diff(a, b) {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func checkDiff(test *testing.T, a interface{}, b interface{}, expected ...string) {
+	if s := diffStrings(a, b); s != strings.Join(expected, "\n") {
+		test.Errorf("Diff(%v, %v) expected:\n%s\nbut got:\n%s", a, b, strings.Join(expected, "\n"), s)
+	}
+}
+
+type diffPoint struct {
+	X int32 `json:"x"`
+	Y int32 `json:"y"`
+}
+
+type diffColor int
+
+var namesDiffColor = []string{1: "RED", 2: "GREEN"}
+
+func (c diffColor) String() string {
+	return namesDiffColor[c]
+}
+
+type diffShape struct {
+	Name   SimpleName   `json:"name"`
+	Points []*diffPoint `json:"points"`
+	Color  diffColor    `json:"color"`
+	Label  *string      `json:"label,omitempty" rdl:"optional"`
+}
+
+type diffUnion struct {
+	Variant int `rdl:"union"`
+	Point   *diffPoint
+	Name    *string
+}
+
+type SimpleName string
+
+func TestDiff(test *testing.T) {
+	checkDiff(test, 1, 1)
+	checkDiff(test, nil, nil)
+	checkDiff(test, int32(1), int32(2), ".: changed from 1 to 2")
+	checkDiff(test, int32(1), int64(1), ".: type changed from Int32 1 to Int64 1")
+	checkDiff(test, "a", nil, ".: type changed from String a to Null <nil>")
+
+	a := map[string]interface{}{"x": int32(1), "y": "same", "gone": true, "items": []interface{}{int32(1), int32(2), int32(3)}}
+	b := map[string]interface{}{"x": int32(2), "y": "same", "new": Symbol("s"), "items": []interface{}{int32(1), "two"}}
+	checkDiff(test, a, b,
+		"gone: removed true",
+		"items[1]: type changed from Int32 2 to String two",
+		"items[2]: removed 3",
+		"x: changed from 1 to 2",
+		"new: added s")
+
+	//structs and maps are compared alike, and a null value is a missing one
+	checkDiff(test, Struct{"x": int32(1), "z": nil}, map[string]interface{}{"x": int32(1)})
+	checkDiff(test, Struct{"n": map[int32]string{1: "one"}}, Struct{"n": map[int32]string{1: "uno"}}, "n.1: changed from one to uno")
+	checkDiff(test, Array{ParseUUID("01020000-0000-0000-0000-000000000000")}, []interface{}{ParseUUID("01030000-0000-0000-0000-000000000000")}, "[0]: changed from 01020000-0000-0000-0000-000000000000 to 01030000-0000-0000-0000-000000000000")
+
+	//model types compare with generic data, with enums as their symbols, and subtypes as their base type
+	label := "tri"
+	shape := &diffShape{Name: "t", Points: []*diffPoint{{1, 2}, {3, 4}}, Color: 1, Label: &label}
+	generic := map[string]interface{}{
+		"name":   "t",
+		"points": []interface{}{map[string]interface{}{"x": int32(1), "y": int32(2)}, map[string]interface{}{"x": int32(3), "y": int32(5)}},
+		"color":  "GREEN",
+	}
+	checkDiff(test, shape, generic,
+		"points[1].y: changed from 4 to 5",
+		"color: changed from RED to GREEN",
+		"label: removed tri")
+	shape2 := &diffShape{Name: "t", Points: []*diffPoint{{1, 2}, {3, 4}}, Color: 1, Label: &label}
+	checkDiff(test, shape, shape2)
+
+	//unions are compared by their variant value
+	checkDiff(test, &diffUnion{Variant: 1, Point: &diffPoint{1, 2}}, map[string]interface{}{"x": int32(1), "y": int32(3)}, "y: changed from 2 to 3")
+	checkDiff(test, &diffUnion{Variant: 2, Name: &label}, "tri")
+
+	//map keys that are not plain words are quoted, so that paths are not ambiguous
+	checkDiff(test, map[string]interface{}{"a.b": int32(1), "a": map[string]interface{}{"b": int32(1)}, "": "x", "2d": true},
+		map[string]interface{}{"a.b": int32(2), "a": map[string]interface{}{"b": int32(2)}, "": "y", "2d": false},
+		`[""]: changed from x to y`,
+		"2d: changed from true to false",
+		"a.b: changed from 1 to 2",
+		`["a.b"]: changed from 1 to 2`)
+	checkDiff(test, map[string]interface{}{"m": map[string]interface{}{"x]": int8(1)}}, map[string]interface{}{"m": map[string]interface{}{"x]": int16(1)}},
+		`m["x]"]: type changed from Int8 1 to Int16 1`)
+
+	d := Diff(a, b)[0]
+	if d.Kind != Removed || d.Path != "gone" || d.A != true || d.B != nil || d.Kind.String() != "removed" {
+		test.Errorf("Unexpected difference: %#v", d)
+	}
+}
@@ -0,0 +1,694 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ardielle/ardielle-go/rdl"
+	"github.com/ardielle/ardielle-go/tbin"
+)
+
+//
+// Options - control the code generated for a schema
+//
+type Options struct {
+	Package   string //the Go package of the generated code. The default is the schema name
+	Precise   bool   //generate String, Number, and other subtypes of the base types as named Go types, rather than as their base types
+	SymbolSet bool   //give enum types a SymbolSet method, with which TBin encodes them as enums rather than as integers
+	TBin      bool   //also generate TBinMarshallable and TBinUnmarshallable implementations. This requires Precise
+}
+
+//
+// Generate - write Go model types for the schema to out: a struct for each struct type, with a
+// constructor, Init, Validate, and JSON unmarshalling that validates, an int type with constants for
+// each enum type, a wrapper struct for each union type, and a named type for each array and map type.
+// The code is in the style of the rdl tool's Go model generator, so that code generated with it by
+// earlier versions can be regenerated.
+//
+func Generate(out io.Writer, schema *rdl.Schema, opts *Options) error {
+	g := newGenerator(schema, opts)
+	for _, t := range schema.Types {
+		g.generateType(t)
+		if g.err != nil {
+			return g.err
+		}
+	}
+	var src bytes.Buffer
+	src.WriteString("//\n")
+	fmt.Fprintf(&src, "// This file generated by golang.Generate from the %q schema. Do not edit.\n", schema.Name)
+	src.WriteString("//\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", g.pkg)
+	body := g.buf.String()
+	src.WriteString("import (\n\t\"encoding/json\"\n\t\"fmt\"\n")
+	if g.pkg != "rdl" && regexp.MustCompile(`(^|[^\w.])rdl\.`).MatchString(body) {
+		src.WriteString("\n\t\"github.com/ardielle/ardielle-go/rdl\"\n")
+	}
+	src.WriteString(")\n\n")
+	src.WriteString("var _ = json.Marshal\n")
+	src.WriteString("var _ = fmt.Printf\n")
+	src.WriteString(body)
+	_, err := out.Write(src.Bytes())
+	return err
+}
+
+//
+// GenerateFiles - write the Go model for the schema into the directory dir, as name_model.go, and
+// with the TBin option, the TBin marshalling code for it as name_tbin.go. The paths of the written
+// files are returned.
+//
+func GenerateFiles(dir string, name string, schema *rdl.Schema, opts *Options) ([]string, error) {
+	if opts != nil && opts.TBin && !opts.Precise {
+		return nil, fmt.Errorf("The TBin option requires the Precise option")
+	}
+	var model bytes.Buffer
+	if err := Generate(&model, schema, opts); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, name+"_model.go")
+	if err := ioutil.WriteFile(path, model.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if opts != nil && opts.TBin {
+		var code bytes.Buffer
+		if err := tbin.GenerateMarshalCode(&code, schema, packageName(schema, opts)); err != nil {
+			return paths, err
+		}
+		path = filepath.Join(dir, name+"_tbin.go")
+		if err := ioutil.WriteFile(path, code.Bytes(), 0644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func packageName(schema *rdl.Schema, opts *Options) string {
+	if opts != nil && opts.Package != "" {
+		return opts.Package
+	}
+	return strings.ToLower(string(schema.Name))
+}
+
+type generator struct {
+	schema *rdl.Schema
+	reg    rdl.TypeRegistry
+	pkg    string
+	opts   Options
+	buf    bytes.Buffer
+	err    error
+}
+
+func newGenerator(schema *rdl.Schema, opts *Options) *generator {
+	g := &generator{
+		schema: schema,
+		reg:    rdl.NewTypeRegistry(schema),
+		pkg:    packageName(schema, opts),
+	}
+	if opts != nil {
+		g.opts = *opts
+	}
+	return g
+}
+
+var goPrimitives = map[rdl.BaseType]string{
+	rdl.BaseTypeBool:      "bool",
+	rdl.BaseTypeInt8:      "int8",
+	rdl.BaseTypeInt16:     "int16",
+	rdl.BaseTypeInt32:     "int32",
+	rdl.BaseTypeInt64:     "int64",
+	rdl.BaseTypeFloat32:   "float32",
+	rdl.BaseTypeFloat64:   "float64",
+	rdl.BaseTypeBytes:     "[]byte",
+	rdl.BaseTypeString:    "string",
+	rdl.BaseTypeTimestamp: "Timestamp",
+	rdl.BaseTypeSymbol:    "Symbol",
+	rdl.BaseTypeUUID:      "UUID",
+}
+
+func (g *generator) line(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+func (g *generator) fail(format string, args ...interface{}) {
+	if g.err == nil {
+		g.err = fmt.Errorf(format, args...)
+	}
+}
+
+//aligned writes lines of tab separated cells, aligned into columns as gofmt does
+func (g *generator) aligned(lines []string) {
+	w := tabwriter.NewWriter(&g.buf, 0, 8, 1, ' ', tabwriter.TabIndent)
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+	w.Flush()
+}
+
+func (g *generator) comment(name string, comment string) {
+	g.line("")
+	g.line("//")
+	if comment == "" {
+		g.line("// %s -", name)
+	} else {
+		g.line("// %s - %s", name, strings.Replace(comment, "\n", "\n// ", -1))
+	}
+	g.line("//")
+}
+
+//qualified returns the identifier of the rdl package as referenced from the generated package
+func (g *generator) qualified(ident string) string {
+	if g.pkg == "rdl" {
+		return ident
+	}
+	return "rdl." + ident
+}
+
+func goName(name string) string {
+	return strings.ToUpper(name[0:1]) + name[1:]
+}
+
+//isPrimitive is true for the base types that are not generated as named types without the Precise option
+func isPrimitive(bt rdl.BaseType) bool {
+	_, ok := goPrimitives[bt]
+	return ok
+}
+
+//goType returns the Go type used to refer to a value of the type, i.e. "int32", "[]*Point", or "Options".
+//The keys and items apply when the reference is to the Array or Map base type, as with inline field declarations.
+func (g *generator) goType(tref rdl.TypeRef, keys rdl.TypeRef, items rdl.TypeRef) string {
+	t := g.reg.FindType(tref)
+	if t == nil {
+		g.fail("Cannot generate code for unknown type '%s'", tref)
+		return "interface{}"
+	}
+	bt := g.reg.BaseType(t)
+	if g.reg.IsBaseTypeName(tref) {
+		switch bt {
+		case rdl.BaseTypeArray:
+			return "[]" + g.goType(defaultRef(items, "Any"), "", "")
+		case rdl.BaseTypeMap:
+			return "map[" + g.goType(defaultRef(keys, "String"), "", "") + "]" + g.goType(defaultRef(items, "Any"), "", "")
+		case rdl.BaseTypeStruct:
+			return g.qualified("Struct")
+		case rdl.BaseTypeAny:
+			return "interface{}"
+		case rdl.BaseTypeEnum, rdl.BaseTypeUnion:
+			g.fail("Cannot generate code for an undefined %s", bt)
+			return "interface{}"
+		}
+		return g.primitive(bt)
+	}
+	switch bt {
+	case rdl.BaseTypeStruct, rdl.BaseTypeUnion:
+		return "*" + string(tref)
+	case rdl.BaseTypeAny:
+		return "interface{}"
+	}
+	if isPrimitive(bt) && !g.opts.Precise {
+		return g.primitive(bt)
+	}
+	return string(tref)
+}
+
+func (g *generator) primitive(bt rdl.BaseType) string {
+	switch bt {
+	case rdl.BaseTypeTimestamp, rdl.BaseTypeSymbol, rdl.BaseTypeUUID:
+		return g.qualified(goPrimitives[bt])
+	}
+	return goPrimitives[bt]
+}
+
+//valueType is the Go type of a value of the type, which for structs and unions is not a pointer
+func (g *generator) valueType(tref rdl.TypeRef) string {
+	return strings.TrimPrefix(g.goType(tref, "", ""), "*")
+}
+
+func defaultRef(tref rdl.TypeRef, dflt rdl.TypeRef) rdl.TypeRef {
+	if tref == "" {
+		return dflt
+	}
+	return tref
+}
+
+func (g *generator) baseType(tref rdl.TypeRef) rdl.BaseType {
+	t := g.reg.FindType(tref)
+	if t == nil {
+		return rdl.BaseTypeAny
+	}
+	return g.reg.BaseType(t)
+}
+
+func (g *generator) generateType(t *rdl.Type) {
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		g.generateStruct(t.StructTypeDef)
+	case rdl.TypeVariantEnumTypeDef:
+		g.generateEnum(t.EnumTypeDef)
+	case rdl.TypeVariantUnionTypeDef:
+		g.generateUnion(t.UnionTypeDef)
+	case rdl.TypeVariantArrayTypeDef:
+		td := t.ArrayTypeDef
+		g.comment(string(td.Name), td.Comment)
+		if td.Type == "Array" {
+			g.line("type %s []%s", td.Name, g.goType(defaultRef(td.Items, "Any"), "", ""))
+		} else {
+			g.line("type %s %s", td.Name, g.valueType(td.Type))
+		}
+	case rdl.TypeVariantMapTypeDef:
+		td := t.MapTypeDef
+		g.comment(string(td.Name), td.Comment)
+		if td.Type == "Map" {
+			g.line("type %s map[%s]%s", td.Name, g.goType(defaultRef(td.Keys, "String"), "", ""), g.goType(defaultRef(td.Items, "Any"), "", ""))
+		} else {
+			g.line("type %s %s", td.Name, g.valueType(td.Type))
+		}
+	case rdl.TypeVariantBaseType:
+		//the base types are provided by the rdl package
+	default:
+		name, super, comment := rdl.TypeInfo(t)
+		if isPrimitive(g.reg.BaseType(t)) && !g.opts.Precise {
+			return
+		}
+		g.comment(string(name), comment)
+		g.line("type %s %s", name, g.valueType(super))
+	}
+}
+
+func (g *generator) generateEnum(td *rdl.EnumTypeDef) {
+	name := string(td.Name)
+	names := "names" + name
+	g.comment(name, td.Comment)
+	g.line("type %s int", name)
+	g.line("")
+	g.line("//")
+	g.line("// %s constants", name)
+	g.line("//")
+	g.line("const (")
+	g.line("\t_ %s = iota", name)
+	for _, elem := range td.Elements {
+		g.line("\t%s", elem.Symbol)
+	}
+	g.line(")")
+	g.line("")
+	g.line("var %s = []string{", names)
+	var syms []string
+	for _, elem := range td.Elements {
+		syms = append(syms, fmt.Sprintf("\t%s:\t%q,", elem.Symbol, elem.Symbol))
+	}
+	g.aligned(syms)
+	g.line("}")
+	g.line("")
+	g.line("//")
+	g.line("// New%s - return a string representation of the enum", name)
+	g.line("//")
+	g.line("func New%s(init ...interface{}) %s {", name, name)
+	g.line("\tif len(init) == 1 {")
+	g.line("\t\tswitch v := init[0].(type) {")
+	g.line("\t\tcase %s:", name)
+	g.line("\t\t\treturn v")
+	g.line("\t\tcase int:")
+	g.line("\t\t\treturn %s(v)", name)
+	g.line("\t\tcase int32:")
+	g.line("\t\t\treturn %s(v)", name)
+	g.line("\t\tcase string:")
+	g.line("\t\t\tfor i, s := range %s {", names)
+	g.line("\t\t\t\tif s == v {")
+	g.line("\t\t\t\t\treturn %s(i)", name)
+	g.line("\t\t\t\t}")
+	g.line("\t\t\t}")
+	g.line("\t\tdefault:")
+	g.line("\t\t\tpanic(\"Bad init value for %s enum\")", name)
+	g.line("\t\t}")
+	g.line("\t}")
+	g.line("\treturn %s(0) //default to the first enum value", name)
+	g.line("}")
+	g.line("")
+	g.line("//")
+	g.line("// String - return a string representation of the enum")
+	g.line("//")
+	g.line("func (e %s) String() string {", name)
+	g.line("\treturn %s[e]", names)
+	g.line("}")
+	if g.opts.SymbolSet {
+		g.line("")
+		g.line("//")
+		g.line("// SymbolSet - return an array of all valid string representations (symbols) of the enum")
+		g.line("//")
+		g.line("func (e %s) SymbolSet() []string {", name)
+		g.line("\treturn %s", names)
+		g.line("}")
+	}
+	g.line("")
+	g.line("//")
+	g.line("// MarshalJSON is defined for proper JSON encoding of a %s", name)
+	g.line("//")
+	g.line("func (e %s) MarshalJSON() ([]byte, error) {", name)
+	g.line("\treturn json.Marshal(e.String())")
+	g.line("}")
+	g.line("")
+	g.line("//")
+	g.line("// UnmarshalJSON is defined for proper JSON decoding of a %s", name)
+	g.line("//")
+	g.line("func (e *%s) UnmarshalJSON(b []byte) error {", name)
+	g.line("\tvar j string")
+	g.line("\terr := json.Unmarshal(b, &j)")
+	g.line("\tif err == nil {")
+	g.line("\t\ts := string(j)")
+	g.line("\t\tfor v, s2 := range %s {", names)
+	g.line("\t\t\tif s == s2 {")
+	g.line("\t\t\t\t*e = %s(v)", name)
+	g.line("\t\t\t\treturn nil")
+	g.line("\t\t\t}")
+	g.line("\t\t}")
+	g.line("\t\terr = fmt.Errorf(\"Bad enum symbol for type %s: %%s\", s)", name)
+	g.line("\t}")
+	g.line("\treturn err")
+	g.line("}")
+}
+
+//structFields returns the fields of a struct type, including those of the types it derives from
+func (g *generator) structFields(td *rdl.StructTypeDef, depth int) []*rdl.StructFieldDef {
+	var fields []*rdl.StructFieldDef
+	if td.Type != "Struct" {
+		super := g.reg.FindType(td.Type)
+		if super == nil || super.Variant != rdl.TypeVariantStructTypeDef || depth > len(g.schema.Types) {
+			g.fail("Cannot generate code for '%s', its supertype is not a struct", td.Name)
+			return nil
+		}
+		fields = g.structFields(super.StructTypeDef, depth+1)
+	}
+	return append(fields, td.Fields...)
+}
+
+//fieldType is the Go type of a struct field. As for the TBin code generator, optional fields of
+//the numeric, Bool, and Timestamp types are pointers, so that they can be omitted.
+func (g *generator) fieldType(f *rdl.StructFieldDef) string {
+	gtype := g.goType(f.Type, f.Keys, f.Items)
+	if f.Optional && f.Default == nil {
+		switch g.baseType(f.Type) {
+		case rdl.BaseTypeBool, rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64,
+			rdl.BaseTypeFloat32, rdl.BaseTypeFloat64, rdl.BaseTypeTimestamp:
+			gtype = "*" + gtype
+		}
+	}
+	return gtype
+}
+
+func fieldTag(f *rdl.StructFieldDef) string {
+	switch {
+	case f.Default != nil:
+		return fmt.Sprintf("`json:\"%s,omitempty\" rdl:\"default=%v\"`", f.Name, f.Default)
+	case f.Optional:
+		return fmt.Sprintf("`json:\"%s,omitempty\" rdl:\"optional\"`", f.Name)
+	}
+	return fmt.Sprintf("`json:\"%s\"`", f.Name)
+}
+
+//fieldInit returns the statement that initializes a field in Init, if there is one
+func (g *generator) fieldInit(f *rdl.StructFieldDef, gtype string) string {
+	bt := g.baseType(f.Type)
+	if f.Default != nil {
+		switch bt {
+		case rdl.BaseTypeString:
+			return fmt.Sprintf("if pTypeDef.%s == \"\" {\n\t\tpTypeDef.%s = %q\n\t}", goName(string(f.Name)), goName(string(f.Name)), fmt.Sprint(f.Default))
+		case rdl.BaseTypeEnum:
+			return fmt.Sprintf("if pTypeDef.%s == 0 {\n\t\tpTypeDef.%s = New%s(%q)\n\t}", goName(string(f.Name)), goName(string(f.Name)), f.Type, fmt.Sprint(f.Default))
+		case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64, rdl.BaseTypeFloat32, rdl.BaseTypeFloat64:
+			return fmt.Sprintf("if pTypeDef.%s == 0 {\n\t\tpTypeDef.%s = %v\n\t}", goName(string(f.Name)), goName(string(f.Name)), f.Default)
+		}
+		return ""
+	}
+	if f.Optional {
+		return ""
+	}
+	name := goName(string(f.Name))
+	var val string
+	switch bt {
+	case rdl.BaseTypeArray:
+		val = "make(" + gtype + ", 0)"
+	case rdl.BaseTypeMap:
+		val = "make(" + gtype + ")"
+	case rdl.BaseTypeStruct:
+		t := g.reg.FindType(f.Type)
+		if t == nil || t.Variant != rdl.TypeVariantStructTypeDef || g.reg.IsBaseTypeName(f.Type) {
+			if gtype != g.qualified("Struct") {
+				return ""
+			}
+			val = "make(" + gtype + ")"
+		} else {
+			val = "New" + string(f.Type) + "()"
+		}
+	default:
+		return ""
+	}
+	return fmt.Sprintf("if pTypeDef.%s == nil {\n\t\tpTypeDef.%s = %s\n\t}", name, name, val)
+}
+
+//fieldMissing returns the condition that is true when a required field has no value, if it can be told
+func (g *generator) fieldMissing(f *rdl.StructFieldDef) string {
+	if f.Optional || f.Default != nil {
+		return ""
+	}
+	name := "pTypeDef." + goName(string(f.Name))
+	switch g.baseType(f.Type) {
+	case rdl.BaseTypeString, rdl.BaseTypeSymbol:
+		return name + ` == ""`
+	case rdl.BaseTypeTimestamp:
+		return name + ".IsZero()"
+	case rdl.BaseTypeBool, rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64,
+		rdl.BaseTypeFloat32, rdl.BaseTypeFloat64, rdl.BaseTypeEnum:
+		return ""
+	}
+	return name + " == nil"
+}
+
+func (g *generator) generateStruct(td *rdl.StructTypeDef) {
+	name := string(td.Name)
+	fields := g.structFields(td, 0)
+	types := make([]string, len(fields))
+	var decls, inits []string
+	for i, f := range fields {
+		types[i] = g.fieldType(f)
+		decl := fmt.Sprintf("\t%s\t%s\t%s", goName(string(f.Name)), types[i], fieldTag(f))
+		if f.Comment != "" {
+			decl += "\t// " + f.Comment
+		}
+		decls = append(decls, decl)
+		if init := g.fieldInit(f, types[i]); init != "" {
+			inits = append(inits, init)
+		}
+	}
+	g.comment(name, td.Comment)
+	g.line("type %s struct {", name)
+	g.aligned(decls)
+	g.line("}")
+	g.line("")
+	g.line("//")
+	g.line("// New%s - creates an initialized %s instance, returns a pointer to it", name, name)
+	g.line("//")
+	g.line("func New%s(init ...*%s) *%s {", name, name, name)
+	g.line("\tvar o *%s", name)
+	g.line("\tif len(init) == 1 {")
+	g.line("\t\to = init[0]")
+	g.line("\t} else {")
+	g.line("\t\to = new(%s)", name)
+	g.line("\t}")
+	if len(inits) > 0 {
+		g.line("\treturn o.Init()")
+	} else {
+		g.line("\treturn o")
+	}
+	g.line("}")
+	if len(inits) > 0 {
+		g.line("")
+		g.line("//")
+		g.line("// Init - sets up the instance according to its default field values, if any")
+		g.line("//")
+		g.line("func (pTypeDef *%s) Init() *%s {", name, name)
+		for _, init := range inits {
+			g.line("\t%s", init)
+		}
+		g.line("\treturn pTypeDef")
+		g.line("}")
+	}
+	g.line("")
+	g.line("type raw%s %s", name, name)
+	g.line("")
+	g.line("//")
+	g.line("// UnmarshalJSON is defined for proper JSON decoding of a %s", name)
+	g.line("//")
+	g.line("func (pTypeDef *%s) UnmarshalJSON(b []byte) error {", name)
+	g.line("\tvar r raw%s", name)
+	g.line("\terr := json.Unmarshal(b, &r)")
+	g.line("\tif err == nil {")
+	g.line("\t\to := %s(r)", name)
+	if len(inits) > 0 {
+		g.line("\t\t*pTypeDef = *((&o).Init())")
+	} else {
+		g.line("\t\t*pTypeDef = o")
+	}
+	g.line("\t\terr = pTypeDef.Validate()")
+	g.line("\t}")
+	g.line("\treturn err")
+	g.line("}")
+	g.line("")
+	g.line("//")
+	g.line("// Validate - checks for missing required fields, etc")
+	g.line("//")
+	g.line("func (pTypeDef *%s) Validate() error {", name)
+	for _, f := range fields {
+		if missing := g.fieldMissing(f); missing != "" {
+			g.line("\tif %s {", missing)
+			g.line("\t\treturn fmt.Errorf(\"%s: Missing required field: %s\")", name, f.Name)
+			g.line("\t}")
+		}
+	}
+	g.line("\treturn nil")
+	g.line("}")
+}
+
+//generateUnion writes a struct holding one of the variants, tagged by the Variant field. In JSON, the
+//variant is not tagged: it is told apart by the fields of the JSON object, for struct variants, and
+//otherwise by the first variant the value can be unmarshalled as.
+func (g *generator) generateUnion(td *rdl.UnionTypeDef) {
+	name := string(td.Name)
+	tag := name + "VariantTag"
+	g.line("")
+	g.line("//")
+	g.line("// %s - generated to support %s", tag, name)
+	g.line("//")
+	g.line("type %s int", tag)
+	g.line("")
+	g.line("//")
+	g.line("// Supporting constants")
+	g.line("//")
+	g.line("const (")
+	g.line("\t_ %s = iota", tag)
+	for _, v := range td.Variants {
+		g.line("\t%sVariant%s", name, goName(string(v)))
+	}
+	g.line(")")
+	decls := []string{fmt.Sprintf("\tVariant\t%s\t`rdl:\"union\"`", tag)}
+	for _, v := range td.Variants {
+		decls = append(decls, fmt.Sprintf("\t%s\t*%s", goName(string(v)), g.valueType(v)))
+	}
+	g.comment(name, td.Comment)
+	g.line("type %s struct {", name)
+	g.aligned(decls)
+	g.line("}")
+	g.line("")
+	g.line("func (u %s) String() string {", name)
+	g.line("\tswitch u.Variant {")
+	for _, v := range td.Variants {
+		g.line("\tcase %sVariant%s:", name, goName(string(v)))
+		g.line("\t\treturn fmt.Sprintf(\"%%v\", u.%s)", goName(string(v)))
+	}
+	g.line("\tdefault:")
+	g.line("\t\treturn \"<%s uninitialized>\"", name)
+	g.line("\t}")
+	g.line("}")
+	g.line("")
+	g.line("//")
+	g.line("// MarshalJSON for %s", name)
+	g.line("//")
+	g.line("func (u %s) MarshalJSON() ([]byte, error) {", name)
+	g.line("\tswitch u.Variant {")
+	for _, v := range td.Variants {
+		g.line("\tcase %sVariant%s:", name, goName(string(v)))
+		g.line("\t\treturn json.Marshal(u.%s)", goName(string(v)))
+	}
+	g.line("\tdefault:")
+	g.line("\t\treturn nil, fmt.Errorf(\"Cannot marshal uninitialized union type %s\")", name)
+	g.line("\t}")
+	g.line("}")
+	var structs, others []rdl.TypeRef
+	for _, v := range td.Variants {
+		if t := g.reg.FindType(v); t != nil && t.Variant == rdl.TypeVariantStructTypeDef {
+			structs = append(structs, v)
+		} else {
+			others = append(others, v)
+		}
+	}
+	if len(structs) > 0 {
+		g.line("")
+		g.line("func check%sStructFields(repr map[string]interface{}, fields map[string]bool) bool {", name)
+		g.line("\tfor name, required := range fields {")
+		g.line("\t\tif _, present := repr[name]; required && !present {")
+		g.line("\t\t\treturn false")
+		g.line("\t\t}")
+		g.line("\t}")
+		g.line("\tfor name := range repr {")
+		g.line("\t\tif _, ok := fields[name]; !ok {")
+		g.line("\t\t\treturn false")
+		g.line("\t\t}")
+		g.line("\t}")
+		g.line("\treturn true")
+		g.line("}")
+	}
+	for _, v := range td.Variants {
+		vname := goName(string(v))
+		indent := "\t"
+		g.line("")
+		if t := g.reg.FindType(v); t != nil && t.Variant == rdl.TypeVariantStructTypeDef {
+			var required []string
+			for _, f := range g.structFields(t.StructTypeDef, 0) {
+				required = append(required, fmt.Sprintf("%q: %v", f.Name, !f.Optional))
+			}
+			g.line("func make%sVariant%s(b []byte, u *%s, fields map[string]interface{}) bool {", name, vname, name)
+			g.line("\tif check%sStructFields(fields, map[string]bool{%s}) {", name, strings.Join(required, ", "))
+			indent = "\t\t"
+		} else {
+			g.line("func make%sVariant%s(b []byte, u *%s) bool {", name, vname, name)
+		}
+		g.line("%svar o %s", indent, g.valueType(v))
+		g.line("%sif err := json.Unmarshal(b, &o); err == nil {", indent)
+		g.line("%s\tup := new(%s)", indent, name)
+		g.line("%s\tup.Variant = %sVariant%s", indent, name, vname)
+		g.line("%s\tup.%s = &o", indent, vname)
+		g.line("%s\t*u = *up", indent)
+		g.line("%s\treturn true", indent)
+		g.line("%s}", indent)
+		if indent != "\t" {
+			g.line("\t}")
+		}
+		g.line("\treturn false")
+		g.line("}")
+	}
+	g.line("")
+	g.line("//")
+	g.line("// UnmarshalJSON for %s", name)
+	g.line("//")
+	g.line("func (u *%s) UnmarshalJSON(b []byte) error {", name)
+	g.line("\tvar tmp interface{}")
+	g.line("\tif err := json.Unmarshal(b, &tmp); err != nil {")
+	g.line("\t\treturn err")
+	g.line("\t}")
+	if len(structs) > 0 {
+		g.line("\tswitch v := tmp.(type) {")
+		g.line("\tcase map[string]interface{}:")
+		for _, v := range structs {
+			g.line("\t\tif make%sVariant%s(b, u, v) {", name, goName(string(v)))
+			g.line("\t\t\treturn nil")
+			g.line("\t\t}")
+		}
+		g.line("\t}")
+	}
+	for _, v := range others {
+		g.line("\tif make%sVariant%s(b, u) {", name, goName(string(v)))
+		g.line("\t\treturn nil")
+		g.line("\t}")
+	}
+	g.line("\treturn fmt.Errorf(\"Cannot unmarshal JSON to union type %s\")", name)
+	g.line("}")
+}
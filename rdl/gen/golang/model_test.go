@@ -0,0 +1,120 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package golang
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+func generateTestModel(test *testing.T, rdlfile string, opts *Options) string {
+	schema, err := rdl.ParseRDLFile("../../../testdata/"+rdlfile, false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse %s: %v", rdlfile, err)
+	}
+	var out bytes.Buffer
+	if err = Generate(&out, schema, opts); err != nil {
+		test.Fatalf("Cannot generate code for %s: %v", rdlfile, err)
+	}
+	code := out.String()
+	if _, err = parser.ParseFile(token.NewFileSet(), rdlfile+".go", code, 0); err != nil {
+		test.Errorf("Generated code for %s does not parse: %v", rdlfile, err)
+	}
+	return code
+}
+
+//modelDecls strips the header and imports, which differ between versions of the generator
+func modelDecls(code string) string {
+	const preamble = "var _ = fmt.Printf\n"
+	return code[strings.Index(code, preamble)+len(preamble):]
+}
+
+//the model files in the repository, generated by earlier versions of the rdl tool, are what the
+//generator currently produces, apart from the header.
+func TestGenerateModel(test *testing.T) {
+	golden := []struct {
+		rdlfile string
+		gofile  string
+		opts    *Options
+	}{
+		{"basictypes.rdl", "../../basictypes_model.go", &Options{Package: "rdl"}},
+		{"bigtest.rdl", "../../../tbin/bigtest_model.go", &Options{Package: "tbin", Precise: true}},
+		{"drawing.rdl", "../../../tbin/polyline_model.go", &Options{Package: "tbin", Precise: true}},
+	}
+	for _, g := range golden {
+		code := generateTestModel(test, g.rdlfile, g.opts)
+		expected, err := ioutil.ReadFile(g.gofile)
+		if err != nil {
+			test.Fatalf("Cannot read %s: %v", g.gofile, err)
+		}
+		if modelDecls(code) != modelDecls(string(expected)) {
+			out := g.gofile
+			if dir, err := ioutil.TempDir("", "golang"); err == nil {
+				out = filepath.Join(dir, filepath.Base(g.gofile))
+				ioutil.WriteFile(out, []byte(code), 0644)
+			}
+			test.Errorf("Generated code for %s differs from %s. See %s", g.rdlfile, g.gofile, out)
+		}
+	}
+}
+
+func TestGenerateModelOptions(test *testing.T) {
+	code := generateTestModel(test, "bigtest.rdl", &Options{Package: "model", Precise: true, SymbolSet: true})
+	for _, s := range []string{
+		"package model\n",
+		`"github.com/ardielle/ardielle-go/rdl"`,
+		"func (e Options) SymbolSet() []string {",
+		"\tMyUuid           rdl.UUID         `json:\"myUuid\"`",
+	} {
+		if !strings.Contains(code, s) {
+			test.Errorf("Generated code for bigtest.rdl does not contain %q", s)
+		}
+	}
+	code = generateTestModel(test, "u1.rdl", nil)
+	for _, s := range []string{
+		"package rdl\n",
+		"\tS1      *string\n",
+		"func makeU1VariantS1(b []byte, u *U1) bool {",
+		"\t\tif makeU1VariantS3(b, u, v) {",
+	} {
+		if !strings.Contains(code, s) {
+			test.Errorf("Generated code for u1.rdl does not contain %q", s)
+		}
+	}
+	if strings.Contains(code, "type S1 ") {
+		test.Errorf("Generated code for u1.rdl has a String type without the Precise option")
+	}
+}
+
+func TestGenerateFiles(test *testing.T) {
+	schema, err := rdl.ParseRDLFile("../../../testdata/polyline.rdl", false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse polyline.rdl: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "golang")
+	if err != nil {
+		test.Fatalf("Cannot create a directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err = GenerateFiles(dir, "polyline", schema, &Options{TBin: true}); err == nil {
+		test.Errorf("Expected the TBin option to require the Precise option")
+	}
+	paths, err := GenerateFiles(dir, "polyline", schema, &Options{Package: "tbin", Precise: true, TBin: true})
+	if err != nil || len(paths) != 2 {
+		test.Fatalf("Cannot generate files: %v", err)
+	}
+	code, _ := ioutil.ReadFile(paths[1])
+	expected, _ := ioutil.ReadFile("../../../tbin/polyline_tbin.go")
+	if filepath.Base(paths[1]) != "polyline_tbin.go" || string(code) != string(expected) {
+		test.Errorf("Generated TBin code differs from polyline_tbin.go")
+	}
+}
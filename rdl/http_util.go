@@ -5,10 +5,14 @@ package rdl
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // for client/server generated code support
@@ -83,6 +87,151 @@ func JSONResponse(w http.ResponseWriter, code int, data interface{}) {
 	}
 }
 
+//
+// Codec - an encoding of resource data, identified by its media type, for content negotiation.
+// JSON is always available. Other encodings register themselves with RegisterCodec, as the tbin
+// package does, so a service that imports it can exchange tbin with clients that ask for it.
+//
+type Codec struct {
+	MediaType string
+	Marshal   func(data interface{}) ([]byte, error)
+	Unmarshal func(b []byte, data interface{}) error
+}
+
+// JSONCodec is the default encoding, used when a request does not ask for another.
+var JSONCodec = &Codec{
+	MediaType: "application/json",
+	Marshal: func(data interface{}) ([]byte, error) {
+		b, err := json.MarshalIndent(data, "", "  ")
+		if err == nil {
+			b = append(b, '\n')
+		}
+		return b, err
+	},
+	Unmarshal: json.Unmarshal,
+}
+
+var codecs = struct {
+	sync.RWMutex
+	byType map[string]*Codec
+}{byType: map[string]*Codec{JSONCodec.MediaType: JSONCodec}}
+
+//
+// RegisterCodec - make the codec available for content negotiation, replacing any with the same media type.
+//
+func RegisterCodec(codec *Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.byType[strings.ToLower(codec.MediaType)] = codec
+}
+
+//
+// LookupCodec - return the codec registered for the media type, or nil if there is none.
+//
+func LookupCodec(mediaType string) *Codec {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	return codecs.byType[strings.ToLower(mediaType)]
+}
+
+//
+// NegotiateCodec - return the registered codec that the Accept header of the request prefers. Media
+// ranges are weighed by their q parameter, and JSON is preferred among equals. If the request accepts
+// none of the registered codecs, JSON is used.
+//
+func NegotiateCodec(r *http.Request) *Codec {
+	best, bestQ := JSONCodec, -1.0
+	for _, accept := range r.Header["Accept"] {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if qs, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(qs, 64); err != nil {
+					continue
+				}
+			}
+			if q <= 0 {
+				continue
+			}
+			var codec *Codec
+			if mediaType == "*/*" || mediaType == "application/*" {
+				codec = JSONCodec
+			} else {
+				codec = LookupCodec(mediaType)
+			}
+			if codec != nil && (q > bestQ || (q == bestQ && codec == JSONCodec)) {
+				best, bestQ = codec, q
+			}
+		}
+	}
+	return best
+}
+
+//
+// Response - write the data as the response, encoded as the request prefers (see NegotiateCodec). A nil
+// data is written as a ResourceError with the code and its status text. If the data cannot be encoded,
+// a 500 ResourceError is written instead.
+//
+func Response(w http.ResponseWriter, r *http.Request, code int, data interface{}) {
+	codec := NegotiateCodec(r)
+	w.Header().Add("Vary", "Accept")
+	switch code {
+	case 204, 304:
+		w.WriteHeader(code)
+		return
+	}
+	if data == nil {
+		data = ResourceError{code, http.StatusText(code)}
+	}
+	b, err := codec.Marshal(data)
+	if err != nil {
+		code = http.StatusInternalServerError
+		b, _ = codec.Marshal(ResourceError{code, http.StatusText(code)})
+	}
+	w.Header().Set("Content-Type", codec.MediaType)
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// MaxRequestBodySize is the largest request body, in bytes, that ReadRequestBody reads.
+var MaxRequestBodySize int64 = 32 << 20
+
+//
+// ReadRequestBody - decode the body of the request into data, according to its Content-Type, which
+// defaults to JSON. An unsupported Content-Type is a 415 ResourceError, a body larger than
+// MaxRequestBodySize is a 413 ResourceError, and a body that cannot be decoded is a 400 ResourceError.
+//
+func ReadRequestBody(r *http.Request, data interface{}) error {
+	codec := JSONCodec
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err == nil {
+			codec = LookupCodec(mediaType)
+		}
+		if codec == nil || err != nil {
+			return &ResourceError{http.StatusUnsupportedMediaType, "Unsupported Content-Type: " + ct}
+		}
+	}
+	if r.Body == nil {
+		return &ResourceError{http.StatusBadRequest, "Missing request body"}
+	}
+	b, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, MaxRequestBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &ResourceError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit)}
+		}
+		return &ResourceError{http.StatusBadRequest, "Cannot read request body: " + err.Error()}
+	}
+	if err = codec.Unmarshal(b, data); err != nil {
+		return &ResourceError{http.StatusBadRequest, "Bad " + codec.MediaType + " request body: " + err.Error()}
+	}
+	return nil
+}
+
 // OptionalStringParam parses and returns an optional parameter
 // from the form body (multipart/form-data encoded).
 func OptionalStringParam(r *http.Request, name string) string {
@@ -239,3 +388,55 @@ func HeaderParam(r *http.Request, name string, defaultValue string) string {
 func FoldHttpHeaderName(name string) string {
 	return http.CanonicalHeaderKey(name)
 }
+
+//
+// DeprecationHeader - the value of the Deprecation response header for the resource, or "" if it is
+// not deprecated. It is the date of the deprecation, as RFC 9745 has it (i.e. "@1767225600"), if
+// the resource has an x_deprecated_since Timestamp, and "true" otherwise.
+//
+func DeprecationHeader(r *Resource) string {
+	d := Deprecated(r.Annotations)
+	if d == nil {
+		return ""
+	}
+	if ts, err := TimestampParse(d.Since); err == nil {
+		return fmt.Sprintf("@%d", ts.Unix())
+	}
+	return "true"
+}
+
+//
+// SetDeprecationHeader - add the Deprecation header to the response, if the resource is deprecated
+//
+func SetDeprecationHeader(w http.ResponseWriter, r *Resource) {
+	if value := DeprecationHeader(r); value != "" {
+		w.Header().Set("Deprecation", value)
+	}
+}
+
+//
+// DeprecationHandler - wrap the handler of the resources of the schema, so that the responses to
+// requests for deprecated resources have the Deprecation header. The resources are served under
+// the base path, i.e. "/api/v1", and a request is for the first resource that matches it.
+//
+func DeprecationHandler(schema *Schema, basePath string, handler http.Handler) http.Handler {
+	var routes []*route
+	for _, r := range schema.Resources {
+		if rt, err := parseRoute(r); err == nil {
+			routes = append(routes, rt)
+		}
+	}
+	basePath = strings.TrimSuffix(basePath, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, basePath+"/") {
+			path := strings.TrimPrefix(req.URL.Path, basePath)
+			for _, rt := range routes {
+				if rt.resource.Method == req.Method && rt.matchesPath(path) {
+					SetDeprecationHeader(w, rt.resource)
+					break
+				}
+			}
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
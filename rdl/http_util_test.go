@@ -0,0 +1,174 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//a codec that shows which one was used
+var testCodec = &Codec{
+	MediaType: "application/x-test",
+	Marshal: func(data interface{}) ([]byte, error) {
+		return []byte(fmt.Sprintf("test:%v", data)), nil
+	},
+	Unmarshal: func(b []byte, data interface{}) error {
+		if !strings.HasPrefix(string(b), "test:") {
+			return fmt.Errorf("not test data")
+		}
+		return json.Unmarshal(b[5:], data)
+	},
+}
+
+func init() {
+	RegisterCodec(testCodec)
+}
+
+func negotiate(accept ...string) string {
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, a := range accept {
+		r.Header.Add("Accept", a)
+	}
+	return NegotiateCodec(r).MediaType
+}
+
+func TestNegotiateCodec(test *testing.T) {
+	cases := []struct {
+		accept   []string
+		expected string
+	}{
+		{nil, "application/json"},
+		{[]string{"application/x-test"}, "application/x-test"},
+		{[]string{"application/json, application/x-test"}, "application/json"},
+		{[]string{"application/x-test, application/json"}, "application/json"},
+		{[]string{"application/json;q=0.5, application/x-test"}, "application/x-test"},
+		{[]string{"application/json;q=0.5", "APPLICATION/X-TEST;q=0.9"}, "application/x-test"},
+		{[]string{"application/x-test;q=0, */*"}, "application/json"},
+		{[]string{"text/html, application/xml"}, "application/json"},
+		{[]string{"application/x-test;q=bad, application/*;q=0.1"}, "application/json"},
+	}
+	for _, c := range cases {
+		if mt := negotiate(c.accept...); mt != c.expected {
+			test.Errorf("Accept %q: expected %s, got %s", c.accept, c.expected, mt)
+		}
+	}
+}
+
+func TestResponse(test *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	Response(w, r, 200, map[string]int{"a": 1})
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json" || w.Body.String() != "{\n  \"a\": 1\n}\n" {
+		test.Errorf("Unexpected JSON response: %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	r.Header.Set("Accept", "application/x-test")
+	w = httptest.NewRecorder()
+	Response(w, r, 404, nil)
+	if w.Code != 404 || w.Header().Get("Content-Type") != "application/x-test" || w.Body.String() != "test:404 Not Found" {
+		test.Errorf("Unexpected negotiated response: %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	if w.Header().Get("Vary") != "Accept" {
+		test.Errorf("Expected the response to vary by Accept")
+	}
+
+	w = httptest.NewRecorder()
+	Response(w, r, 204, nil)
+	if w.Code != 204 || w.Body.Len() != 0 {
+		test.Errorf("Expected no body for a 204 response")
+	}
+
+	//an unencodable value is a server error
+	r.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	Response(w, r, 200, map[string]interface{}{"f": func() {}})
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "Internal Server Error") {
+		test.Errorf("Expected a server error, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func readBody(contentType string, body string, data interface{}) error {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return ReadRequestBody(r, data)
+}
+
+func TestReadRequestBody(test *testing.T) {
+	var m map[string]int
+	if err := readBody("", `{"a": 1}`, &m); err != nil || m["a"] != 1 {
+		test.Errorf("Cannot read a JSON body: %v", err)
+	}
+	m = nil
+	if err := readBody("application/x-test; charset=utf-8", `test:{"b": 2}`, &m); err != nil || m["b"] != 2 {
+		test.Errorf("Cannot read a negotiated body: %v", err)
+	}
+	errors := []struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		{"application/json", `{"a": `, 400},
+		{"application/x-test", `{"a": 1}`, 400},
+		{"text/plain", `a`, 415},
+		{"bad/", `a`, 415},
+	}
+	for _, e := range errors {
+		err := readBody(e.contentType, e.body, &m)
+		if rerr, ok := err.(*ResourceError); !ok || rerr.Code != e.code {
+			test.Errorf("Expected a %d ResourceError for %s %q, got %v", e.code, e.contentType, e.body, err)
+		}
+	}
+
+	//the body is read up to the limit
+	defer func(max int64) { MaxRequestBodySize = max }(MaxRequestBodySize)
+	MaxRequestBodySize = 8
+	if err := readBody("", `{"a": 1}`, &m); err != nil {
+		test.Errorf("Cannot read a body of the maximum size: %v", err)
+	}
+	if rerr, ok := readBody("", `{"a": 12}`, &m).(*ResourceError); !ok || rerr.Code != 413 {
+		test.Errorf("Expected a 413 ResourceError for a body over the limit, got %v", rerr)
+	}
+}
+
+func TestDeprecationHandler(test *testing.T) {
+	schema, err := parseRDL(nil, "colors.rdl", strings.NewReader(deprecationTestSchema), false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse: %v", err)
+	}
+	if DeprecationHeader(schema.Resources[0]) != "" || DeprecationHeader(schema.Resources[1]) != "@1767225600" {
+		test.Errorf("Wrong Deprecation headers: %q, %q", DeprecationHeader(schema.Resources[0]), DeprecationHeader(schema.Resources[1]))
+	}
+	handler := DeprecationHandler(schema, "/api/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for path, expected := range map[string]string{
+		"/api/v1/paints/red":   "@1767225600",
+		"/api/paints/red":      "",
+		"/api/v1/paints":       "",
+		"/api/v1/paints/red/x": "",
+		"/v1/paints/red":       "",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if got := w.Header().Get("Deprecation"); got != expected || w.Code != http.StatusOK {
+			test.Errorf("Wrong Deprecation header for %s: %q, expected %q", path, got, expected)
+		}
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("PUT", "/api/v1/paints/red", nil))
+	if got := w.Header().Get("Deprecation"); got != "" {
+		test.Errorf("Unexpected Deprecation header for another method: %q", got)
+	}
+	delete(schema.Resources[1].Annotations, AnnotationDeprecatedSince)
+	if DeprecationHeader(schema.Resources[1]) != "true" {
+		test.Errorf("Wrong Deprecation header without a date: %q", DeprecationHeader(schema.Resources[1]))
+	}
+}
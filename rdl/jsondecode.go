@@ -0,0 +1,655 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+//
+// JSONError - an error found by DecodeJSON, with the position in the input of the value that caused
+// it. The context is the path of the value from the top level type, as in a Validation.
+//
+type JSONError struct {
+	Offset  int64
+	Line    int
+	Column  int
+	Context string
+	Message string
+}
+
+func (e *JSONError) Error() string {
+	if e.Context == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Context, e.Message)
+}
+
+//
+// DecodeJSON - decode JSON data of the specified type in the schema, checking it against the type as it
+// goes, rather than decoding it generically and validating the result. The result is generic, but each
+// value has the Go type for its RDL type: int32 for an Int32, Timestamp, UUID and Symbol for those, []byte
+// for Bytes (which are base64 encoded in JSON), a string for an enum symbol, []interface{} for an array,
+// and map[string]interface{} for a struct, or a map with String keys. A map with other keys is a
+// map[interface{}]interface{}, with keys of the key type. Numbers are never converted to float64 on the
+// way, so all Int64 values are exact. Missing fields are left out, rather than set to their defaults.
+//
+// A union may be represented either as its variant value, as generated models marshal it, or wrapped
+// in an object with the name of its variant type as the only key, as the validator expects. A struct
+// that is a variant of a union must not have fields that its type does not define.
+//
+// An error is returned as a *JSONError, which locates the offending value in the input.
+//
+func DecodeJSON(schema *Schema, typename string, r io.Reader) (interface{}, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	checker := &validator{registry: NewTypeRegistry(schema), schema: schema, options: &ValidateOptions{}}
+	t := checker.registry.FindType(TypeRef(typename))
+	if t == nil {
+		return nil, &JSONError{Line: 1, Column: 1, Context: typename, Message: "No such type"}
+	}
+	d := newJSONDecoder(checker, data, data, 0, false)
+	val, err := d.decode(t, typename)
+	if err != nil {
+		return nil, err
+	}
+	if pos := d.offset(); pos < int64(len(data)) {
+		return nil, d.errorAt(pos, "", "Unexpected data after the value")
+	}
+	return val, nil
+}
+
+type jsonDecoder struct {
+	checker *validator
+	data    []byte //all of the input, to locate errors
+	base    int64  //the offset in data of the input of this decoder
+	dec     *json.Decoder
+	strict  bool //if set, a struct must not have fields its type does not define
+}
+
+func newJSONDecoder(checker *validator, data []byte, input []byte, base int64, strict bool) *jsonDecoder {
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+	return &jsonDecoder{checker: checker, data: data, base: base, dec: dec, strict: strict}
+}
+
+//offset returns the position in the data of the next value to be read
+func (d *jsonDecoder) offset() int64 {
+	pos := d.base + d.dec.InputOffset()
+	for pos < int64(len(d.data)) {
+		switch d.data[pos] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			pos++
+			continue
+		}
+		break
+	}
+	return pos
+}
+
+func (d *jsonDecoder) errorAt(pos int64, context string, format string, args ...interface{}) error {
+	if pos > int64(len(d.data)) {
+		pos = int64(len(d.data))
+	}
+	before := d.data[:pos]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+	return &JSONError{Offset: pos, Line: line, Column: col, Context: context, Message: fmt.Sprintf(format, args...)}
+}
+
+//syntaxError converts an error from the json decoder into a JSONError
+func (d *jsonDecoder) syntaxError(pos int64, context string, err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return d.errorAt(d.base+e.Offset, context, "%s", e.Error())
+	case *JSONError:
+		return e
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return d.errorAt(int64(len(d.data)), context, "Unexpected end of JSON input")
+	}
+	return d.errorAt(pos, context, "%v", err)
+}
+
+func (d *jsonDecoder) token(context string) (interface{}, int64, error) {
+	pos := d.offset()
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, pos, d.syntaxError(pos, context, err)
+	}
+	return tok, pos, nil
+}
+
+func (d *jsonDecoder) bad(pos int64, context string, t *Type) error {
+	tName, _, _ := TypeInfo(t)
+	return d.errorAt(pos, context, "Bad %s", tName)
+}
+
+func (d *jsonDecoder) decode(t *Type, context string) (interface{}, error) {
+	t = d.checker.resolveAliases(t, context)
+	switch d.checker.registry.BaseType(t) {
+	case BaseTypeAny:
+		return d.decodeAny(context)
+	case BaseTypeUnion:
+		return d.decodeUnion(t, context)
+	case BaseTypeStruct:
+		return d.decodeStruct(t, context)
+	case BaseTypeArray:
+		return d.decodeArray(t, context)
+	case BaseTypeMap:
+		return d.decodeMap(t, context)
+	}
+	tok, pos, err := d.token(context)
+	if err != nil {
+		return nil, err
+	}
+	return d.convertScalar(t, tok, false, pos, context)
+}
+
+//convertScalar converts a JSON token, which is either a value or an object key, to the scalar type
+func (d *jsonDecoder) convertScalar(t *Type, tok interface{}, key bool, pos int64, context string) (interface{}, error) {
+	base := d.checker.registry.BaseType(t)
+	switch base {
+	case BaseTypeBool:
+		if b, ok := tok.(bool); ok {
+			return b, nil
+		}
+	case BaseTypeInt8, BaseTypeInt16, BaseTypeInt32, BaseTypeInt64, BaseTypeFloat32, BaseTypeFloat64:
+		var s string
+		switch v := tok.(type) {
+		case json.Number:
+			s = string(v)
+		case string:
+			if !key {
+				return nil, d.bad(pos, context, t)
+			}
+			s = v
+		default:
+			return nil, d.bad(pos, context, t)
+		}
+		return d.convertNumber(t, base, s, pos, context)
+	case BaseTypeString, BaseTypeSymbol, BaseTypeUUID, BaseTypeTimestamp, BaseTypeBytes, BaseTypeEnum:
+		s, ok := tok.(string)
+		if !ok {
+			break
+		}
+		switch base {
+		case BaseTypeString:
+			return d.checkString(t, s, pos, context)
+		case BaseTypeSymbol:
+			return Symbol(s), nil
+		case BaseTypeUUID:
+			if u := ParseUUID(s); u != nil {
+				return u, nil
+			}
+		case BaseTypeTimestamp:
+			if ts, err := TimestampParse(s); err == nil {
+				return ts, nil
+			}
+		case BaseTypeBytes:
+			if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+				return d.checkBytes(t, b, pos, context)
+			}
+		case BaseTypeEnum:
+			if v := d.checker.validateEnum(t, s, context); v.Valid {
+				return s, nil
+			}
+			return nil, d.errorAt(pos, context, "Invalid value in Enum type %s: %q", t.EnumTypeDef.Name, s)
+		}
+	}
+	return nil, d.bad(pos, context, t)
+}
+
+func (d *jsonDecoder) checkString(t *Type, s string, pos int64, context string) (interface{}, error) {
+	if v := d.checker.validateString(t, s, context); !v.Valid {
+		return nil, d.errorAt(pos, context, "%s", v.Error)
+	}
+	if t.Variant == TypeVariantStringTypeDef {
+		n := int32(utf8.RuneCountInString(s))
+		if st := t.StringTypeDef; st.MinSize != nil && n < *st.MinSize {
+			return nil, d.errorAt(pos, context, "String is shorter than the minimum size of %d", *st.MinSize)
+		} else if st.MaxSize != nil && n > *st.MaxSize {
+			return nil, d.errorAt(pos, context, "String is longer than the maximum size of %d", *st.MaxSize)
+		}
+	}
+	return s, nil
+}
+
+func (d *jsonDecoder) checkBytes(t *Type, b []byte, pos int64, context string) (interface{}, error) {
+	if t.Variant == TypeVariantBytesTypeDef {
+		bt := t.BytesTypeDef
+		if err := d.checkSize(int32(len(b)), bt.Size, bt.MinSize, bt.MaxSize, "Bytes", pos, context); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (d *jsonDecoder) checkSize(n int32, size *int32, minSize *int32, maxSize *int32, what string, pos int64, context string) error {
+	if size != nil && n != *size {
+		return d.errorAt(pos, context, "%s is not of the specified size %d", what, *size)
+	}
+	if minSize != nil && n < *minSize {
+		return d.errorAt(pos, context, "%s is smaller than the specified minimum size %d", what, *minSize)
+	}
+	if maxSize != nil && n > *maxSize {
+		return d.errorAt(pos, context, "%s is larger than the specified maximum size %d", what, *maxSize)
+	}
+	return nil
+}
+
+func (d *jsonDecoder) convertNumber(t *Type, base BaseType, s string, pos int64, context string) (interface{}, error) {
+	var val interface{}
+	switch base {
+	case BaseTypeFloat32, BaseTypeFloat64:
+		bits := 64
+		if base == BaseTypeFloat32 {
+			bits = 32
+		}
+		f, err := strconv.ParseFloat(s, bits)
+		if err != nil {
+			return nil, d.errorAt(pos, context, "Bad %s: %s", base, s)
+		}
+		if base == BaseTypeFloat32 {
+			val = float32(f)
+		} else {
+			val = f
+		}
+	default:
+		bits := map[BaseType]int{BaseTypeInt8: 8, BaseTypeInt16: 16, BaseTypeInt32: 32, BaseTypeInt64: 64}[base]
+		n, err := strconv.ParseInt(s, 10, bits)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return nil, d.errorAt(pos, context, "Value %s overflows %s", s, base)
+			}
+			return nil, d.errorAt(pos, context, "Bad %s: %s", base, s)
+		}
+		switch base {
+		case BaseTypeInt8:
+			val = int8(n)
+		case BaseTypeInt16:
+			val = int16(n)
+		case BaseTypeInt32:
+			val = int32(n)
+		default:
+			val = n
+		}
+	}
+	if t.Variant == TypeVariantNumberTypeDef {
+		nt := t.NumberTypeDef
+		if nt.Min != nil && compareNumber(val, nt.Min) < 0 {
+			return nil, d.errorAt(pos, context, "Value is less than 'min' constraint %v of %s", numberValue(nt.Min), nt.Name)
+		}
+		if nt.Max != nil && compareNumber(val, nt.Max) > 0 {
+			return nil, d.errorAt(pos, context, "Value is greater than 'max' constraint %v of %s", numberValue(nt.Max), nt.Name)
+		}
+	}
+	return val, nil
+}
+
+//compareNumber compares a decoded number with a constraint, exactly when both are integers
+func compareNumber(val interface{}, bound *Number) int {
+	var ival int64
+	isInt := true
+	switch v := val.(type) {
+	case int8:
+		ival = int64(v)
+	case int16:
+		ival = int64(v)
+	case int32:
+		ival = int64(v)
+	case int64:
+		ival = v
+	default:
+		isInt = false
+	}
+	var ibound int64
+	fbound := math.NaN()
+	switch bound.Variant {
+	case NumberVariantInt8:
+		ibound = int64(*bound.Int8)
+	case NumberVariantInt16:
+		ibound = int64(*bound.Int16)
+	case NumberVariantInt32:
+		ibound = int64(*bound.Int32)
+	case NumberVariantInt64:
+		ibound = *bound.Int64
+	case NumberVariantFloat32:
+		fbound = float64(*bound.Float32)
+	case NumberVariantFloat64:
+		fbound = *bound.Float64
+	default:
+		return 0
+	}
+	if isInt && math.IsNaN(fbound) {
+		switch {
+		case ival < ibound:
+			return -1
+		case ival > ibound:
+			return 1
+		}
+		return 0
+	}
+	var fval float64
+	if isInt {
+		fval = float64(ival)
+	} else if f32, ok := val.(float32); ok {
+		fval = float64(f32)
+	} else {
+		fval = val.(float64)
+	}
+	if math.IsNaN(fbound) {
+		fbound = float64(ibound)
+	}
+	switch {
+	case fval < fbound:
+		return -1
+	case fval > fbound:
+		return 1
+	}
+	return 0
+}
+
+//numberValue is the value of the variant the number holds, for messages
+func numberValue(n *Number) interface{} {
+	switch n.Variant {
+	case NumberVariantInt8:
+		return *n.Int8
+	case NumberVariantInt16:
+		return *n.Int16
+	case NumberVariantInt32:
+		return *n.Int32
+	case NumberVariantInt64:
+		return *n.Int64
+	case NumberVariantFloat32:
+		return *n.Float32
+	case NumberVariantFloat64:
+		return *n.Float64
+	}
+	return nil
+}
+
+//expectDelim reads the opening of an object or an array
+func (d *jsonDecoder) expectDelim(delim json.Delim, t *Type, context string) (int64, error) {
+	tok, pos, err := d.token(context)
+	if err != nil {
+		return pos, err
+	}
+	if tok != delim {
+		return pos, d.bad(pos, context, t)
+	}
+	return pos, nil
+}
+
+func (d *jsonDecoder) decodeStruct(t *Type, context string) (interface{}, error) {
+	pos, err := d.expectDelim('{', t, context)
+	if err != nil {
+		return nil, err
+	}
+	var fields []*StructFieldDef
+	closed := d.strict
+	if t.Variant == TypeVariantStructTypeDef {
+		fields = flattenedFields(d.checker.registry, t)
+		closed = closed || t.StructTypeDef.Closed
+	}
+	result := make(map[string]interface{}, len(fields))
+	for d.dec.More() {
+		tok, kpos, err := d.token(context)
+		if err != nil {
+			return nil, err
+		}
+		name := tok.(string)
+		var field *StructFieldDef
+		for _, f := range fields {
+			if string(f.Name) == name {
+				field = f
+				break
+			}
+		}
+		fcontext := context + "." + name
+		if field == nil {
+			if closed {
+				return nil, d.errorAt(kpos, context, "Unexpected field: '%s'", name)
+			}
+			if result[name], err = d.decodeAny(fcontext); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if d.peekNull() {
+			if !field.Optional && field.Default == nil {
+				return nil, d.errorAt(d.offset(), fcontext, "Field cannot be null: %s", name)
+			}
+			d.dec.Token()
+			continue
+		}
+		ft := d.checker.registry.FindType(field.Type)
+		if ft == nil {
+			return nil, d.errorAt(kpos, fcontext, "No such type: %s", field.Type)
+		}
+		if result[name], err = d.decode(d.checker.synthesizeFieldType(ft, field), fcontext); err != nil {
+			return nil, err
+		}
+	}
+	if _, _, err = d.token(context); err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		if _, ok := result[string(f.Name)]; !ok && !f.Optional && f.Default == nil {
+			return nil, d.errorAt(pos, context, "Field missing: %s", f.Name)
+		}
+	}
+	return result, nil
+}
+
+func (d *jsonDecoder) peekNull() bool {
+	pos := d.offset()
+	return bytes.HasPrefix(d.data[pos:], []byte("null"))
+}
+
+func (d *jsonDecoder) decodeArray(t *Type, context string) (interface{}, error) {
+	pos, err := d.expectDelim('[', t, context)
+	if err != nil {
+		return nil, err
+	}
+	items := d.checker.registry.FindType("Any")
+	at := t.ArrayTypeDef
+	if at != nil && at.Items != "" {
+		if items = d.checker.registry.FindType(at.Items); items == nil {
+			return nil, d.errorAt(pos, context, "No such type: %s", at.Items)
+		}
+	}
+	result := make([]interface{}, 0)
+	for d.dec.More() {
+		item, err := d.decode(items, fmt.Sprintf("%s[%d]", context, len(result)))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	if _, _, err = d.token(context); err != nil {
+		return nil, err
+	}
+	if at != nil {
+		if err = d.checkSize(int32(len(result)), at.Size, at.MinSize, at.MaxSize, "Array", pos, context); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (d *jsonDecoder) decodeMap(t *Type, context string) (interface{}, error) {
+	pos, err := d.expectDelim('{', t, context)
+	if err != nil {
+		return nil, err
+	}
+	keys := d.checker.registry.FindType("String")
+	items := d.checker.registry.FindType("Any")
+	mt := t.MapTypeDef
+	if mt != nil {
+		if mt.Keys != "" {
+			keys = d.checker.registry.FindType(mt.Keys)
+		}
+		if mt.Items != "" {
+			items = d.checker.registry.FindType(mt.Items)
+		}
+		if keys == nil || items == nil {
+			return nil, d.errorAt(pos, context, "No such type: %s", t.MapTypeDef.Name)
+		}
+	}
+	keys = d.checker.resolveAliases(keys, context)
+	var strmap map[string]interface{}
+	var anymap map[interface{}]interface{}
+	if d.checker.registry.BaseType(keys) == BaseTypeString {
+		strmap = make(map[string]interface{})
+	} else {
+		anymap = make(map[interface{}]interface{})
+	}
+	count := 0
+	for d.dec.More() {
+		tok, kpos, err := d.token(context)
+		if err != nil {
+			return nil, err
+		}
+		kcontext := fmt.Sprintf("%s[%v]", context, tok)
+		key, err := d.convertScalar(keys, tok, true, kpos, kcontext)
+		if err != nil {
+			return nil, err
+		}
+		item, err := d.decode(items, kcontext)
+		if err != nil {
+			return nil, err
+		}
+		if strmap != nil {
+			strmap[key.(string)] = item
+		} else {
+			anymap[mapKey(key)] = item
+		}
+		count++
+	}
+	if _, _, err = d.token(context); err != nil {
+		return nil, err
+	}
+	if mt != nil {
+		if err = d.checkSize(int32(count), mt.Size, mt.MinSize, mt.MaxSize, "Map", pos, context); err != nil {
+			return nil, err
+		}
+	}
+	if strmap != nil {
+		return strmap, nil
+	}
+	return anymap, nil
+}
+
+//mapKey makes a key comparable: UUID and Bytes keys are slices, and are used as their strings
+func mapKey(key interface{}) interface{} {
+	switch k := key.(type) {
+	case UUID:
+		return k.String()
+	case []byte:
+		return string(k)
+	}
+	return key
+}
+
+func (d *jsonDecoder) decodeUnion(t *Type, context string) (interface{}, error) {
+	ut := t.UnionTypeDef
+	pos := d.offset()
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return nil, d.syntaxError(pos, context, err)
+	}
+	input := d.data[pos : pos+int64(len(raw))]
+
+	//the wrapped form, an object with the name of the variant as its only key
+	if val, ok := d.decodeWrappedVariant(ut, input, pos, context); ok {
+		return val, nil
+	}
+	//otherwise, the first variant the value is valid for
+	for _, v := range ut.Variants {
+		vt := d.checker.registry.FindType(v)
+		if vt == nil {
+			return nil, d.errorAt(pos, context, "No such type: %s", v)
+		}
+		sub := newJSONDecoder(d.checker, d.data, input, pos, true)
+		if val, err := sub.decode(vt, context); err == nil {
+			return val, nil
+		}
+	}
+	return nil, d.errorAt(pos, context, "Value is not any variant of Union type %s", ut.Name)
+}
+
+func (d *jsonDecoder) decodeWrappedVariant(ut *UnionTypeDef, input []byte, pos int64, context string) (interface{}, bool) {
+	if len(input) == 0 || input[0] != '{' {
+		return nil, false
+	}
+	sub := newJSONDecoder(d.checker, d.data, input, pos, true)
+	sub.dec.Token()
+	tok, err := sub.dec.Token()
+	name, ok := tok.(string)
+	if err != nil || !ok {
+		return nil, false
+	}
+	for _, v := range ut.Variants {
+		if string(v) == name {
+			vt := d.checker.registry.FindType(v)
+			if vt == nil {
+				return nil, false
+			}
+			val, err := sub.decode(vt, context)
+			if err != nil {
+				return nil, false
+			}
+			if tok, err = sub.dec.Token(); err != nil || tok != json.Delim('}') {
+				return nil, false
+			}
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+//decodeAny decodes a value of unknown type. Integral numbers are the narrowest of int32 and int64 that
+//holds them, and other numbers are float64.
+func (d *jsonDecoder) decodeAny(context string) (interface{}, error) {
+	pos := d.offset()
+	var val interface{}
+	if err := d.dec.Decode(&val); err != nil {
+		return nil, d.syntaxError(pos, context, err)
+	}
+	return anyNumbers(val), nil
+}
+
+func anyNumbers(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int32(n)
+			}
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i, item := range v {
+			v[i] = anyNumbers(item)
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = anyNumbers(item)
+		}
+	}
+	return val
+}
@@ -0,0 +1,153 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func decodeTestJSON(test *testing.T, schema *Schema, typename string, j string) (interface{}, *JSONError) {
+	val, err := DecodeJSON(schema, typename, strings.NewReader(j))
+	if err == nil {
+		return val, nil
+	}
+	jerr, ok := err.(*JSONError)
+	if !ok {
+		test.Fatalf("Expected a JSONError, got %v", err)
+	}
+	return nil, jerr
+}
+
+func TestDecodeJSON(test *testing.T) {
+	schema := loadTestSchema(test, "basictypes.rdl")
+	f, err := os.Open("../testdata/basictypes.json")
+	if err != nil {
+		test.Fatalf("Cannot open basictypes.json: %v", err)
+	}
+	defer f.Close()
+	val, err := DecodeJSON(schema, "Test", f)
+	if err != nil {
+		test.Fatalf("Cannot decode basictypes.json: %v", err)
+	}
+	data := val.(map[string]interface{})
+	expected := map[string]interface{}{
+		"int":       int32(215),
+		"mylong":    int64(1234567),
+		"mydbl":     25.23,
+		"myyear":    int32(1978),
+		"myoptions": "ONE",
+		"bool2":     true,
+		"co1":       "a:b",
+		"extra":     "stuff",
+	}
+	for k, v := range expected {
+		if data[k] != v {
+			test.Errorf("Expected %s to be %T %v, got %T %v", k, v, v, data[k], data[k])
+		}
+	}
+	if ts, ok := data["mytime"].(Timestamp); !ok || ts.String() != "2012-02-29T23:59:59.123Z" {
+		test.Errorf("Expected a Timestamp, got %T %v", data["mytime"], data["mytime"])
+	}
+	if u, ok := data["myuuid"].(UUID); !ok || u.String() != "7829db01-a4ad-11de-0000-090000000179" {
+		test.Errorf("Expected a UUID, got %T %v", data["myuuid"], data["myuuid"])
+	}
+	symtest := data["symtest"].(map[string]interface{})
+	if m, ok := symtest["symmap"].(map[interface{}]interface{}); !ok || m[Symbol("foobar")] != "barfoo" {
+		test.Errorf("Expected a map with Symbol keys, got %T %v", symtest["symmap"], symtest["symmap"])
+	}
+	if a, ok := symtest["symary"].([]interface{}); !ok || len(a) != 2 || a[1] != Symbol("barfoo") {
+		test.Errorf("Expected an array of Symbols, got %v", symtest["symary"])
+	}
+
+	//large Int64 values are exact
+	val, jerr := decodeTestJSON(test, schema, "Test", strings.Replace(testJSON, `"mylong": 1234567`, `"mylong": 9007199254740993`, 1))
+	if jerr != nil || val.(map[string]interface{})["mylong"] != int64(9007199254740993) {
+		test.Errorf("Expected an exact Int64, got %v (%v)", val, jerr)
+	}
+}
+
+//a compact version of basictypes.json, to make errors in it
+const testJSON = `{
+  "name": "a", "utfname": "姚冀清", "int": 215, "mylong": 1234567, "mydbl": 25.23,
+  "my_int_array": [1, 2, 3], "my_str_array": ["abc"],
+  "myuuid": "7829db01-a4ad-11de-0000-090000000179", "myaz": "abc", "bool": false, "bool2": true,
+  "myyear": 1978, "mypi": 3.14, "myotherlong": 500001, "myoptions": "ONE", "mytime": "2012-02-29T23:59:59.123Z",
+  "co1": "a:b", "co2": "c:d", "co3": "e:f", "intarray": [1, 2, 3],
+  "symtest": {"symmap": {"foobar": "barfoo"}, "symary": ["foobar"], "sym": "foobar"}
+}`
+
+func TestDecodeJSONErrors(test *testing.T) {
+	schema := loadTestSchema(test, "basictypes.rdl")
+	if _, jerr := decodeTestJSON(test, schema, "Test", testJSON); jerr != nil {
+		test.Fatalf("Cannot decode the test data: %v", jerr)
+	}
+	cases := []struct {
+		from, to string
+		line     int
+		col      int
+		context  string
+		message  string
+	}{
+		{`"int": 215`, `"int": 3000000000`, 2, 41, "Test.int", "Value 3000000000 overflows Int32"},
+		{`"int": 215`, `"int": 2.5`, 2, 41, "Test.int", "Bad Int32: 2.5"},
+		{`"int": 215`, `"int": "215"`, 2, 41, "Test.int", "Bad Int32"},
+		{`"myyear": 1978`, `"myyear": 5000`, 5, 13, "Test.myyear", "Value is greater than 'max' constraint 3000 of Year"},
+		{`"myyear": 1978`, `"myyear": 500`, 5, 13, "Test.myyear", "Value is less than 'min' constraint 1000 of Year"},
+		{`"myoptions": "ONE"`, `"myoptions": "FOUR"`, 5, 69, "Test.myoptions", `Invalid value in Enum type Options: "FOUR"`},
+		{`"myaz": "abc"`, `"myaz": "a1"`, 4, 61, "Test.myaz", "Pattern mismatch in String type /^[a-zA-Z]+$/"},
+		{`"co1": "a:b"`, `"co1": "a"`, 6, 10, "Test.co1", "Value mismatch in String type"},
+		{`"intarray": [1, 2, 3]`, `"intarray": [1, 2, 3, 4]`, 6, 57, "Test.intarray", "Array is larger than the specified maximum size 3"},
+		{`"my_int_array": [1, 2, 3]`, `"my_int_array": [1, true, 3]`, 3, 23, "Test.my_int_array[1]", "Bad Int32"},
+		{`"myuuid": "7829db01`, `"myuuid": "X829db01`, 4, 13, "Test.myuuid", "Bad UUID"},
+		{`"sym": "foobar"`, `"sym": 1`, 7, 76, "Test.symtest.sym", "Bad Symbol"},
+		{`"name": "a", `, ``, 1, 1, "Test", "Field missing: name"},
+		{`"bool": false`, `"bool": null`, 4, 76, "Test.bool", "Field cannot be null: bool"},
+		{`"mydbl": 25.23`, `"mydbl": 25.23,,`, 2, 81, "Test", "invalid character ',' looking for beginning of value"},
+		{"\n}", "\n} {}", 8, 3, "", "Unexpected data after the value"},
+		{"\n}", "", 7, 85, "Test", "unexpected end of JSON input"},
+	}
+	for _, c := range cases {
+		j := strings.Replace(testJSON, c.from, c.to, 1)
+		_, jerr := decodeTestJSON(test, schema, "Test", j)
+		if jerr == nil {
+			test.Errorf("Expected an error for %s", c.to)
+			continue
+		}
+		if jerr.Line != c.line || jerr.Column != c.col || jerr.Context != c.context || jerr.Message != c.message {
+			test.Errorf("Expected %d:%d: %s: %s for %s, got %v", c.line, c.col, c.context, c.message, c.to, jerr)
+		}
+	}
+}
+
+func TestDecodeJSONUnion(test *testing.T) {
+	src := `name test;
+type Point Struct { Int32 x; Int32 y; }
+type Rect Struct { Point p1; Point p2; }
+type Polyline Struct { Array<Point> points; }
+type Shape Union<Rect,Polyline>;
+type Drawing Struct { Array<Shape> shapes; }
+`
+	schema, err := parseRDL(nil, "union.rdl", strings.NewReader(src), false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse the schema: %v", err)
+	}
+	j := `{"shapes": [{"p1": {"x": 1, "y": 2}, "p2": {"x": 3, "y": 4}}, {"Polyline": {"points": [{"x": 5, "y": 6}]}}]}`
+	val, err := DecodeJSON(schema, "Drawing", strings.NewReader(j))
+	if err != nil {
+		test.Fatalf("Cannot decode a union: %v", err)
+	}
+	shapes := val.(map[string]interface{})["shapes"].([]interface{})
+	rect := shapes[0].(map[string]interface{})
+	line := shapes[1].(map[string]interface{})
+	if rect["p2"].(map[string]interface{})["y"] != int32(4) || len(line["points"].([]interface{})) != 1 {
+		test.Errorf("Unexpected union values: %v", shapes)
+	}
+
+	_, err = DecodeJSON(schema, "Drawing", strings.NewReader(`{"shapes": [{"p1": {"x": 1, "y": 2}}]}`))
+	if jerr, ok := err.(*JSONError); !ok || jerr.Column != 13 || !strings.Contains(jerr.Message, "not any variant of Union type Shape") {
+		test.Errorf("Expected an error for a value that is no variant, got %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+//
+// Package lint checks RDL schemas against named rules of style and good practice. Each rule can be
+// disabled, or have its severity changed, and the naming rules take the pattern names must match.
+//
+// A finding is suppressed by a comment on its line, or the line before it, of the form
+// "rdl-lint:ignore rule1,rule2". Without rule names, all findings there are suppressed.
+//
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+//
+// Rule - a named check of a schema
+//
+type Rule struct {
+	Name        string
+	Description string
+	Severity    rdl.DiagnosticSeverity
+
+	//Pattern is what names must match, for the naming rules
+	Pattern string
+
+	check func(l *linter, r *activeRule)
+}
+
+//
+// RuleConfig - the configuration of a rule. A zero Severity, or an empty Pattern, leaves the
+// rule's own.
+//
+type RuleConfig struct {
+	Disabled bool                   `json:"disabled,omitempty"`
+	Severity rdl.DiagnosticSeverity `json:"severity,omitempty"`
+	Pattern  string                 `json:"pattern,omitempty"`
+}
+
+//
+// Config - the configuration of the rules by name. Rules that are not named have their defaults.
+//
+type Config struct {
+	Rules map[string]*RuleConfig `json:"rules,omitempty"`
+}
+
+//
+// Rules - all the rules, with their defaults
+//
+func Rules() []Rule {
+	result := make([]Rule, len(rules))
+	for i, r := range rules {
+		result[i] = *r
+	}
+	return result
+}
+
+type activeRule struct {
+	*Rule
+	severity rdl.DiagnosticSeverity
+	pattern  *regexp.Regexp
+}
+
+type linter struct {
+	schema   *rdl.Schema
+	sources  *rdl.SourceMap
+	registry rdl.TypeRegistry
+	graph    *rdl.DependencyGraph
+	findings []*rdl.Diagnostic
+}
+
+//
+// Lint - check the schema with the configured rules, and return the findings, ordered by their
+// positions. Findings have the name of their rule as the Code, and spans from the source map, which
+// may be nil. The suppression comments are those the source map has. A nil config has the
+// defaults for all rules.
+//
+func Lint(schema *rdl.Schema, sources *rdl.SourceMap, config *Config) ([]*rdl.Diagnostic, error) {
+	var active []*activeRule
+	if config == nil {
+		config = &Config{}
+	}
+	for name := range config.Rules {
+		if findRule(name) == nil {
+			return nil, fmt.Errorf("lint: no such rule: %s", name)
+		}
+	}
+	for _, r := range rules {
+		a := &activeRule{Rule: r, severity: r.Severity}
+		pattern := r.Pattern
+		if rc := config.Rules[r.Name]; rc != nil {
+			if rc.Disabled {
+				continue
+			}
+			if rc.Severity != 0 {
+				a.severity = rc.Severity
+			}
+			if rc.Pattern != "" {
+				pattern = rc.Pattern
+			}
+		}
+		if pattern != "" {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("lint: bad pattern for %s: %v", r.Name, err)
+			}
+			a.pattern = re
+		}
+		active = append(active, a)
+	}
+	l := &linter{schema: schema, sources: sources, registry: rdl.NewTypeRegistry(schema), graph: rdl.NewDependencyGraph(schema)}
+	for _, a := range active {
+		a.check(l, a)
+	}
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		fi, fj := l.findings[i], l.findings[j]
+		if fi.File != fj.File {
+			return fi.File < fj.File
+		}
+		if fi.Line != fj.Line {
+			return fi.Line < fj.Line
+		}
+		return fi.Column < fj.Column
+	})
+	return l.findings, nil
+}
+
+func findRule(name string) *Rule {
+	for _, r := range rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+//report adds a finding about the first of the elements with a known position, unless it is suppressed
+func (l *linter) report(r *activeRule, msg string, elems ...interface{}) {
+	d := &rdl.Diagnostic{Severity: r.severity, Code: r.Name, Message: msg}
+	for _, elem := range elems {
+		if span := l.sources.Position(elem); span != nil {
+			d.Span = *span
+			break
+		}
+	}
+	if !l.isSuppressed(r.Name, &d.Span) {
+		l.findings = append(l.findings, d)
+	}
+}
+
+var suppressionPattern = regexp.MustCompile(`rdl-lint:ignore\b[ \t]*([\w-]+(?:[ \t]*,[ \t]*[\w-]+)*)?`)
+
+//isSuppressed looks for a suppression comment the parser found on the line of the span, or the line
+//before it
+func (l *linter) isSuppressed(rule string, span *rdl.Span) bool {
+	if span.Line == 0 {
+		return false
+	}
+	for n := span.Line; n >= span.Line-1 && n > 0; n-- {
+		for _, comment := range l.sources.Comments(span.File, n) {
+			for _, m := range suppressionPattern.FindAllStringSubmatch(comment, -1) {
+				if m[1] == "" {
+					return true
+				}
+				for _, name := range strings.Split(m[1], ",") {
+					if strings.TrimSpace(name) == rule {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+//
+// WriteText - write the findings, one per line, as "file:line:column: severity: message (rule)"
+//
+func WriteText(out io.Writer, findings []*rdl.Diagnostic) error {
+	for _, d := range findings {
+		if _, err := fmt.Fprintf(out, "%s: %s: %s (%s)\n", d.Span.String(), d.Severity, d.Message, d.Code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//
+// WriteJSON - write the findings as a JSON array
+//
+func WriteJSON(out io.Writer, findings []*rdl.Diagnostic) error {
+	if findings == nil {
+		findings = []*rdl.Diagnostic{}
+	}
+	data, err := json.MarshalIndent(findings, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", data)
+	return err
+}
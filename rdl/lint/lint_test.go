@@ -0,0 +1,161 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+func lintTestSchema(test *testing.T, config *Config) []*rdl.Diagnostic {
+	schema, sources, err := rdl.ParseRDLFileWithSourceMap("../../testdata/lint.rdl", &rdl.ParseOptions{NoWarn: true})
+	if err != nil {
+		test.Fatalf("Cannot parse lint.rdl: %v", err)
+	}
+	findings, err := Lint(schema, sources, config)
+	if err != nil {
+		test.Fatalf("Cannot lint lint.rdl: %v", err)
+	}
+	return findings
+}
+
+func TestLint(test *testing.T) {
+	var out bytes.Buffer
+	WriteText(&out, lintTestSchema(test, nil))
+	expected := `../../testdata/lint.rdl:5:1: warning: type Switch has no comment (type-comment)
+../../testdata/lint.rdl:5:1: warning: alias Switch refers to the alias Flag, rather than to Bool (alias-chain)
+../../testdata/lint.rdl:9:1: warning: type bad_name has no comment (type-comment)
+../../testdata/lint.rdl:9:1: warning: type name bad_name does not match ^[A-Z][A-Za-z0-9]*$ (type-name)
+../../testdata/lint.rdl:9:1: warning: type bad_name is not used (unused-type)
+../../testdata/lint.rdl:9:1: error: type bad_name has a bad pattern: error parsing regexp: missing closing ]: ` + "`[a-z$`" + ` (bad-pattern)
+../../testdata/lint.rdl:12:24: warning: enum symbol Color.green does not match ^[A-Z][A-Z0-9_]*$ (enum-symbol)
+../../testdata/lint.rdl:15:1: warning: type Spare has no comment (type-comment)
+../../testdata/lint.rdl:19:1: info: struct Item is used by resources, and is not closed (closed-struct)
+../../testdata/lint.rdl:21:5: info: field Item.Colour has no comment (field-comment)
+../../testdata/lint.rdl:21:5: warning: field name Item.Colour does not match ^[a-z][A-Za-z0-9]*$ (field-name)
+../../testdata/lint.rdl:32:1: warning: resource PUT /items/{name} has no exceptions (resource-exceptions)
+`
+	if out.String() != expected {
+		test.Errorf("Unexpected findings:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+//the suppression comments are those the parser found, not those of the file on disk, if there is one
+func TestLintSuppressedInSource(test *testing.T) {
+	src := "name edited;\n\n// rdl-lint:ignore type-comment\ntype Flag Bool;\n\ntype Spare Flag;\ntype Other String; // rdl-lint:ignore type-comment\n"
+	schema, sources, err := rdl.ParseRDLWithSourceMap("../../testdata/no-such-file.rdl", strings.NewReader(src), &rdl.ParseOptions{NoWarn: true})
+	if err != nil {
+		test.Fatalf("Cannot parse the schema: %v", err)
+	}
+	findings, err := Lint(schema, sources, nil)
+	if err != nil {
+		test.Fatalf("Cannot lint the schema: %v", err)
+	}
+	var out bytes.Buffer
+	WriteText(&out, findings)
+	expected := "../../testdata/no-such-file.rdl:6:1: warning: type Spare has no comment (type-comment)\n" +
+		"../../testdata/no-such-file.rdl:6:1: warning: alias Spare refers to the alias Flag, rather than to Bool (alias-chain)\n"
+	if out.String() != expected {
+		test.Errorf("Unexpected findings:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func TestLintConfig(test *testing.T) {
+	config := &Config{Rules: map[string]*RuleConfig{
+		"type-comment":  {Disabled: true},
+		"closed-struct": {Severity: rdl.SeverityError},
+		"enum-symbol":   {Pattern: "^[A-Za-z]+$"},
+		"type-name":     {Pattern: "^[A-Za-z_]+$"},
+	}}
+	findings := lintTestSchema(test, config)
+	counts := make(map[string]int)
+	for _, d := range findings {
+		counts[d.Code]++
+		if d.Code == "closed-struct" && d.Severity != rdl.SeverityError {
+			test.Errorf("Expected the configured severity, got %v", d)
+		}
+	}
+	if len(findings) != 7 || counts["type-comment"] != 0 || counts["enum-symbol"] != 0 || counts["type-name"] != 0 {
+		test.Errorf("Unexpected findings with the configuration: %v", counts)
+	}
+
+	//the configuration can be read from JSON
+	var c Config
+	if err := json.Unmarshal([]byte(`{"rules": {"closed-struct": {"severity": "error"}, "unused-type": {"disabled": true}}}`), &c); err != nil {
+		test.Fatalf("Cannot decode the configuration: %v", err)
+	}
+	if c.Rules["closed-struct"].Severity != rdl.SeverityError || !c.Rules["unused-type"].Disabled {
+		test.Errorf("Unexpected configuration: %v", c.Rules)
+	}
+
+	schema := rdl.NewSchema()
+	if _, err := Lint(schema, nil, &Config{Rules: map[string]*RuleConfig{"no-such-rule": {}}}); err == nil {
+		test.Errorf("Expected an error for an unknown rule")
+	}
+	if _, err := Lint(schema, nil, &Config{Rules: map[string]*RuleConfig{"type-name": {Pattern: "("}}}); err == nil {
+		test.Errorf("Expected an error for a bad pattern")
+	}
+}
+
+//the parser requires the body input, but a schema built otherwise may lack it
+func TestLintBuiltSchema(test *testing.T) {
+	schema := rdl.NewSchema()
+	schema.Resources = append(schema.Resources, &rdl.Resource{
+		Type:       "String",
+		Method:     "POST",
+		Path:       "/things?q={q}",
+		Inputs:     []*rdl.ResourceInput{{Name: "q", Type: "String", QueryParam: "q", Pattern: "[0-9"}},
+		Exceptions: map[string]*rdl.ExceptionDef{"BAD_REQUEST": {Type: "ResourceError"}},
+	})
+	var out bytes.Buffer
+	findings, err := Lint(schema, nil, nil)
+	if err != nil {
+		test.Fatalf("Cannot lint the schema: %v", err)
+	}
+	WriteText(&out, findings)
+	expected := "0:0: warning: resource POST /things?q={q} has no body input (body-input)\n" +
+		"0:0: error: input q of resource POST /things?q={q} has a bad pattern: error parsing regexp: missing closing ]: `[0-9$` (bad-pattern)\n"
+	if out.String() != expected {
+		test.Errorf("Unexpected findings:\n%s", out.String())
+	}
+}
+
+func TestLintJSON(test *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJSON(&out, lintTestSchema(test, &Config{Rules: map[string]*RuleConfig{"type-comment": {Disabled: true}}})[:1]); err != nil {
+		test.Fatalf("Cannot write JSON: %v", err)
+	}
+	var findings []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &findings); err != nil {
+		test.Fatalf("Cannot decode %s: %v", out.String(), err)
+	}
+	f := findings[0]
+	if len(findings) != 1 || f["severity"] != "warning" || f["code"] != "alias-chain" || f["line"] != 5.0 || !strings.HasSuffix(f["file"].(string), "lint.rdl") {
+		test.Errorf("Unexpected JSON: %s", out.String())
+	}
+	out.Reset()
+	WriteJSON(&out, nil)
+	if out.String() != "[]\n" {
+		test.Errorf("Expected an empty array, got %q", out.String())
+	}
+}
+
+func TestRules(test *testing.T) {
+	names := make(map[string]bool)
+	for _, r := range Rules() {
+		if names[r.Name] || r.Description == "" || r.Severity == 0 {
+			test.Errorf("Bad rule: %+v", r)
+		}
+		names[r.Name] = true
+	}
+	for _, name := range []string{"type-comment", "field-comment", "type-name", "field-name", "enum-symbol", "unused-type", "resource-exceptions", "body-input", "closed-struct", "bad-pattern", "alias-chain"} {
+		if !names[name] {
+			test.Errorf("Missing rule %s", name)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+var rules = []*Rule{
+	{
+		Name:        "type-comment",
+		Description: "types have comments",
+		Severity:    rdl.SeverityWarning,
+		check:       checkTypeComments,
+	},
+	{
+		Name:        "field-comment",
+		Description: "struct fields have comments",
+		Severity:    rdl.SeverityInfo,
+		check:       checkFieldComments,
+	},
+	{
+		Name:        "type-name",
+		Description: "type names match the pattern",
+		Severity:    rdl.SeverityWarning,
+		Pattern:     "^[A-Z][A-Za-z0-9]*$",
+		check:       checkTypeNames,
+	},
+	{
+		Name:        "field-name",
+		Description: "struct field names match the pattern",
+		Severity:    rdl.SeverityWarning,
+		Pattern:     "^[a-z][A-Za-z0-9]*$",
+		check:       checkFieldNames,
+	},
+	{
+		Name:        "enum-symbol",
+		Description: "enum symbols match the pattern",
+		Severity:    rdl.SeverityWarning,
+		Pattern:     "^[A-Z][A-Z0-9_]*$",
+		check:       checkEnumSymbols,
+	},
+	{
+		Name:        "unused-type",
+		Description: "types are used by other types or by resources, in a schema with resources",
+		Severity:    rdl.SeverityWarning,
+		check:       checkUnusedTypes,
+	},
+	{
+		Name:        "resource-exceptions",
+		Description: "resources declare their exceptions",
+		Severity:    rdl.SeverityWarning,
+		check:       checkResourceExceptions,
+	},
+	{
+		Name:        "body-input",
+		Description: "PUT and POST resources have a body input",
+		Severity:    rdl.SeverityWarning,
+		check:       checkBodyInputs,
+	},
+	{
+		Name:        "closed-struct",
+		Description: "struct types used by resources are closed",
+		Severity:    rdl.SeverityInfo,
+		check:       checkClosedStructs,
+	},
+	{
+		Name:        "bad-pattern",
+		Description: "the patterns of string types and resource inputs are valid regular expressions",
+		Severity:    rdl.SeverityError,
+		check:       checkPatterns,
+	},
+	{
+		Name:        "alias-chain",
+		Description: "aliases refer to types that are not themselves aliases",
+		Severity:    rdl.SeverityWarning,
+		check:       checkAliasChains,
+	},
+}
+
+//ownTypes are the types the schema defines or includes. Types from used schemas, which have
+//qualified names, are checked with those schemas.
+func (l *linter) ownTypes() []*rdl.Type {
+	var types []*rdl.Type
+	for _, t := range l.schema.Types {
+		name, _, _ := rdl.TypeInfo(t)
+		if !strings.Contains(string(name), ".") {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func hasComment(comment string) bool {
+	return strings.TrimSpace(suppressionPattern.ReplaceAllString(comment, "")) != ""
+}
+
+func checkTypeComments(l *linter, r *activeRule) {
+	for _, t := range l.ownTypes() {
+		if name, _, comment := rdl.TypeInfo(t); !hasComment(comment) {
+			l.report(r, fmt.Sprintf("type %s has no comment", name), t)
+		}
+	}
+}
+
+func checkFieldComments(l *linter, r *activeRule) {
+	for _, t := range l.ownTypes() {
+		if t.StructTypeDef == nil {
+			continue
+		}
+		for _, f := range t.StructTypeDef.Fields {
+			if !hasComment(f.Comment) {
+				l.report(r, fmt.Sprintf("field %s.%s has no comment", t.StructTypeDef.Name, f.Name), f, t)
+			}
+		}
+	}
+}
+
+func checkTypeNames(l *linter, r *activeRule) {
+	for _, t := range l.ownTypes() {
+		if name, _, _ := rdl.TypeInfo(t); !r.pattern.MatchString(string(name)) {
+			l.report(r, fmt.Sprintf("type name %s does not match %s", name, r.pattern), t)
+		}
+	}
+}
+
+func checkFieldNames(l *linter, r *activeRule) {
+	for _, t := range l.ownTypes() {
+		if t.StructTypeDef == nil {
+			continue
+		}
+		for _, f := range t.StructTypeDef.Fields {
+			if !r.pattern.MatchString(string(f.Name)) {
+				l.report(r, fmt.Sprintf("field name %s.%s does not match %s", t.StructTypeDef.Name, f.Name, r.pattern), f, t)
+			}
+		}
+	}
+}
+
+func checkEnumSymbols(l *linter, r *activeRule) {
+	for _, t := range l.ownTypes() {
+		if t.EnumTypeDef == nil {
+			continue
+		}
+		for _, e := range t.EnumTypeDef.Elements {
+			if !r.pattern.MatchString(string(e.Symbol)) {
+				l.report(r, fmt.Sprintf("enum symbol %s.%s does not match %s", t.EnumTypeDef.Name, e.Symbol, r.pattern), e, t)
+			}
+		}
+	}
+}
+
+func checkUnusedTypes(l *linter, r *activeRule) {
+	if len(l.schema.Resources) == 0 {
+		return
+	}
+	used := make(map[rdl.TypeName]bool)
+	for _, rez := range l.schema.Resources {
+		for _, name := range l.graph.ResourceDependencies(rez) {
+			used[name] = true
+		}
+	}
+	for _, t := range l.ownTypes() {
+		name, _, _ := rdl.TypeInfo(t)
+		for _, dep := range l.graph.Dependents(name) {
+			used[name] = used[name] || dep != name
+		}
+		if !used[name] {
+			l.report(r, fmt.Sprintf("type %s is not used", name), t)
+		}
+	}
+}
+
+func checkResourceExceptions(l *linter, r *activeRule) {
+	for _, rez := range l.schema.Resources {
+		if len(rez.Exceptions) == 0 {
+			l.report(r, fmt.Sprintf("resource %s %s has no exceptions", rez.Method, rez.Path), rez)
+		}
+	}
+}
+
+func isBodyInput(in *rdl.ResourceInput) bool {
+	return !in.PathParam && in.QueryParam == "" && in.Header == "" && in.Context == ""
+}
+
+func checkBodyInputs(l *linter, r *activeRule) {
+	for _, rez := range l.schema.Resources {
+		if rez.Method != "PUT" && rez.Method != "POST" {
+			continue
+		}
+		body := false
+		for _, in := range rez.Inputs {
+			body = body || isBodyInput(in)
+		}
+		if !body {
+			l.report(r, fmt.Sprintf("resource %s %s has no body input", rez.Method, rez.Path), rez)
+		}
+	}
+}
+
+//checkClosedStructs finds the struct types used by the resources, directly or through other types
+func checkClosedStructs(l *linter, r *activeRule) {
+	public := make(map[rdl.TypeName]bool)
+	for _, name := range l.graph.ResourceReachable(l.schema.Resources...) {
+		public[name] = true
+	}
+	for _, t := range l.ownTypes() {
+		if t.StructTypeDef != nil && public[t.StructTypeDef.Name] && !t.StructTypeDef.Closed {
+			l.report(r, fmt.Sprintf("struct %s is used by resources, and is not closed", t.StructTypeDef.Name), t)
+		}
+	}
+}
+
+//checkPatterns compiles the patterns as the validator does
+func checkPatterns(l *linter, r *activeRule) {
+	for _, t := range l.ownTypes() {
+		if t.StringTypeDef != nil && t.StringTypeDef.Pattern != "" {
+			if _, err := regexp.Compile("^" + t.StringTypeDef.Pattern + "$"); err != nil {
+				l.report(r, fmt.Sprintf("type %s has a bad pattern: %v", t.StringTypeDef.Name, err), t)
+			}
+		}
+	}
+	for _, rez := range l.schema.Resources {
+		for _, in := range rez.Inputs {
+			if in.Pattern != "" {
+				if _, err := regexp.Compile("^" + in.Pattern + "$"); err != nil {
+					l.report(r, fmt.Sprintf("input %s of resource %s %s has a bad pattern: %v", in.Name, rez.Method, rez.Path, err), in, rez)
+				}
+			}
+		}
+	}
+}
+
+func checkAliasChains(l *linter, r *activeRule) {
+	for _, t := range l.ownTypes() {
+		if t.AliasTypeDef == nil {
+			continue
+		}
+		if target := l.registry.FindType(t.AliasTypeDef.Type); target != nil && target.AliasTypeDef != nil {
+			l.report(r, fmt.Sprintf("alias %s refers to the alias %s, rather than to %s", t.AliasTypeDef.Name, t.AliasTypeDef.Type, target.AliasTypeDef.Type), t)
+		}
+	}
+}
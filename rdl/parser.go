@@ -7,10 +7,8 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"os"
-	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -28,7 +26,10 @@ type parser struct {
 	legacySynonyms map[string]string
 	types          []string
 	resources      []*Resource
-	verbose        bool
+	sources        *SourceMap //shared with the parsers of included and used files
+	bundle         *bundler   //collects the origins of the types, and their collisions, when bundling
+	options        *ParseOptions
+	renderer       DiagnosticRenderer
 	pedantic       bool
 	nowarn         bool
 }
@@ -37,19 +38,64 @@ func (p *parser) String() string {
 	return "<scanner " + p.scanner.Filename + ">"
 }
 
+//
+// ParseOptions - options for parsing a schema
+//
+type ParseOptions struct {
+	Verbose  bool //render diagnostics in color, with the lines of source around them
+	Pedantic bool //reject legacy syntax, rather than warn about it
+	NoWarn   bool //don't produce warnings
+
+	//CheckRoutes warns about ambiguous and shadowed routes, and other problems CheckRoutes finds
+	CheckRoutes bool
+
+	//DiagnosticHandler receives each diagnostic. Without it, warnings are written to os.Stderr.
+	DiagnosticHandler DiagnosticHandler
+
+	//Renderer formats the returned error and the warnings written to os.Stderr. The default is a
+	//TextRenderer, with color and 10 lines of context before and after in verbose mode.
+	Renderer DiagnosticRenderer
+}
+
 // ParseRDLFile parses the specified file to produce a Schema object.
 func ParseRDLFile(path string, verbose bool, pedantic bool, nowarn bool) (*Schema, error) {
-	return parseRDLFile(path, nil, verbose, pedantic, nowarn)
+	return ParseRDLFileWithOptions(path, &ParseOptions{Verbose: verbose, Pedantic: pedantic, NoWarn: nowarn})
+}
+
+// ParseRDLFileWithOptions parses the specified file to produce a Schema object. When parsing fails,
+// the error is a *ParseError.
+func ParseRDLFileWithOptions(path string, options *ParseOptions) (*Schema, error) {
+	schema, _, err := parseRDLFile(path, nil, nil, options)
+	return schema, err
+}
+
+// ParseRDLFileWithSourceMap parses the specified file like ParseRDLFileWithOptions, and also returns
+// the spans of source text that define the elements of the schema.
+func ParseRDLFileWithSourceMap(path string, options *ParseOptions) (*Schema, *SourceMap, error) {
+	return parseRDLFile(path, nil, nil, options)
+}
+
+// ParseRDLWithOptions parses the schema read from the reader. The source names it in diagnostics,
+// and files it includes or uses are found relative to it.
+func ParseRDLWithOptions(source string, reader io.Reader, options *ParseOptions) (*Schema, error) {
+	schema, _, err := parseRDLWithOptions(nil, nil, source, reader, options)
+	return schema, err
+}
+
+// ParseRDLWithSourceMap parses the schema read from the reader like ParseRDLWithOptions, and also
+// returns the spans of source text that define the elements of the schema.
+func ParseRDLWithSourceMap(source string, reader io.Reader, options *ParseOptions) (*Schema, *SourceMap, error) {
+	return parseRDLWithOptions(nil, nil, source, reader, options)
 }
 
-func parseRDLFile(path string, parent *parser, verbose bool, pedantic bool, nowarn bool) (*Schema, error) {
+func parseRDLFile(path string, parent *parser, bundle *bundler, options *ParseOptions) (*Schema, *SourceMap, error) {
 	fi, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer fi.Close()
 	reader := bufio.NewReader(fi)
-	return parseRDL(parent, path, reader, verbose, pedantic, nowarn)
+	return parseRDLWithOptions(parent, bundle, path, reader, options)
 }
 
 func isIdentRune(ch rune, i int) bool {
@@ -57,6 +103,14 @@ func isIdentRune(ch rune, i int) bool {
 }
 
 func parseRDL(parent *parser, source string, reader io.Reader, verbose bool, pedantic bool, nowarn bool) (*Schema, error) {
+	schema, _, err := parseRDLWithOptions(parent, nil, source, reader, &ParseOptions{Verbose: verbose, Pedantic: pedantic, NoWarn: nowarn})
+	return schema, err
+}
+
+func parseRDLWithOptions(parent *parser, bundle *bundler, source string, reader io.Reader, options *ParseOptions) (*Schema, *SourceMap, error) {
+	if options == nil {
+		options = &ParseOptions{}
+	}
 	p := new(parser)
 	p.legacySynonyms = map[string]string{
 		"byte":    "Int8",
@@ -68,19 +122,46 @@ func parseRDL(parent *parser, source string, reader io.Reader, verbose bool, ped
 		"boolean": "Bool",
 	}
 	p.parent = parent
-	p.verbose = verbose
-	p.pedantic = pedantic
-	p.nowarn = nowarn
+	p.bundle = bundle
+	p.options = options
+	p.pedantic = options.Pedantic
+	p.nowarn = options.NoWarn
+	p.renderer = options.Renderer
+	if p.renderer == nil {
+		if options.Verbose {
+			p.renderer = &TextRenderer{Color: true, Context: 10}
+		} else {
+			p.renderer = &TextRenderer{}
+		}
+	}
 	p.scanner = new(scanner.Scanner)
 	p.scanner.Init(reader)
 	p.scanner.Filename = source
 	p.scanner.Mode = scanner.ScanComments | scanner.ScanIdents | scanner.ScanStrings | scanner.ScanFloats
-	p.scanner.Error = func(s *scanner.Scanner, msg string) { p.error(msg) }
+	p.scanner.Error = func(s *scanner.Scanner, msg string) { p.errorCode(DiagnosticSyntax, msg) }
 	p.scanner.IsIdentRune = isIdentRune //Only works with go1.4 and later.
 	p.schema = NewSchema()
 	p.registry = newTypeRegistry(p.schema)
+	if parent != nil {
+		p.sources = parent.sources
+	} else {
+		p.sources = newSourceMap()
+	}
 	p.parseSchema()
-	return p.schema, p.err
+	if p.bundle != nil {
+		p.bundle.names[source] = p.schema.Name
+	}
+	if p.err == nil && parent == nil && options.CheckRoutes && !p.nowarn {
+		for _, d := range CheckRoutes(p.schema, p.sources) {
+			p.report(d)
+		}
+	}
+	if p.err == nil && parent == nil && !p.nowarn {
+		for _, d := range CheckDeprecations(p.schema, p.sources) {
+			p.report(d)
+		}
+	}
+	return p.schema, p.sources, p.err
 }
 
 func max(n1 int, n2 int) int {
@@ -97,57 +178,80 @@ func min(n1 int, n2 int) int {
 	return n2
 }
 
-func (p *parser) warning(msg string) {
-	if !p.nowarn {
-		s := p.formattedAnnotation(p.scanner.Pos(), msg, true)
-		fmt.Fprintln(os.Stderr, s)
+//diagnostic describes the most recently scanned token, or the current position if there is none
+func (p *parser) diagnostic(severity DiagnosticSeverity, code string, msg string) *Diagnostic {
+	start, end := p.scanner.Position, p.scanner.Pos()
+	if !start.IsValid() || start.Offset > end.Offset {
+		start = end
+	}
+	return &Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  msg,
+		Span:     p.spanFrom(start, end),
 	}
 }
 
-func (p *parser) error(msg string) {
-	s := p.formattedAnnotation(p.scanner.Pos(), msg, false)
-	p.err = fmt.Errorf(s)
+func (p *parser) spanFrom(start scanner.Position, end scanner.Position) Span {
+	return Span{File: p.scanner.Filename, Line: start.Line, Column: start.Column, EndLine: end.Line, EndColumn: end.Column}
 }
 
-func (p *parser) formattedAnnotation(pos scanner.Position, msg string, warning bool) string {
-	prefix := "Error"
-	if warning {
-		prefix = "Warning"
+//scan returns the next token, and records it in the source map if it is a comment
+func (p *parser) scan() rune {
+	tok := p.scanner.Scan()
+	if tok == scanner.Comment {
+		p.sources.addComment(p.scanner.Position, p.scanner.TokenText())
 	}
-	if len(pos.Filename) > 0 {
-		if p.verbose {
-			red := "\033[0;31m"
-			yellow := "\033[0;33m"
-			black := "\033[0;0m"
-			color := red
-			if warning {
-				color = yellow
-			}
-			data, err := ioutil.ReadFile(pos.Filename)
-			if err == nil {
-				lines := strings.Split(string(data), "\n")
-				line := pos.Line - 1
-				begin := max(0, line-10)
-				end := min(len(lines), line+10)
-				context := lines[begin:end]
-				tmp := ""
-				for i, l := range context {
-					if i+begin == line {
-						tmp += fmt.Sprintf("%s%3d\t%v\n%s", color, i+begin+1, l, black)
-					} else {
-						tmp += fmt.Sprintf("%3d\t%v\n", i+begin+1, l)
-					}
-				}
-				return fmt.Sprintf("%s%s (%s, line %d): %s%s\n%s", color, prefix, path.Base(pos.Filename), pos.Line, msg, black, tmp)
-			}
+	return tok
+}
+
+//record notes the span of an element, from the start position to the current one
+func (p *parser) record(elem interface{}, start scanner.Position) {
+	span := p.spanFrom(start, p.scanner.Pos())
+	p.sources.spans[elem] = &span
+	if t, ok := elem.(*Type); ok {
+		if def := typeDef(t); def != nil {
+			p.sources.spans[def] = &span
 		}
-		return fmt.Sprintf("%s(%s:%d): %s", prefix, filepath.Base(pos.Filename), pos.Line, msg)
 	}
-	return fmt.Sprintf("%s(line %d): %s", prefix, pos.Line, msg)
+}
+
+func (p *parser) report(d *Diagnostic) {
+	if p.options.DiagnosticHandler != nil {
+		p.options.DiagnosticHandler(d)
+	} else if d.Severity != SeverityError {
+		fmt.Fprintln(os.Stderr, p.renderer.Render(d))
+	}
+}
+
+func (p *parser) warning(code string, msg string) {
+	if !p.nowarn {
+		p.report(p.diagnostic(SeverityWarning, code, msg))
+	}
+}
+
+func (p *parser) error(msg string) {
+	p.errorCode(DiagnosticInvalid, msg)
+}
+
+func (p *parser) errorCode(code string, msg string) {
+	d := p.diagnostic(SeverityError, code, msg)
+	p.report(d)
+	p.err = &ParseError{Diagnostic: d, Text: p.renderer.Render(d)}
+}
+
+//includeError reports the failure to parse an included or used file. Errors in the file itself
+//have already been reported by the parser for it.
+func (p *parser) includeError(err error) {
+	if _, ok := err.(*ParseError); ok {
+		p.err = err
+	} else {
+		p.errorCode(DiagnosticInclude, err.Error())
+	}
 }
 
 func (p *parser) expectedError(expected string) {
-	p.error(fmt.Sprintf("expected %s, found '%s'", expected, p.scanner.TokenText()))
+	p.errorCode(DiagnosticSyntax, fmt.Sprintf("expected %s, found '%s'", expected, p.scanner.TokenText()))
 }
 
 func (p *parser) trailingComment(prev string) string {
@@ -161,7 +265,7 @@ func (p *parser) trailingComment(prev string) string {
 		}
 	}
 	if c == '/' {
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		comment, _ := p.parseComment(tok, prev)
 		return comment
 	}
@@ -169,7 +273,7 @@ func (p *parser) trailingComment(prev string) string {
 }
 
 func (p *parser) parseSchema() {
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	comment := ""
 	for tok != scanner.EOF && p.err == nil {
 		txt := p.scanner.TokenText()
@@ -205,34 +309,38 @@ func (p *parser) parseSchema() {
 			case "type":
 				typeComment := comment
 				comment = ""
+				start := p.scanner.Position
 				t := p.parseType(typeComment)
 				if t != nil {
+					p.record(t, start)
 					p.registerType(t)
 				}
 			case "resource":
 				resourceComment := comment
 				comment = ""
+				start := p.scanner.Position
 				r := p.parseResource(resourceComment)
 				if r != nil {
+					p.record(r, start)
 					p.registerResource(r)
 				}
 			default:
-				p.error("Unrecognized keyword in schema: '" + txt + "'")
+				p.errorCode(DiagnosticSyntax, "Unrecognized keyword in schema: '"+txt+"'")
 			}
 		case ';':
-			p.warning("stray ';' character")
+			p.warning(DiagnosticStray, "stray ';' character")
 		case '#':
 			if !p.acceptLegacy("'#' for line comments, use '//' instead", "use '//', not '#'") {
 				return
 			}
 			comment = p.parseLegacyComment(comment)
 		default:
-			p.error("unexpected token")
+			p.errorCode(DiagnosticSyntax, "unexpected token")
 		}
 		if p.err != nil {
 			return
 		}
-		tok = p.scanner.Scan()
+		tok = p.scan()
 
 	}
 	if p.types != nil {
@@ -265,6 +373,45 @@ func (p *parser) parseExtendedOption(options map[ExtendedAnnotation]string, optn
 	return options
 }
 
+//parseAnnotations parses the options of an element that can only have extended annotations, i.e.
+//an enum element or an exception: "(x_a, x_b="b")". It returns nil if there are none.
+func (p *parser) parseAnnotations(what string) map[ExtendedAnnotation]string {
+	var annotations map[ExtendedAnnotation]string
+	c := p.skipWhitespaceExceptNewline()
+	if c != '(' {
+		return nil
+	}
+	p.scanner.Next()
+	tok := p.scan()
+	commaExpected := false
+	for tok != ')' {
+		if commaExpected {
+			if tok != ',' {
+				p.expectedError("',' or ')'")
+				return nil
+			}
+			tok = p.scan()
+		} else {
+			commaExpected = true
+		}
+		if tok != scanner.Ident {
+			p.expectedError("option name")
+			return nil
+		}
+		optname := p.scanner.TokenText()
+		if !strings.HasPrefix(optname, "x_") {
+			p.error("Unsupported " + what + " option: " + optname)
+			return nil
+		}
+		annotations = p.parseExtendedOption(annotations, ExtendedAnnotation(optname))
+		if p.err != nil {
+			return nil
+		}
+		tok = p.scan()
+	}
+	return annotations
+}
+
 func (p *parser) parseNamespace() {
 	if p.err != nil {
 		return
@@ -359,9 +506,12 @@ func (p *parser) parseInclude() {
 		if p.includedFile(path) {
 			return
 		}
-		schema, err := parseRDLFile(path, p, p.verbose, p.pedantic, p.nowarn)
+		if p.bundle != nil {
+			p.bundle.includers[path] = p.scanner.Filename
+		}
+		schema, _, err := parseRDLFile(path, p, p.bundle, p.options)
 		if err != nil {
-			p.err = err
+			p.includeError(err)
 		} else {
 			for _, t := range schema.Types {
 				p.registerType(t)
@@ -481,15 +631,18 @@ func (p *parser) parseUse() {
 				return
 			}
 			schema = RdlSchema()
+			if p.bundle != nil {
+				p.bundle.use(path, schema)
+			}
 		} else {
 			path = filepath.Join(dir, fname)
 			if p.includedFile(path) {
 				return
 			}
-			schema, err = parseRDLFile(path, p, p.verbose, p.pedantic, p.nowarn)
+			schema, _, err = parseRDLFile(path, p, p.bundle, p.options)
 		}
 		if err != nil {
-			p.err = err
+			p.includeError(err)
 		} else {
 			prefix := string(schema.Name + ".")
 			for _, t := range schema.Types {
@@ -514,7 +667,7 @@ func (p *parser) statementEnd(comment string) string {
 
 func (p *parser) expect(expected string) bool {
 	if p.err == nil {
-		_ = p.scanner.Scan()
+		_ = p.scan()
 		txt := p.scanner.TokenText()
 		if txt != expected {
 			p.expectedError("'" + expected + "'")
@@ -527,7 +680,7 @@ func (p *parser) expect(expected string) bool {
 
 func (p *parser) identifier(expected string) Identifier {
 	if p.err == nil {
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		if tok == scanner.Ident {
 			return Identifier(p.scanner.TokenText())
 		}
@@ -538,7 +691,7 @@ func (p *parser) identifier(expected string) Identifier {
 
 func (p *parser) stringLiteral(expected string) string {
 	if p.err == nil {
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		if tok == scanner.String {
 			s := p.scanner.TokenText()
 			q, err := strconv.Unquote(s)
@@ -555,7 +708,7 @@ func (p *parser) stringLiteral(expected string) string {
 
 func (p *parser) numericLiteral(expected string) float64 {
 	if p.err == nil {
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		if tok == scanner.Int {
 			n, err := strconv.ParseInt(p.scanner.TokenText(), 10, 64)
 			if err == nil {
@@ -577,7 +730,7 @@ func (p *parser) numericLiteral(expected string) float64 {
 
 func (p *parser) int32Literal(expected string) int32 {
 	if p.err == nil {
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		if tok == scanner.Int {
 			n, err := strconv.Atoi(p.scanner.TokenText())
 			if err == nil {
@@ -602,7 +755,7 @@ func (p *parser) findLegacySynonym(context *parser, name string) *Type {
 						pp = context
 					}
 					if !pp.nowarn {
-						pp.warning("Use '" + string(tName) + "', not '" + name + "'")
+						pp.warning(DiagnosticLegacy, "Use '"+string(tName)+"', not '"+name+"'")
 					}
 					return tt
 				}
@@ -616,11 +769,14 @@ const forwardReferenceTag = "___forward_reference___"
 
 func (p *parser) registerType(t *Type) {
 	name, _, _ := TypeInfo(t)
+	if p.bundle != nil {
+		p.bundle.define(p.scanner.Filename, t)
+	}
 	prev := p.findType(TypeRef(name))
 	if prev != nil {
 		if t.AliasTypeDef != nil && t.AliasTypeDef.Type == forwardReferenceTag {
 			if !p.nowarn {
-				p.warning("redefinition of " + string(name))
+				p.warning(DiagnosticRedefinition, "redefinition of "+string(name))
 			}
 			return //we already have a def, don't need a forward reference
 		}
@@ -629,9 +785,10 @@ func (p *parser) registerType(t *Type) {
 			return
 		}
 		forwardRef := prev.AliasTypeDef != nil && prev.AliasTypeDef.Type == forwardReferenceTag
+		if p.bundle != nil && !forwardRef {
+			p.bundle.collide(name, prev, t)
+		}
 		if p.pedantic && !forwardRef {
-			fmt.Println("prev:", prev)
-			fmt.Println("t:", t)
 			p.error("conflicting definitions of " + string(name))
 		} else {
 			idx := -1
@@ -689,7 +846,7 @@ func (p *parser) parseTypeRef(expected string) TypeRef {
 		c := p.scanner.Peek()
 		if c == '.' {
 			p.scanner.Next()
-			tok := p.scanner.Scan()
+			tok := p.scan()
 			if tok == scanner.Ident {
 				sym = sym + "." + p.scanner.TokenText()
 			} else {
@@ -852,11 +1009,11 @@ func (p *parser) parseStringPatternOption(t *StringTypeDef) {
 func (p *parser) parseStringValuesOption(t *StringTypeDef) {
 	p.expect("=")
 	if p.err == nil {
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		if tok != '[' {
 			p.expectedError("array of string literals")
 		} else {
-			tok := p.scanner.Scan()
+			tok := p.scan()
 			var values []string
 			for tok != ']' && tok != scanner.EOF {
 				if tok != ',' {
@@ -872,7 +1029,7 @@ func (p *parser) parseStringValuesOption(t *StringTypeDef) {
 					}
 					values = append(values, q)
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			}
 			if len(values) > 0 {
 				t.Values = values
@@ -919,7 +1076,7 @@ func (p *parser) parseStringType(typeName Identifier, supertypeName TypeRef, com
 	p.skipWhitespaceExceptNewline()
 	if p.scanner.Peek() == '(' {
 		p.scanner.Next()
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		commaExpected := false
 		for tok != ')' {
 			if commaExpected {
@@ -927,7 +1084,7 @@ func (p *parser) parseStringType(typeName Identifier, supertypeName TypeRef, com
 					p.expectedError("',' or ')'")
 					return nil
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			} else {
 				commaExpected = true
 			}
@@ -980,7 +1137,7 @@ func (p *parser) parseStringType(typeName Identifier, supertypeName TypeRef, com
 			if p.err != nil {
 				return nil
 			}
-			tok = p.scanner.Scan()
+			tok = p.scan()
 		}
 	}
 	t.Comment = p.statementEnd(t.Comment)
@@ -993,7 +1150,7 @@ func (p *parser) parseStructOptions(typename string) map[ExtendedAnnotation]stri
 	c := p.skipWhitespaceExceptNewline()
 	if c == '(' {
 		p.scanner.Next()
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		commaExpected := false
 		for tok != ')' {
 			if commaExpected {
@@ -1001,7 +1158,7 @@ func (p *parser) parseStructOptions(typename string) map[ExtendedAnnotation]stri
 					p.expectedError("',' or ')'")
 					return nil
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			} else {
 				commaExpected = true
 			}
@@ -1025,7 +1182,7 @@ func (p *parser) parseStructOptions(typename string) map[ExtendedAnnotation]stri
 			if p.err != nil {
 				return nil
 			}
-			tok = p.scanner.Scan()
+			tok = p.scan()
 		}
 	}
 	if len(options) == 0 {
@@ -1068,7 +1225,7 @@ func (p *parser) parseStructType(typeName Identifier, supertypeName TypeRef, com
 	fcomment := ""
 	p.expect("{")
 	var fields []*StructFieldDef
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	for tok != scanner.EOF {
 		if tok == '}' {
 			break
@@ -1080,27 +1237,28 @@ func (p *parser) parseStructType(typeName Identifier, supertypeName TypeRef, com
 					return nil
 				}
 				if !p.nowarn {
-					p.warning("use '//' instead of '#'")
+					p.warning(DiagnosticLegacy, "use '//' instead of '#'")
 				}
 				fcomment = p.parseLegacyComment(fcomment)
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			case scanner.Comment:
 				fcomment, _ = p.parseComment(tok, fcomment)
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			case scanner.Ident:
 				sym := p.scanner.TokenText()
+				start := p.scanner.Position
 				if sym == "closed" {
 					if !p.nowarn {
-						p.warning("use 'type " + string(t.Name) + " Struct (closed) { ... } syntax instead")
+						p.warning(DiagnosticLegacy, "use 'type "+string(t.Name)+" Struct (closed) { ... } syntax instead")
 					}
 					isClosed = true
 					fcomment = p.statementEnd(fcomment)
-					tok = p.scanner.Scan()
+					tok = p.scan()
 				} else {
 					c = p.scanner.Peek()
 					if c == '.' {
 						p.scanner.Next()
-						tok := p.scanner.Scan()
+						tok := p.scan()
 						if tok == scanner.Ident {
 							sym = sym + "." + p.scanner.TokenText()
 						} else {
@@ -1110,7 +1268,7 @@ func (p *parser) parseStructType(typeName Identifier, supertypeName TypeRef, com
 					}
 					ft := p.findType(TypeRef(sym))
 					if ft == nil {
-						p.error("No such type: " + sym)
+						p.errorCode(DiagnosticUndefined, "No such type: "+sym)
 						return nil
 					}
 					fieldType, _, _ := TypeInfo(ft)
@@ -1119,7 +1277,8 @@ func (p *parser) parseStructType(typeName Identifier, supertypeName TypeRef, com
 					if p.err != nil {
 						return nil
 					}
-					tok = p.scanner.Scan()
+					p.record(field, start)
+					tok = p.scan()
 					fields = append(fields, field)
 				}
 			default:
@@ -1129,7 +1288,7 @@ func (p *parser) parseStructType(typeName Identifier, supertypeName TypeRef, com
 		}
 	}
 	if tok == scanner.EOF {
-		p.error("Unterminated struct definition")
+		p.errorCode(DiagnosticSyntax, "Unterminated struct definition")
 		return nil
 	}
 	t.Closed = isClosed
@@ -1142,7 +1301,7 @@ func (p *parser) parseStructType(typeName Identifier, supertypeName TypeRef, com
 
 func (p *parser) parseStructField(t *StructTypeDef, fieldType string, comment string) *StructFieldDef {
 	field := NewStructFieldDef()
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	optional := false
 	if tok == '<' {
 		switch strings.ToLower(fieldType) {
@@ -1186,7 +1345,7 @@ func (p *parser) parseStructField(t *StructTypeDef, fieldType string, comment st
 			p.error("parameterized type only supported for arrays and maps")
 			return nil
 		}
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	if tok == '.' {
 		s := p.identifier("type name")
@@ -1194,7 +1353,7 @@ func (p *parser) parseStructField(t *StructTypeDef, fieldType string, comment st
 			return nil
 		}
 		fieldType = fieldType + "." + string(s)
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	field.Type = TypeRef(fieldType)
 	if tok == scanner.Ident {
@@ -1202,7 +1361,7 @@ func (p *parser) parseStructField(t *StructTypeDef, fieldType string, comment st
 		c := p.skipWhitespaceExceptNewline()
 		if c == '(' {
 			p.scanner.Next()
-			tok = p.scanner.Scan()
+			tok = p.scan()
 			commaExpected := false
 			for tok != ')' {
 				if commaExpected {
@@ -1210,7 +1369,7 @@ func (p *parser) parseStructField(t *StructTypeDef, fieldType string, comment st
 						p.expectedError("','")
 						return nil
 					}
-					tok = p.scanner.Scan()
+					tok = p.scan()
 				} else {
 					commaExpected = true
 				}
@@ -1256,7 +1415,7 @@ func (p *parser) parseStructField(t *StructTypeDef, fieldType string, comment st
 				if p.err != nil {
 					return nil
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			}
 		}
 		field.Comment = p.statementEnd(comment)
@@ -1274,7 +1433,7 @@ func (p *parser) parseArrayType(typeName Identifier, supertypeName TypeRef, comm
 	t.Name = TypeName(typeName)
 	t.Type = TypeRef(supertypeName)
 	t.Comment = comment
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	if tok == '<' {
 		itemsType := p.typeSpec()
 		if itemsType != nil {
@@ -1282,10 +1441,10 @@ func (p *parser) parseArrayType(typeName Identifier, supertypeName TypeRef, comm
 			t.Items = TypeRef(ti)
 		}
 		p.expect(">")
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	if tok == '(' {
-		tok = p.scanner.Scan()
+		tok = p.scan()
 		commaExpected := false
 		for tok != ')' {
 			if commaExpected {
@@ -1293,7 +1452,7 @@ func (p *parser) parseArrayType(typeName Identifier, supertypeName TypeRef, comm
 					p.expectedError("',' or ')'")
 					return nil
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			} else {
 				commaExpected = true
 			}
@@ -1332,7 +1491,7 @@ func (p *parser) parseArrayType(typeName Identifier, supertypeName TypeRef, comm
 			if p.err != nil {
 				return nil
 			}
-			tok = p.scanner.Scan()
+			tok = p.scan()
 		}
 	}
 	t.Comment = p.statementEnd(t.Comment)
@@ -1344,7 +1503,7 @@ func (p *parser) parseBytesType(typeName Identifier, supertypeName TypeRef, comm
 	t.Name = TypeName(typeName)
 	t.Type = TypeRef(supertypeName)
 	t.Comment = comment
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	if tok == '[' {
 		size := p.int32Literal("byte array size, non-negative integer")
 		if size < 0 {
@@ -1356,10 +1515,10 @@ func (p *parser) parseBytesType(typeName Identifier, supertypeName TypeRef, comm
 			return nil
 		}
 		t.Size = &size
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	if tok == '(' {
-		tok = p.scanner.Scan()
+		tok = p.scan()
 		commaExpected := false
 		for tok != ')' {
 			if commaExpected {
@@ -1367,7 +1526,7 @@ func (p *parser) parseBytesType(typeName Identifier, supertypeName TypeRef, comm
 					p.expectedError("',' or ')'")
 					return nil
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			} else {
 				commaExpected = true
 			}
@@ -1410,7 +1569,7 @@ func (p *parser) parseBytesType(typeName Identifier, supertypeName TypeRef, comm
 			if p.err != nil {
 				return nil
 			}
-			tok = p.scanner.Scan()
+			tok = p.scan()
 		}
 	}
 	t.Comment = p.statementEnd(t.Comment)
@@ -1430,13 +1589,13 @@ func (p *parser) typeSpec() *Type {
 		}
 		pType := p.findType(TypeRef(tname))
 		if pType == nil {
-			p.error("Undefined type: " + tname)
+			p.errorCode(DiagnosticUndefined, "Undefined type: "+tname)
 			return nil
 		}
 		tName, _, _ := TypeInfo(pType)
 		c := p.skipWhitespaceExceptNewline()
 		if c == '\n' || c == '/' {
-			p.error("unexpected end of line")
+			p.errorCode(DiagnosticSyntax, "unexpected end of line")
 		}
 		if c == '<' {
 			p.scanner.Next()
@@ -1493,7 +1652,7 @@ func (p *parser) parseMapType(typeName Identifier, supertypeName TypeRef, commen
 	t.Name = TypeName(typeName)
 	t.Type = TypeRef(supertypeName)
 	t.Comment = comment
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	if tok == '<' {
 		tt := p.typeSpec()
 		if p.err != nil {
@@ -1525,10 +1684,10 @@ func (p *parser) parseMapType(typeName Identifier, supertypeName TypeRef, commen
 		if p.err != nil {
 			return nil
 		}
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	if tok == '(' {
-		tok = p.scanner.Scan()
+		tok = p.scan()
 		commaExpected := false
 		for tok != ')' {
 			if commaExpected {
@@ -1536,7 +1695,7 @@ func (p *parser) parseMapType(typeName Identifier, supertypeName TypeRef, commen
 					p.expectedError("',' or ')'")
 					return nil
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			} else {
 				commaExpected = true
 			}
@@ -1575,7 +1734,7 @@ func (p *parser) parseMapType(typeName Identifier, supertypeName TypeRef, commen
 			if p.err != nil {
 				return nil
 			}
-			tok = p.scanner.Scan()
+			tok = p.scan()
 		}
 	}
 	t.Comment = p.statementEnd(t.Comment)
@@ -1597,7 +1756,7 @@ func (p *parser) parseNumericType(typeName Identifier, supertypeName TypeRef, co
 	c := p.skipWhitespaceExceptNewline()
 	if c == '(' {
 		p.scanner.Next()
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		commaExpected := false
 		for tok != ')' {
 			if commaExpected {
@@ -1605,7 +1764,7 @@ func (p *parser) parseNumericType(typeName Identifier, supertypeName TypeRef, co
 					p.expectedError("',' or ')'")
 					return nil
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			} else {
 				commaExpected = true
 			}
@@ -1637,7 +1796,7 @@ func (p *parser) parseNumericType(typeName Identifier, supertypeName TypeRef, co
 			if p.err != nil {
 				return nil
 			}
-			tok = p.scanner.Scan()
+			tok = p.scan()
 		}
 	}
 	t.Comment = p.statementEnd(t.Comment)
@@ -1652,7 +1811,7 @@ func (p *parser) parseBoolType(typeName Identifier, supertypeName TypeRef, comme
 	c := p.skipWhitespaceExceptNewline()
 	if c == '(' {
 		p.scanner.Next()
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		commaExpected := false
 		for tok != ')' {
 			if commaExpected {
@@ -1660,7 +1819,7 @@ func (p *parser) parseBoolType(typeName Identifier, supertypeName TypeRef, comme
 					p.expectedError("',' or ')'")
 					return nil
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			} else {
 				commaExpected = true
 			}
@@ -1676,7 +1835,7 @@ func (p *parser) parseBoolType(typeName Identifier, supertypeName TypeRef, comme
 				p.error("Unsupported Bool option: '" + optname + "'")
 				return nil
 			}
-			tok = p.scanner.Scan()
+			tok = p.scan()
 		}
 	}
 	t.Comment = p.statementEnd(t.Comment)
@@ -1698,7 +1857,7 @@ func (p *parser) parseUnionType(typeName Identifier, supertypeName TypeRef, comm
 	t.Type = TypeRef(supertypeName)
 	t.Comment = comment
 	p.expect("<")
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	commaExpected := false
 	for tok != '>' {
 		if commaExpected {
@@ -1706,7 +1865,7 @@ func (p *parser) parseUnionType(typeName Identifier, supertypeName TypeRef, comm
 				p.expectedError("','")
 				break
 			}
-			tok = p.scanner.Scan()
+			tok = p.scan()
 		} else {
 			commaExpected = true
 		}
@@ -1716,7 +1875,7 @@ func (p *parser) parseUnionType(typeName Identifier, supertypeName TypeRef, comm
 		} else {
 			t.Variants = append(t.Variants, TypeRef(p.scanner.TokenText()))
 		}
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	c := p.skipWhitespaceExceptNewline()
 	if c == '(' {
@@ -1759,19 +1918,24 @@ func (p *parser) parseEnumType(typeName Identifier, supertypeName TypeRef, comme
 		}
 	}
 	p.expect("{")
-	tok = p.scanner.Scan()
+	tok = p.scan()
 	if tok == scanner.Comment {
 		t.Comment, _ = p.parseComment(tok, t.Comment)
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	for tok != '}' {
 		if tok == scanner.Comment {
 			comment, _ = p.parseComment(tok, comment)
 		} else if tok != scanner.Ident {
-			p.error("Enum type not terminated properly")
+			p.errorCode(DiagnosticSyntax, "Enum type not terminated properly")
 			break
 		} else {
 			symbol := p.scanner.TokenText()
+			start, end := p.scanner.Position, p.scanner.Pos()
+			annotations := p.parseAnnotations("Enum element")
+			if p.err != nil {
+				break
+			}
 			p.skipWhitespace()
 			c := p.scanner.Peek()
 			if c == ',' {
@@ -1782,11 +1946,13 @@ func (p *parser) parseEnumType(typeName Identifier, supertypeName TypeRef, comme
 			if c == '/' {
 				comment = p.trailingComment(comment)
 			}
-			el := EnumElementDef{Identifier(symbol), comment}
+			el := EnumElementDef{Symbol: Identifier(symbol), Comment: comment, Annotations: annotations}
+			span := p.spanFrom(start, end)
+			p.sources.spans[&el] = &span
 			t.Elements = append(t.Elements, &el)
 			comment = ""
 		}
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	t.Comment = p.trailingComment(t.Comment)
 	return &Type{Variant: TypeVariantEnumTypeDef, EnumTypeDef: t}
@@ -1804,7 +1970,7 @@ func (p *parser) parseResourceOptions() map[ExtendedAnnotation]string {
 	c := p.skipWhitespaceExceptNewline()
 	if c == '(' {
 		p.scanner.Next()
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		commaExpected := false
 		for tok != ')' {
 			if commaExpected {
@@ -1812,7 +1978,7 @@ func (p *parser) parseResourceOptions() map[ExtendedAnnotation]string {
 					p.expectedError("',' or ')'")
 					return nil
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			} else {
 				commaExpected = true
 			}
@@ -1836,7 +2002,7 @@ func (p *parser) parseResourceOptions() map[ExtendedAnnotation]string {
 			if p.err != nil {
 				return nil
 			}
-			tok = p.scanner.Scan()
+			tok = p.scan()
 		}
 	}
 	if len(options) == 0 {
@@ -1851,7 +2017,7 @@ func (p *parser) parseResource(comment string) *Resource {
 	r.Type = TypeRef(p.identifier("resource type"))
 	rt := p.findType(TypeRef(r.Type))
 	if rt == nil {
-		p.error("Type not found: " + string(r.Type))
+		p.errorCode(DiagnosticUndefined, "Type not found: "+string(r.Type))
 		return nil
 	}
 	method := strings.ToUpper(string(p.identifier("HTTP method")))
@@ -1875,15 +2041,14 @@ func (p *parser) parseResource(comment string) *Resource {
 			}
 		}
 		if len(options) > 0 {
-			//when the rdl model gets updated to include it
-			//r.Annotations = options
+			r.Annotations = options
 		}
 	} else if c != '{' {
 		p.expectedError("'{'")
 		return nil
 	}
 	fcomment := ""
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	for tok != scanner.EOF || p.err == nil {
 		if tok == '}' {
 			break
@@ -1897,7 +2062,7 @@ func (p *parser) parseResource(comment string) *Resource {
 					return nil
 				}
 				if !p.nowarn {
-					p.warning("use '//' instead of '#'")
+					p.warning(DiagnosticLegacy, "use '//' instead of '#'")
 				}
 				fcomment = p.parseLegacyComment(fcomment)
 			case scanner.Ident:
@@ -1930,10 +2095,11 @@ func (p *parser) parseResource(comment string) *Resource {
 					r.Async = &b
 					fcomment = ""
 				default:
+					start := p.scanner.Position
 					c := p.scanner.Peek()
 					if c == '.' {
 						p.scanner.Next()
-						tok := p.scanner.Scan()
+						tok := p.scan()
 						if tok == scanner.Ident {
 							sym = sym + "." + p.scanner.TokenText()
 						} else {
@@ -1941,7 +2107,7 @@ func (p *parser) parseResource(comment string) *Resource {
 							return nil
 						}
 					}
-					p.parseResourceParam(r, sym, fcomment)
+					p.parseResourceParam(r, sym, fcomment, start)
 					fcomment = ""
 				}
 			}
@@ -1949,7 +2115,7 @@ func (p *parser) parseResource(comment string) *Resource {
 		if p.err != nil {
 			return nil
 		}
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	for _, in := range r.Inputs {
 		if in.Type == "" {
@@ -2051,16 +2217,16 @@ func (p *parser) parsePathTemplate(r *Resource, template string) {
 	}
 }
 
-func (p *parser) parseResourceParam(r *Resource, paramTypeName string, comment string) {
+func (p *parser) parseResourceParam(r *Resource, paramTypeName string, comment string, start scanner.Position) {
 	//factor this, it's too big
 
 	paramType := p.findType(TypeRef(paramTypeName))
 	if paramType == nil {
-		p.error("Undefined type: " + paramTypeName)
+		p.errorCode(DiagnosticUndefined, "Undefined type: "+paramTypeName)
 		return
 	}
 
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	if tok == '<' {
 		if paramTypeName != "array" {
 			p.expectedError("String array")
@@ -2076,7 +2242,7 @@ func (p *parser) parseResourceParam(r *Resource, paramTypeName string, comment s
 			p.error("array parameters NYI")
 			return
 		}
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 
 	if tok != scanner.Ident {
@@ -2113,7 +2279,9 @@ func (p *parser) parseResourceParam(r *Resource, paramTypeName string, comment s
 	input.Comment = p.statementEnd(input.Comment)
 	if output {
 		p.addOutput(r, paramName, input)
+		p.record(r.Outputs[len(r.Outputs)-1], start)
 	} else {
+		p.record(input, start)
 		input.Name = Identifier(paramName)
 		if current < 0 {
 			r.Inputs = append(r.Inputs, input)
@@ -2129,6 +2297,7 @@ func (p *parser) addOutput(r *Resource, paramName string, input *ResourceInput)
 	out.Type = input.Type
 	out.Header = input.Header
 	out.Optional = input.Optional
+	out.Annotations = input.Annotations
 	r.Outputs = append(r.Outputs, out)
 }
 
@@ -2193,17 +2362,21 @@ func (p *parser) parseResourceParamOptions(tok rune, pathOrQueryParam bool, inpu
 				input.Header = s
 			case "context":
 				if !p.nowarn {
-					p.warning("Deprecated resource param option: 'context=...'.")
+					p.warning(DiagnosticDeprecated, "Deprecated resource param option: 'context=...'.")
 				}
 				p.expect("=")
 				s := p.stringLiteral("quoted context variable name")
 				input.Context = s
+			default:
+				if strings.HasPrefix(string(option), "x_") {
+					input.Annotations = p.parseExtendedOption(input.Annotations, ExtendedAnnotation(option))
+				}
 			}
 			if p.err != nil {
 				return false
 			}
 		}
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	input.Optional = optional
 	return output
@@ -2251,7 +2424,7 @@ func (p *parser) parseExceptions(r *Resource) {
 		return
 	}
 	exceptions := make(map[string]*ExceptionDef)
-	tok := p.scanner.Scan()
+	tok := p.scan()
 	for tok != scanner.EOF {
 		if tok == '}' {
 			break
@@ -2262,10 +2435,11 @@ func (p *parser) parseExceptions(r *Resource) {
 			r.Comment = cmt
 		case scanner.Ident:
 			etype := p.scanner.TokenText()
+			start := p.scanner.Position
 			ft := p.findType(TypeRef(etype))
 			if ft == nil {
 				if etype != "ResourceError" { //we generate this
-					p.error("No such type: " + etype)
+					p.errorCode(DiagnosticUndefined, "No such type: "+etype)
 				}
 			}
 			esym := p.identifier("symbol")
@@ -2274,10 +2448,15 @@ func (p *parser) parseExceptions(r *Resource) {
 			}
 			edef := NewExceptionDef()
 			edef.Type = etype
+			edef.Annotations = p.parseAnnotations("exception")
+			if p.err != nil {
+				return
+			}
 			edef.Comment = p.statementEnd("")
 			exceptions[string(esym)] = edef
+			p.record(edef, start)
 		}
-		tok = p.scanner.Scan()
+		tok = p.scan()
 	}
 	if len(exceptions) > 0 {
 		r.Exceptions = exceptions
@@ -2288,12 +2467,12 @@ func (p *parser) parseAuthorization(r *Resource) {
 	if r.Auth != nil {
 		p.error("Cannot specify more than one authorization permission per resource")
 	} else {
-		tok := p.scanner.Scan()
+		tok := p.scan()
 		if tok != '(' {
 			p.expectedError("(")
 		} else {
 			auth := NewResourceAuth()
-			tok := p.scanner.Scan()
+			tok := p.scan()
 			for tok != ')' && tok != scanner.EOF {
 				if tok != ',' {
 					s := p.scanner.TokenText()
@@ -2312,7 +2491,7 @@ func (p *parser) parseAuthorization(r *Resource) {
 						return
 					}
 				}
-				tok = p.scanner.Scan()
+				tok = p.scan()
 			}
 			r.Auth = auth
 			r.Comment = p.statementEnd(r.Comment)
@@ -2326,7 +2505,7 @@ func (p *parser) acceptLegacy(item string, warning string) bool {
 		return false
 	}
 	if !p.nowarn {
-		p.warning(warning)
+		p.warning(DiagnosticLegacy, warning)
 	}
 	return true
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"testing"
 )
 
@@ -74,3 +75,86 @@ func TestBasicTypes(test *testing.T) {
 		}
 	}
 }
+
+const elementAnnotationsSchema = `name annotations;
+
+type MyStruct Struct {
+    String name;
+}
+
+type MyEnum Enum (x_highlight, x_group="special") {
+    ONE (x_display="One") // the first enum value
+    TWO (x_deprecated, x_display="Two")
+    THREE
+}
+
+resource MyStruct GET "/structs/{name}" {
+    String name (x_sensitive); // the name of the struct
+    String token (header="X-Token", x_sensitive, x_group="auth");
+    String tag (header="ETag", out, x_display="Tag");
+    exceptions {
+        ResourceError NOT_FOUND (x_retry="false"); // no such struct
+        ResourceError BAD_REQUEST;
+    }
+}
+`
+
+func TestElementAnnotations(test *testing.T) {
+	schema, err := parseRDL(nil, "annotations.rdl", strings.NewReader(elementAnnotationsSchema), false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse the schema: %v", err)
+	}
+	reg := NewTypeRegistry(schema)
+	elements := reg.FindType("MyEnum").EnumTypeDef.Elements
+	if elements[0].Annotations["x_display"] != "One" || elements[0].Comment != "the first enum value" {
+		test.Errorf("Wrong annotations for the enum element ONE: %v", elements[0])
+	}
+	if _, ok := elements[1].Annotations["x_deprecated"]; !ok || elements[1].Annotations["x_display"] != "Two" {
+		test.Errorf("Wrong annotations for the enum element TWO: %v", elements[1].Annotations)
+	}
+	if elements[2].Annotations != nil {
+		test.Errorf("Unexpected annotations for the enum element THREE: %v", elements[2].Annotations)
+	}
+	r := schema.Resources[0]
+	if _, ok := r.Inputs[0].Annotations["x_sensitive"]; !ok || r.Inputs[0].Comment != "the name of the struct" {
+		test.Errorf("Wrong annotations for the path parameter: %v", r.Inputs[0])
+	}
+	if r.Inputs[1].Header != "X-Token" || r.Inputs[1].Annotations["x_group"] != "auth" {
+		test.Errorf("Wrong annotations for the header input: %v", r.Inputs[1])
+	}
+	if r.Outputs[0].Header != "ETag" || r.Outputs[0].Annotations["x_display"] != "Tag" {
+		test.Errorf("Wrong annotations for the output: %v", r.Outputs[0])
+	}
+	if e := r.Exceptions["NOT_FOUND"]; e.Annotations["x_retry"] != "false" || e.Comment != "no such struct" {
+		test.Errorf("Wrong annotations for the exception: %v", e)
+	}
+	if r.Exceptions["BAD_REQUEST"].Annotations != nil {
+		test.Errorf("Unexpected annotations for the exception: %v", r.Exceptions["BAD_REQUEST"].Annotations)
+	}
+
+	//the annotations survive export to JSON
+	data, err := json.Marshal(schema)
+	if err != nil {
+		test.Fatalf("Cannot marshal the schema: %v", err)
+	}
+	var schema2 Schema
+	if err = json.Unmarshal(data, &schema2); err != nil {
+		test.Fatalf("Cannot unmarshal the schema: %v", err)
+	}
+	if s := CompareSchemas(schema, &schema2); s != "" {
+		test.Errorf("The schema changed in JSON: %s", s)
+	}
+	schema2.Resources[0].Outputs[0].Annotations["x_display"] = "ETag"
+	if s := CompareSchemas(schema, &schema2); !strings.Contains(s, "GET /structs/{name} output tag") {
+		test.Errorf("Expected the output annotations to differ, got %q", s)
+	}
+	delete(NewTypeRegistry(&schema2).FindType("MyEnum").EnumTypeDef.Elements[1].Annotations, "x_deprecated")
+	schema2.Resources[0].Outputs[0].Annotations["x_display"] = "Tag"
+	if s := CompareSchemas(schema, &schema2); !strings.Contains(s, "MyEnum.TWO") {
+		test.Errorf("Expected the enum element annotations to differ, got %q", s)
+	}
+
+	if _, err = parseRDL(nil, "test", strings.NewReader("type E Enum { A (closed) }"), false, false, true); err == nil || !strings.Contains(err.Error(), "Unsupported Enum element option: closed") {
+		test.Errorf("Expected an error for an enum element option, got %v", err)
+	}
+}
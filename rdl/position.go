@@ -0,0 +1,113 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"fmt"
+	"text/scanner"
+)
+
+//
+// Span - a range of source text. Lines and columns start at 1, and the end position, when known,
+// is just past the text.
+//
+type Span struct {
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+}
+
+func (s *Span) String() string {
+	if s.File == "" {
+		return fmt.Sprintf("%d:%d", s.Line, s.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", s.File, s.Line, s.Column)
+}
+
+//
+// Contains - true if the line and column are within the span
+//
+func (s *Span) Contains(line int, column int) bool {
+	if line < s.Line || line == s.Line && column < s.Column {
+		return false
+	}
+	return line < s.EndLine || line == s.EndLine && column < s.EndColumn
+}
+
+//
+// SourceMap - the spans of source text that define the elements of a schema produced by the parser,
+// and the comments of that text, which the schema does not keep. It is kept apart from the schema,
+// so that the schema model is not changed, and is returned with the schema by ParseRDLWithSourceMap
+// and ParseRDLFileWithSourceMap. A nil SourceMap has no spans.
+//
+type SourceMap struct {
+	spans    map[interface{}]*Span
+	comments map[string]map[int][]string //the text of the comments, by file and starting line
+}
+
+func newSourceMap() *SourceMap {
+	return &SourceMap{spans: make(map[interface{}]*Span), comments: make(map[string]map[int][]string)}
+}
+
+func (m *SourceMap) addComment(pos scanner.Position, text string) {
+	lines := m.comments[pos.Filename]
+	if lines == nil {
+		lines = make(map[int][]string)
+		m.comments[pos.Filename] = lines
+	}
+	lines[pos.Line] = append(lines[pos.Line], text)
+}
+
+//
+// Position - the span of source text that defines an element of the schema, or nil if it is not
+// known. The element is a *Type, or the definition it holds (i.e. a *StructTypeDef), a
+// *StructFieldDef, an *EnumElementDef, a *Resource, a *ResourceInput, a *ResourceOutput, or an
+// *ExceptionDef. Elements that came from included or used files have spans in those files.
+//
+func (m *SourceMap) Position(elem interface{}) *Span {
+	if m == nil {
+		return nil
+	}
+	return m.spans[elem]
+}
+
+//
+// Comments - the text of the comments that start on the line of the file, including the "//" or
+// "/*" that opens them, in the order they appear
+//
+func (m *SourceMap) Comments(file string, line int) []string {
+	if m == nil {
+		return nil
+	}
+	return m.comments[file][line]
+}
+
+//typeDef returns the definition a Type holds
+func typeDef(t *Type) interface{} {
+	switch t.Variant {
+	case TypeVariantBaseType:
+		return nil
+	case TypeVariantStructTypeDef:
+		return t.StructTypeDef
+	case TypeVariantMapTypeDef:
+		return t.MapTypeDef
+	case TypeVariantArrayTypeDef:
+		return t.ArrayTypeDef
+	case TypeVariantEnumTypeDef:
+		return t.EnumTypeDef
+	case TypeVariantUnionTypeDef:
+		return t.UnionTypeDef
+	case TypeVariantStringTypeDef:
+		return t.StringTypeDef
+	case TypeVariantBytesTypeDef:
+		return t.BytesTypeDef
+	case TypeVariantNumberTypeDef:
+		return t.NumberTypeDef
+	case TypeVariantAliasTypeDef:
+		return t.AliasTypeDef
+	}
+	return nil
+}
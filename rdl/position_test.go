@@ -0,0 +1,76 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const positionTestSchema = `name pictures;
+use "polyline.rdl";
+include "names.rdl";
+
+type Color Enum { RED, GREEN }
+type Picture Struct {
+    SimpleName name;
+    test.Polyline outline (optional);
+}
+resource Picture GET "/pictures/{name}" {
+    SimpleName name;
+    String etag (header="ETag", out);
+    exceptions {
+        ResourceError NOT_FOUND;
+    }
+}
+`
+
+func checkSpan(test *testing.T, what string, span *Span, file string, line int, column int, endLine int, endColumn int) {
+	if span == nil {
+		test.Errorf("No position for %s", what)
+	} else if filepath.Base(span.File) != file || span.Line != line || span.Column != column || span.EndLine != endLine || span.EndColumn != endColumn {
+		test.Errorf("Expected %s at %s:%d:%d-%d:%d, got %s-%d:%d", what, file, line, column, endLine, endColumn, span, span.EndLine, span.EndColumn)
+	}
+}
+
+func TestPosition(test *testing.T) {
+	schema, sources, err := ParseRDLWithSourceMap("../testdata/pictures.rdl", strings.NewReader(positionTestSchema), &ParseOptions{NoWarn: true})
+	if err != nil {
+		test.Fatalf("Cannot parse the schema: %v", err)
+	}
+	types := make(map[TypeName]*Type)
+	for _, t := range schema.Types {
+		name, _, _ := TypeInfo(t)
+		types[name] = t
+	}
+	checkSpan(test, "Color", sources.Position(types["Color"]), "pictures.rdl", 5, 1, 5, 31)
+	checkSpan(test, "GREEN", sources.Position(types["Color"].EnumTypeDef.Elements[1]), "pictures.rdl", 5, 24, 5, 29)
+	picture := types["Picture"].StructTypeDef
+	checkSpan(test, "Picture", sources.Position(picture), "pictures.rdl", 6, 1, 9, 2)
+	checkSpan(test, "Picture.outline", sources.Position(picture.Fields[1]), "pictures.rdl", 8, 5, 8, 38)
+
+	//included and used types are in their own files
+	checkSpan(test, "SimpleName", sources.Position(types["SimpleName"]), "names.rdl", 1, 1, 1, 59)
+	checkSpan(test, "test.Polyline", sources.Position(types["test.Polyline"]), "polyline.rdl", 8, 1, 10, 2)
+	checkSpan(test, "test.Polyline.points", sources.Position(types["test.Polyline"].StructTypeDef.Fields[0]), "polyline.rdl", 9, 6, 9, 26)
+
+	r := schema.Resources[0]
+	checkSpan(test, "resource", sources.Position(r), "pictures.rdl", 10, 1, 16, 2)
+	checkSpan(test, "input", sources.Position(r.Inputs[0]), "pictures.rdl", 11, 5, 11, 21)
+	checkSpan(test, "output", sources.Position(r.Outputs[0]), "pictures.rdl", 12, 5, 12, 38)
+	checkSpan(test, "exception", sources.Position(r.Exceptions["NOT_FOUND"]), "pictures.rdl", 14, 9, 14, 33)
+	if !sources.Position(r).Contains(12, 10) || sources.Position(r).Contains(9, 1) || sources.Position(r).Contains(16, 2) {
+		test.Errorf("Unexpected span containment")
+	}
+
+	//the positions are not part of the model, and are not known for other schemas
+	if sources.Position(&Type{}) != nil || sources.Position(RdlSchema().Types[0]) != nil {
+		test.Errorf("Expected no position for an element that was not parsed")
+	}
+	var none *SourceMap
+	if none.Position(r) != nil {
+		test.Errorf("Expected no position without a source map")
+	}
+}
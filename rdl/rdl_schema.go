@@ -137,6 +137,7 @@ func init() {
 	tEnumElementDef.Comment("EnumElementDef defines one of the elements of an Enum")
 	tEnumElementDef.Field("symbol", "Identifier", false, nil, "The identifier representing the value")
 	tEnumElementDef.Field("comment", "String", true, nil, "the comment for the element")
+	tEnumElementDef.MapField("annotations", "ExtendedAnnotation", "String", true, "additional annotations starting with \"x_\"")
 	sb.AddType(tEnumElementDef.Build())
 
 	tEnumTypeDef := NewStructTypeBuilder("TypeDef", "EnumTypeDef")
@@ -176,6 +177,7 @@ func init() {
 	tResourceInput.Field("optional", "Bool", false, false, "If present, indicates that the input is optional")
 	tResourceInput.Field("flag", "Bool", false, false, "If present, indicates the queryparam is of flag style (no value)")
 	tResourceInput.Field("context", "String", true, nil, "If present, indicates the parameter comes form the implementation context")
+	tResourceInput.MapField("annotations", "ExtendedAnnotation", "String", true, "additional annotations starting with \"x_\"")
 	sb.AddType(tResourceInput.Build())
 
 	tResourceOutput := NewStructTypeBuilder("Struct", "ResourceOutput")
@@ -185,6 +187,7 @@ func init() {
 	tResourceOutput.Field("header", "String", false, nil, "the name of the header associated with this output")
 	tResourceOutput.Field("comment", "String", true, nil, "The optional comment for the output")
 	tResourceOutput.Field("optional", "Bool", false, false, "If present, indicates that the output is optional (the server decides)")
+	tResourceOutput.MapField("annotations", "ExtendedAnnotation", "String", true, "additional annotations starting with \"x_\"")
 	sb.AddType(tResourceOutput.Build())
 
 	tResourceAuth := NewStructTypeBuilder("Struct", "ResourceAuth")
@@ -199,6 +202,7 @@ func init() {
 	tExceptionDef.Comment("ExceptionDef describes the exception a symbolic response code maps to.")
 	tExceptionDef.Field("type", "String", false, nil, "The type of the exception")
 	tExceptionDef.Field("comment", "String", true, nil, "the optional comment for the exception")
+	tExceptionDef.MapField("annotations", "ExtendedAnnotation", "String", true, "additional annotations starting with \"x_\"")
 	sb.AddType(tExceptionDef.Build())
 
 	tResource := NewStructTypeBuilder("Struct", "Resource")
@@ -214,6 +218,7 @@ func init() {
 	tResource.ArrayField("alternatives", "String", true, "The set of alternative but non-error response codes")
 	tResource.MapField("exceptions", "String", "ExceptionDef", true, "A map of symbolic response code to Exception definitions")
 	tResource.Field("async", "Bool", true, nil, "A hint to server implementations that this resource would be better implemented with async I/O")
+	tResource.MapField("annotations", "ExtendedAnnotation", "String", true, "additional annotations starting with \"x_\"")
 	sb.AddType(tResource.Build())
 
 	tSchema := NewStructTypeBuilder("Struct", "Schema")
@@ -0,0 +1,224 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//a segment of a path template. A literal segment has no regex. A wildcard is a parameter whose
+//pattern matches '/', and so the rest of the path.
+type routeSegment struct {
+	literal  string
+	regex    *regexp.Regexp
+	pattern  string
+	wildcard bool
+}
+
+const defaultSegmentPattern = "[^/]+"
+
+func (s *routeSegment) isLiteral() bool {
+	return s.regex == nil
+}
+
+type route struct {
+	resource *Resource
+	segments []*routeSegment
+}
+
+//parseRoute splits the path of the resource into segments. A segment with text around a parameter,
+//or with more than one, is matched as a whole by a regex made from its parts.
+func parseRoute(r *Resource) (*route, error) {
+	rt := &route{resource: r}
+	for _, part := range strings.Split(r.Path, "/") {
+		if !strings.Contains(part, "{") {
+			rt.segments = append(rt.segments, &routeSegment{literal: part})
+			continue
+		}
+		pattern := ""
+		rest := part
+		for rest != "" {
+			i := strings.Index(rest, "{")
+			j := strings.Index(rest, "}")
+			if i < 0 {
+				pattern += regexp.QuoteMeta(rest)
+				break
+			}
+			if j < i {
+				return nil, fmt.Errorf("bad path template syntax: %s", r.Path)
+			}
+			pattern += regexp.QuoteMeta(rest[:i])
+			param := rest[i+1 : j]
+			if k := strings.Index(param, ":"); k >= 0 {
+				pattern += "(?:" + param[k+1:] + ")"
+			} else {
+				pattern += "(?:" + defaultSegmentPattern + ")"
+			}
+			rest = rest[j+1:]
+		}
+		if pattern == "(?:"+defaultSegmentPattern+")" {
+			pattern = defaultSegmentPattern
+		}
+		regex, err := regexp.Compile("^" + pattern + "$")
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern in path template %s: %v", r.Path, err)
+		}
+		rt.segments = append(rt.segments, &routeSegment{regex: regex, pattern: pattern, wildcard: regex.MatchString("a/b")})
+	}
+	return rt, nil
+}
+
+//segmentCovers is true if every value the segment b matches, a matches too. Parameters with
+//different patterns are taken not to cover each other, as that cannot be decided in general.
+func segmentCovers(a *routeSegment, b *routeSegment) bool {
+	switch {
+	case a.isLiteral():
+		return b.isLiteral() && a.literal == b.literal
+	case b.isLiteral():
+		return a.regex.MatchString(b.literal)
+	case a.wildcard != b.wildcard:
+		return a.wildcard
+	default:
+		return a.pattern == defaultSegmentPattern || a.pattern == b.pattern
+	}
+}
+
+//segmentsOverlap is true if some value is matched by both segments
+func segmentsOverlap(a *routeSegment, b *routeSegment) bool {
+	return segmentCovers(a, b) || segmentCovers(b, a)
+}
+
+//wildcardAt returns the index of the first wildcard segment of the route, or -1
+func (rt *route) wildcardAt() int {
+	for i, s := range rt.segments {
+		if s.wildcard {
+			return i
+		}
+	}
+	return -1
+}
+
+//compare the segments of the routes pairwise with the function, up to the first wildcard of a. A
+//wildcard matches the rest of the path, so b must have as many segments as a has before it.
+func (rt *route) match(other *route, f func(a *routeSegment, b *routeSegment) bool) bool {
+	a, b := rt.segments, other.segments
+	if w := rt.wildcardAt(); w >= 0 {
+		if len(b) <= w {
+			return false
+		}
+		a, b = a[:w+1], b[:w+1]
+	} else if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !f(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rt *route) covers(other *route) bool {
+	return rt.match(other, segmentCovers)
+}
+
+func (rt *route) overlaps(other *route) bool {
+	return rt.match(other, segmentsOverlap) || other.match(rt, segmentsOverlap)
+}
+
+//matchesPath is true if the route matches the path of a request
+func (rt *route) matchesPath(path string) bool {
+	parts := strings.Split(path, "/")
+	for i, s := range rt.segments {
+		switch {
+		case i >= len(parts):
+			return false
+		case s.wildcard:
+			return s.regex.MatchString(strings.Join(parts[i:], "/"))
+		case s.isLiteral():
+			if s.literal != parts[i] {
+				return false
+			}
+		case !s.regex.MatchString(parts[i]):
+			return false
+		}
+	}
+	return len(parts) == len(rt.segments)
+}
+
+func (rt *route) String() string {
+	return rt.resource.Method + " " + rt.resource.Path
+}
+
+//
+// CheckRoutes - check the resources of the schema for routes that are ambiguous with, or shadowed
+// by, the routes of earlier resources with the same method, for duplicate query parameters, for
+// path parameters without a typed input, and for patterns that do not compile, or differ from the
+// pattern of the input's type. Routes are taken to be matched in the order of the resources. The
+// diagnostics are warnings, at the positions of the resources in the source map, which may be nil.
+//
+func CheckRoutes(schema *Schema, sources *SourceMap) []*Diagnostic {
+	var diags []*Diagnostic
+	report := func(r *Resource, code string, msg string) {
+		d := &Diagnostic{Severity: SeverityWarning, Code: code, Message: msg}
+		if span := sources.Position(r); span != nil {
+			d.Span = *span
+		}
+		diags = append(diags, d)
+	}
+	registry := NewTypeRegistry(schema)
+	var routes []*route
+	for _, r := range schema.Resources {
+		rt, err := parseRoute(r)
+		if err != nil {
+			report(r, DiagnosticRoutePattern, err.Error())
+		} else {
+			for _, prev := range routes {
+				if prev.resource.Method != r.Method {
+					continue
+				}
+				if prev.covers(rt) && rt.covers(prev) {
+					report(r, DiagnosticAmbiguousRoute, fmt.Sprintf("%s is ambiguous with %s", rt, prev))
+				} else if prev.covers(rt) {
+					report(r, DiagnosticShadowedRoute, fmt.Sprintf("%s is shadowed by %s", rt, prev))
+				} else if prev.overlaps(rt) && !rt.covers(prev) {
+					report(r, DiagnosticAmbiguousRoute, fmt.Sprintf("%s overlaps %s", rt, prev))
+				}
+			}
+			routes = append(routes, rt)
+		}
+		checkRouteInputs(registry, r, report)
+	}
+	return diags
+}
+
+func checkRouteInputs(registry TypeRegistry, r *Resource, report func(r *Resource, code string, msg string)) {
+	inputs := make(map[Identifier]*ResourceInput)
+	queryParams := make(map[string]bool)
+	for _, in := range r.Inputs {
+		inputs[in.Name] = in
+		if in.QueryParam != "" {
+			if queryParams[in.QueryParam] {
+				report(r, DiagnosticDuplicateParam, fmt.Sprintf("%s %s has more than one query parameter named '%s'", r.Method, r.Path, in.QueryParam))
+			}
+			queryParams[in.QueryParam] = true
+		}
+		//patterns that do not compile are reported with the path template they are in
+		if t := registry.FindType(in.Type); in.Pattern != "" && t != nil && t.StringTypeDef != nil && t.StringTypeDef.Pattern != "" && t.StringTypeDef.Pattern != in.Pattern {
+			report(r, DiagnosticRoutePattern, fmt.Sprintf("%s %s has the pattern /%s/ for '%s', but its type %s has /%s/", r.Method, r.Path, in.Pattern, in.Name, in.Type, t.StringTypeDef.Pattern))
+		}
+	}
+	for _, part := range strings.Split(r.Path, "{")[1:] {
+		name := part
+		if i := strings.IndexAny(part, ":}"); i >= 0 {
+			name = part[:i]
+		}
+		in := inputs[Identifier(name)]
+		if in == nil || !in.PathParam || in.Type == "" {
+			report(r, DiagnosticPathParam, fmt.Sprintf("%s %s has no typed input for the path parameter '%s'", r.Method, r.Path, name))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"strings"
+	"testing"
+)
+
+const routesTestSchema = `name routes;
+type Name String (pattern="[a-z]+");
+resource String GET "/domain/{name}" {
+    String name;
+}
+resource String GET "/domain/{id}" {
+    String id;
+}
+resource String PUT "/domain/{name}" {
+    String name;
+    String body;
+}
+resource String GET "/things/default" {
+}
+resource String GET "/things/{name}" {
+    String name;
+}
+resource String GET "/files/{path:.*}" {
+    String path;
+}
+resource String GET "/files/readme/{part}" {
+    String part;
+}
+resource String GET "/p/a/{x}" {
+    String x;
+}
+resource String GET "/p/{y}/b" {
+    String y;
+}
+resource String GET "/ids/{id:[0-9]+}" {
+    String id;
+}
+resource String GET "/ids/{name:[a-z]+}" {
+    Name name;
+}
+resource String GET "/names/{n:[a-z0-9]+}/{m:[0-9}" {
+    Name n;
+    String m;
+}
+resource String GET "/search?q={q}&q={q2}" {
+    String q;
+    String q2;
+}
+`
+
+func TestCheckRoutes(test *testing.T) {
+	diags, err := collectDiagnostics("routes.rdl", routesTestSchema, &ParseOptions{CheckRoutes: true})
+	if err != nil {
+		test.Fatalf("Cannot parse the schema: %v", err)
+	}
+	var lines []string
+	for _, d := range diags {
+		lines = append(lines, d.Span.String()+" "+d.Code+": "+d.Message)
+	}
+	expected := []string{
+		"routes.rdl:6:1 ambiguous-route: GET /domain/{id} is ambiguous with GET /domain/{name}",
+		"routes.rdl:21:1 shadowed-route: GET /files/readme/{part} is shadowed by GET /files/{path:.*}",
+		"routes.rdl:27:1 ambiguous-route: GET /p/{y}/b overlaps GET /p/a/{x}",
+		"routes.rdl:36:1 route-pattern: bad pattern in path template /names/{n:[a-z0-9]+}/{m:[0-9}: error parsing regexp: missing closing ]: `[0-9)$`",
+		"routes.rdl:36:1 route-pattern: GET /names/{n:[a-z0-9]+}/{m:[0-9} has the pattern /[a-z0-9]+/ for 'n', but its type Name has /[a-z]+/",
+		"routes.rdl:40:1 duplicate-param: GET /search has more than one query parameter named 'q'",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		test.Errorf("Unexpected route diagnostics:\n%s", strings.Join(lines, "\n"))
+	}
+	for _, d := range diags {
+		if d.Severity != SeverityWarning {
+			test.Errorf("Expected a warning, got %v", d)
+		}
+	}
+
+	//the check is off by default, and off without warnings
+	if diags, _ = collectDiagnostics("routes.rdl", routesTestSchema, nil); len(diags) != 0 {
+		test.Errorf("Expected no route diagnostics by default, got %v", diags)
+	}
+	if diags, _ = collectDiagnostics("routes.rdl", routesTestSchema, &ParseOptions{CheckRoutes: true, NoWarn: true}); len(diags) != 0 {
+		test.Errorf("Expected no route diagnostics without warnings, got %v", diags)
+	}
+}
+
+func TestCheckRoutesBuilt(test *testing.T) {
+	schema := NewSchema()
+	schema.Resources = []*Resource{
+		{Type: "String", Method: "GET", Path: "/items/{id}"},
+		{Type: "String", Method: "GET", Path: "/items/{id}/parts/{part}", Inputs: []*ResourceInput{{Name: "id", Type: "String", PathParam: true}, {Name: "part", PathParam: true}}},
+	}
+	diags := CheckRoutes(schema, nil)
+	if len(diags) != 2 || diags[0].Message != "GET /items/{id} has no typed input for the path parameter 'id'" || diags[1].Message != "GET /items/{id}/parts/{part} has no typed input for the path parameter 'part'" || diags[0].Line != 0 {
+		test.Errorf("Unexpected diagnostics for path parameters: %v", diags)
+	}
+}
@@ -1133,6 +1133,11 @@ type EnumElementDef struct {
 	// the comment for the element
 	//
 	Comment string `json:"comment,omitempty" rdl:"optional"`
+
+	//
+	// additional annotations starting with "x_"
+	//
+	Annotations map[ExtendedAnnotation]string `json:"annotations,omitempty" rdl:"optional"`
 }
 
 //
@@ -1543,6 +1548,11 @@ type ResourceInput struct {
 	// If present, indicates the parameter comes form the implementation context
 	//
 	Context string `json:"context,omitempty" rdl:"optional"`
+
+	//
+	// additional annotations starting with "x_"
+	//
+	Annotations map[ExtendedAnnotation]string `json:"annotations,omitempty" rdl:"optional"`
 }
 
 //
@@ -1625,6 +1635,11 @@ type ResourceOutput struct {
 	// If present, indicates that the output is optional (the server decides)
 	//
 	Optional bool `json:"optional,omitempty" rdl:"default=false"`
+
+	//
+	// additional annotations starting with "x_"
+	//
+	Annotations map[ExtendedAnnotation]string `json:"annotations,omitempty" rdl:"optional"`
 }
 
 //
@@ -1764,6 +1779,11 @@ type ExceptionDef struct {
 	// the optional comment for the exception
 	//
 	Comment string `json:"comment,omitempty" rdl:"optional"`
+
+	//
+	// additional annotations starting with "x_"
+	//
+	Annotations map[ExtendedAnnotation]string `json:"annotations,omitempty" rdl:"optional"`
 }
 
 //
@@ -1869,6 +1889,11 @@ type Resource struct {
 	// implemented with async I/O
 	//
 	Async *bool `json:"async,omitempty" rdl:"optional"`
+
+	//
+	// additional annotations starting with "x_"
+	//
+	Annotations map[ExtendedAnnotation]string `json:"annotations,omitempty" rdl:"optional"`
 }
 
 //
@@ -0,0 +1,162 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"fmt"
+	"strings"
+)
+
+//
+// SubsetOptions - options for Subset
+//
+type SubsetOptions struct {
+
+	//Strip removes the types and struct fields with this annotation, i.e. "x_internal". It is an
+	//error for what remains to refer to a removed type, other than through a removed field.
+	Strip ExtendedAnnotation
+}
+
+//
+// Subset - a new schema with only the types reachable from the roots. A root is the name of a type,
+// or a resource, selected by its method and path, i.e. "GET /pictures/{name}". The types and
+// resources are in the order of the original schema, and are shared with it.
+//
+func Subset(schema *Schema, roots ...string) (*Schema, error) {
+	return SubsetWithOptions(schema, nil, roots...)
+}
+
+//
+// SubsetWithOptions - a new schema with only the types reachable from the roots, and without
+// what the options strip. Struct types that lose fields are copies.
+//
+func SubsetWithOptions(schema *Schema, options *SubsetOptions, roots ...string) (*Schema, error) {
+	if options == nil {
+		options = &SubsetOptions{}
+	}
+	tmp := &Schema{Name: schema.Name}
+	for _, t := range schema.Types {
+		if t = stripType(t, options.Strip); t != nil {
+			tmp.Types = append(tmp.Types, t)
+		}
+	}
+	g := NewDependencyGraph(tmp)
+	original := NewTypeRegistry(schema)
+	registry := NewTypeRegistry(tmp)
+
+	var typeRoots []TypeName
+	selected := make(map[*Resource]bool)
+	for _, root := range roots {
+		if i := strings.Index(root, " "); i > 0 {
+			method, path := strings.ToUpper(root[:i]), strings.TrimSpace(root[i+1:])
+			found := false
+			for _, r := range schema.Resources {
+				if r.Method == method && r.Path == path {
+					selected[r] = true
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("subset: no such resource: %s", root)
+			}
+		} else if t := registry.FindType(TypeRef(root)); t != nil && t.Variant != TypeVariantBaseType {
+			typeRoots = append(typeRoots, TypeName(root))
+		} else if original.FindType(TypeRef(root)) != nil {
+			return nil, fmt.Errorf("subset: the type %s is stripped", root)
+		} else {
+			return nil, fmt.Errorf("subset: no such type: %s", root)
+		}
+	}
+
+	//every reference must resolve once the stripped types are gone
+	checkRefs := func(what string, refs []TypeRef) error {
+		for _, ref := range refs {
+			if ref != "" && registry.FindType(ref) == nil && original.FindType(ref) != nil {
+				return fmt.Errorf("subset: %s refers to the stripped type %s", what, ref)
+			}
+		}
+		return nil
+	}
+	for _, r := range schema.Resources {
+		if selected[r] {
+			tmp.Resources = append(tmp.Resources, r)
+			if err := checkRefs(r.Method+" "+r.Path, resourceReferences(r)); err != nil {
+				return nil, err
+			}
+			typeRoots = append(typeRoots, g.ResourceDependencies(r)...)
+		}
+	}
+	reachable := make(map[TypeName]bool)
+	for _, name := range g.Reachable(typeRoots...) {
+		reachable[name] = true
+	}
+	subset := &Schema{
+		Namespace: schema.Namespace,
+		Name:      schema.Name,
+		Version:   schema.Version,
+		Comment:   schema.Comment,
+		Resources: tmp.Resources,
+	}
+	for _, t := range tmp.Types {
+		name, _, _ := TypeInfo(t)
+		if reachable[name] {
+			if err := checkRefs("type "+string(name), typeReferences(t)); err != nil {
+				return nil, err
+			}
+			subset.Types = append(subset.Types, t)
+		}
+	}
+	return subset, nil
+}
+
+//stripType returns nil if the type has the annotation, a copy without the fields that have it, or
+//the type itself
+func stripType(t *Type, annotation ExtendedAnnotation) *Type {
+	if annotation == "" {
+		return t
+	}
+	if _, ok := typeAnnotations(t)[annotation]; ok {
+		return nil
+	}
+	if t.StructTypeDef == nil {
+		return t
+	}
+	var fields []*StructFieldDef
+	for _, f := range t.StructTypeDef.Fields {
+		if _, ok := f.Annotations[annotation]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == len(t.StructTypeDef.Fields) {
+		return t
+	}
+	def := *t.StructTypeDef
+	def.Fields = fields
+	return &Type{Variant: TypeVariantStructTypeDef, StructTypeDef: &def}
+}
+
+//typeAnnotations returns the annotations of the definition a Type holds
+func typeAnnotations(t *Type) map[ExtendedAnnotation]string {
+	switch t.Variant {
+	case TypeVariantStructTypeDef:
+		return t.StructTypeDef.Annotations
+	case TypeVariantMapTypeDef:
+		return t.MapTypeDef.Annotations
+	case TypeVariantArrayTypeDef:
+		return t.ArrayTypeDef.Annotations
+	case TypeVariantEnumTypeDef:
+		return t.EnumTypeDef.Annotations
+	case TypeVariantUnionTypeDef:
+		return t.UnionTypeDef.Annotations
+	case TypeVariantStringTypeDef:
+		return t.StringTypeDef.Annotations
+	case TypeVariantBytesTypeDef:
+		return t.BytesTypeDef.Annotations
+	case TypeVariantNumberTypeDef:
+		return t.NumberTypeDef.Annotations
+	case TypeVariantAliasTypeDef:
+		return t.AliasTypeDef.Annotations
+	}
+	return nil
+}
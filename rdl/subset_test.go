@@ -0,0 +1,123 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package rdl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const subsetTestSchema = `name catalog;
+version 2;
+
+// the name of an item
+type Name String (pattern="[a-z]+");
+type Price Float64 (min=0);
+type Secret String (x_internal);
+type Audit Struct (x_internal) {
+    String who;
+}
+// an item in the catalog
+type Item Struct (x_owner="catalog") {
+    Name name;
+    Price price;
+    Secret cost (optional, x_internal);
+    Audit audit (optional, x_internal);
+}
+type Items Array<Item>;
+type Unrelated Struct {
+    String x;
+}
+type Report Struct {
+    Audit audit;
+}
+resource Items GET "/items" {
+    exceptions {
+        ResourceError BAD_REQUEST;
+    }
+}
+resource Item GET "/items/{name}" {
+    Name name;
+}
+type ResourceError Struct {
+    String message;
+}
+`
+
+func typeNames(schema *Schema) string {
+	var names []string
+	for _, t := range schema.Types {
+		name, _, _ := TypeInfo(t)
+		names = append(names, string(name))
+	}
+	return strings.Join(names, " ")
+}
+
+func TestSubset(test *testing.T) {
+	schema, err := parseRDL(nil, "catalog.rdl", strings.NewReader(subsetTestSchema), false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse the schema: %v", err)
+	}
+	subset, err := Subset(schema, "GET /items")
+	if err != nil {
+		test.Fatalf("Cannot make a subset: %v", err)
+	}
+	if s := typeNames(subset); s != "Name Price Secret Audit Item Items ResourceError" {
+		test.Errorf("Unexpected types in the subset: %s", s)
+	}
+	if len(subset.Resources) != 1 || subset.Resources[0].Path != "/items" || *subset.Version != 2 || subset.Name != "catalog" {
+		test.Errorf("Unexpected subset: %v", subset)
+	}
+
+	//stripping removes the internal types and fields
+	subset, err = SubsetWithOptions(schema, &SubsetOptions{Strip: "x_internal"}, "get /items/{name}", "Items")
+	if err != nil {
+		test.Fatalf("Cannot make a subset: %v", err)
+	}
+	if s := typeNames(subset); s != "Name Price Item Items" {
+		test.Errorf("Unexpected types in the stripped subset: %s", s)
+	}
+	item := subset.Types[2].StructTypeDef
+	if len(item.Fields) != 2 || item.Comment != "an item in the catalog" || item.Annotations["x_owner"] != "catalog" {
+		test.Errorf("Unexpected stripped struct: %v", item)
+	}
+	if len(schema.Types[4].StructTypeDef.Fields) != 4 {
+		test.Errorf("The original schema was changed")
+	}
+
+	//the subset is a valid schema, and validates data as the original does
+	data, _ := json.Marshal(subset)
+	var decoded Schema
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		test.Errorf("The subset does not decode: %v", err)
+	}
+	for _, v := range []interface{}{
+		map[string]interface{}{"name": "widget", "price": 1.5},
+		map[string]interface{}{"name": "Widget", "price": 1.5},
+		map[string]interface{}{"name": "widget"},
+		map[string]interface{}{"name": "widget", "price": -1.0},
+	} {
+		v1, v2 := Validate(schema, "Item", v), Validate(subset, "Item", v)
+		if v1.Error != v2.Error {
+			test.Errorf("Different validation of %v: %q and %q", v, v1.Error, v2.Error)
+		}
+	}
+
+	for _, c := range []struct {
+		roots    []string
+		expected string
+	}{
+		{[]string{"Report"}, "subset: type Report refers to the stripped type Audit"},
+		{[]string{"Secret"}, "subset: the type Secret is stripped"},
+		{[]string{"Nothing"}, "subset: no such type: Nothing"},
+		{[]string{"DELETE /items"}, "subset: no such resource: DELETE /items"},
+	} {
+		_, err = SubsetWithOptions(schema, &SubsetOptions{Strip: "x_internal"}, c.roots...)
+		if fmt.Sprint(err) != c.expected {
+			test.Errorf("Expected the error %q, got %v", c.expected, err)
+		}
+	}
+}
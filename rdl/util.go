@@ -568,27 +568,27 @@ func compareNumbers(name TypeName, n1 *Number, n2 *Number) string {
 	} else {
 		switch n1.Variant {
 		case NumberVariantInt8:
-			if n1.Int8 != n2.Int8 {
+			if n1.Int8 == nil || n2.Int8 == nil || *n1.Int8 != *n2.Int8 {
 				fail = true
 			}
 		case NumberVariantInt16:
-			if n1.Int16 != n2.Int16 {
+			if n1.Int16 == nil || n2.Int16 == nil || *n1.Int16 != *n2.Int16 {
 				fail = true
 			}
 		case NumberVariantInt32:
-			if n1.Int32 != n2.Int32 {
+			if n1.Int32 == nil || n2.Int32 == nil || *n1.Int32 != *n2.Int32 {
 				fail = true
 			}
 		case NumberVariantInt64:
-			if n1.Int64 != n2.Int64 {
+			if n1.Int64 == nil || n2.Int64 == nil || *n1.Int64 != *n2.Int64 {
 				fail = true
 			}
 		case NumberVariantFloat32:
-			if n1.Float32 != n2.Float32 {
+			if n1.Float32 == nil || n2.Float32 == nil || *n1.Float32 != *n2.Float32 {
 				fail = true
 			}
 		case NumberVariantFloat64:
-			if n1.Float64 != n2.Float64 {
+			if n1.Float64 == nil || n2.Float64 == nil || *n1.Float64 != *n2.Float64 {
 				fail = true
 			}
 		}
@@ -819,6 +819,9 @@ func compareEnumTypes(t1 *EnumTypeDef, t2 *EnumTypeDef) string {
 		if t1.Elements[i].Comment != t2.Elements[i].Comment {
 			return fmt.Sprintf("Enum types (%s) have element comment mismatch: %v vs %v", t1.Name, t1.Elements[i].Comment, t2.Elements[i].Comment)
 		}
+		if s := compareAnnotations(TypeName(fmt.Sprintf("%s.%s", t1.Name, t1.Elements[i].Symbol)), t1.Elements[i].Annotations, t2.Elements[i].Annotations); s != "" {
+			return s
+		}
 	}
 	return compareAnnotations(t1.Name, t1.Annotations, t2.Annotations)
 }
@@ -893,6 +896,46 @@ func compareResources(r1 *Resource, r2 *Resource) string {
 	if r1.Path != r2.Path {
 		return fmt.Sprintf("Resource paths differ: %v vs %v", r1, r2)
 	}
-	//fix me
+	name := r1.Method + " " + r1.Path
+	if len(r1.Inputs) != len(r2.Inputs) {
+		return fmt.Sprintf("Resource (%s) has different number of inputs: %v vs %v", name, len(r1.Inputs), len(r2.Inputs))
+	}
+	for i, in1 := range r1.Inputs {
+		in2 := r2.Inputs[i]
+		if in1.Name != in2.Name || in1.Type != in2.Type {
+			return fmt.Sprintf("Resource (%s) inputs differ: %s %s vs %s %s", name, in1.Type, in1.Name, in2.Type, in2.Name)
+		}
+		if s := compareAnnotations(TypeName(fmt.Sprintf("%s input %s", name, in1.Name)), in1.Annotations, in2.Annotations); s != "" {
+			return s
+		}
+	}
+	if len(r1.Outputs) != len(r2.Outputs) {
+		return fmt.Sprintf("Resource (%s) has different number of outputs: %v vs %v", name, len(r1.Outputs), len(r2.Outputs))
+	}
+	for i, out1 := range r1.Outputs {
+		out2 := r2.Outputs[i]
+		if out1.Name != out2.Name || out1.Type != out2.Type {
+			return fmt.Sprintf("Resource (%s) outputs differ: %s %s vs %s %s", name, out1.Type, out1.Name, out2.Type, out2.Name)
+		}
+		if s := compareAnnotations(TypeName(fmt.Sprintf("%s output %s", name, out1.Name)), out1.Annotations, out2.Annotations); s != "" {
+			return s
+		}
+	}
+	if len(r1.Exceptions) != len(r2.Exceptions) {
+		return fmt.Sprintf("Resource (%s) has different number of exceptions: %v vs %v", name, len(r1.Exceptions), len(r2.Exceptions))
+	}
+	for sym, e1 := range r1.Exceptions {
+		e2, ok := r2.Exceptions[sym]
+		if !ok || e1.Type != e2.Type {
+			return fmt.Sprintf("Resource (%s) exceptions for %s differ", name, sym)
+		}
+		if s := compareAnnotations(TypeName(fmt.Sprintf("%s exception %s", name, sym)), e1.Annotations, e2.Annotations); s != "" {
+			return s
+		}
+	}
+	if s := compareAnnotations(TypeName(name), r1.Annotations, r2.Annotations); s != "" {
+		return s
+	}
+	//fix me: the other fields of resources are not compared
 	return ""
 }
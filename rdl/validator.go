@@ -27,6 +27,10 @@ type Validation struct {
 	Error   string      `json:"error,omitempty"`
 	Value   interface{} `json:"value,omitempty"`
 	Context string      `json:"context,omitempty"`
+
+	//Deprecated describes the uses of deprecated fields and enum values in valid data, when the
+	//options of ValidateWithOptions ask for them
+	Deprecated []string `json:"deprecated,omitempty"`
 }
 
 func (v Validation) String() string {
@@ -35,16 +39,32 @@ func (v Validation) String() string {
 }
 
 type validator struct {
-	registry TypeRegistry
-	schema   *Schema
+	registry   TypeRegistry
+	schema     *Schema
+	options    *ValidateOptions
+	deprecated []string
+}
+
+// ValidateOptions are the options of ValidateWithOptions
+type ValidateOptions struct {
+	ReportDeprecated bool //list the uses of deprecated fields and enum values in Validation.Deprecated
 }
 
 // Validate tests the provided generic data against a type in the specified schema. If the typename is empty,
 // an attempt to guess the type is made, otherwise the check is done against the single type.
 func Validate(schema *Schema, typename string, data interface{}) Validation {
+	return ValidateWithOptions(schema, typename, data, nil)
+}
+
+// ValidateWithOptions tests the data against a type in the schema, as Validate does, with options.
+func ValidateWithOptions(schema *Schema, typename string, data interface{}, options *ValidateOptions) Validation {
+	if options == nil {
+		options = &ValidateOptions{}
+	}
 	checker := new(validator)
 	checker.registry = NewTypeRegistry(schema)
 	checker.schema = schema
+	checker.options = options
 
 	if schema.Types == nil {
 		return checker.bad("top level", "Schema contains no types", data, "")
@@ -56,9 +76,10 @@ func Validate(schema *Schema, typename string, data interface{}) Validation {
 		for i := len(typelist) - 1; i >= 0; i-- {
 			t := typelist[i]
 			tName, _, _ := TypeInfo(t)
+			checker.deprecated = nil
 			v := checker.validate(t, data, string(tName))
 			if v.Error == "" {
-				return v
+				return checker.withDeprecations(v)
 			}
 		}
 		return checker.bad("top level", "Cannot determine type of data in schema", data, "")
@@ -66,11 +87,25 @@ func Validate(schema *Schema, typename string, data interface{}) Validation {
 	context := typename
 	typedef := checker.registry.FindType(TypeRef(typename))
 	if typedef != nil {
-		return checker.validate(typedef, data, context)
+		return checker.withDeprecations(checker.validate(typedef, data, context))
 	}
 	return checker.bad(context, "No such type", nil, "")
 }
 
+func (checker *validator) withDeprecations(v Validation) Validation {
+	if v.Valid {
+		v.Deprecated = checker.deprecated
+	}
+	return v
+}
+
+//deprecation notes the use of a deprecated element of the data, if the options ask for it
+func (checker *validator) deprecation(d *Deprecation, context string, msg string) {
+	if d != nil && checker.options.ReportDeprecated {
+		checker.deprecated = append(checker.deprecated, context+": "+d.explain(msg))
+	}
+}
+
 func (checker *validator) resolveAliases(typedef *Type, context string) *Type {
 	for typedef.Variant == TypeVariantAliasTypeDef {
 		typedef = checker.registry.FindType(typedef.AliasTypeDef.Type)
@@ -405,6 +440,7 @@ func (checker *validator) validateStruct(t *Type, data map[string]interface{}, c
 			seen[f.Name] = f.Name
 		}
 		if d, ok := data[string(f.Name)]; ok {
+			checker.deprecation(Deprecated(f.Annotations), context+"."+string(f.Name), fmt.Sprintf("the field %s of %s is deprecated", f.Name, typedef.Name))
 			t := checker.registry.FindType(f.Type)
 			tf := checker.synthesizeFieldType(t, f)
 			v := checker.validate(tf, d, context+"."+string(f.Name))
@@ -431,6 +467,7 @@ func (checker *validator) validateEnum(t *Type, data string, context string) Val
 	typedef := t.EnumTypeDef
 	for _, e := range typedef.Elements {
 		if string(e.Symbol) == data {
+			checker.deprecation(Deprecated(e.Annotations), context, fmt.Sprintf("the value %s of %s is deprecated", e.Symbol, typedef.Name))
 			return checker.good(t, data)
 		}
 	}
@@ -459,14 +496,14 @@ func (checker *validator) validateUnion(typedef *UnionTypeDef, data interface{},
 
 func (checker *validator) good(t *Type, data interface{}) Validation {
 	tName, _, _ := TypeInfo(t)
-	return Validation{true, string(tName), "", data, ""}
+	return Validation{Valid: true, Type: string(tName), Value: data}
 
 }
 
 func (checker *validator) bad(context string, msg string, data interface{}, typename TypeName) Validation {
 	var d interface{}
 	d = data
-	v := Validation{false, string(typename), msg, d, context}
+	v := Validation{Valid: false, Type: string(typename), Error: msg, Value: d, Context: context}
 	return v
 }
 
@@ -10,8 +10,11 @@ package tbin
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"fmt"
 	"github.com/ardielle/ardielle-go/rdl"
 	"io"
+	"sync"
 )
 
 //
@@ -27,14 +30,108 @@ type TBinMarshallable interface {
 // Marshal - Marshal the specified data to TBin, returning a byte array or error.
 //
 func Marshal(data interface{}) ([]byte, error) {
-	enc := NewEncoder(nil)
+	enc := encoderPool.Get().(*Encoder)
+	enc.Encode(data)
+	b := append([]byte(nil), enc.Bytes()...)
+	err := enc.Error()
+	if enc.buf.Cap() <= maxPooledBuffer {
+		enc.Reset(nil, false)
+		encoderPool.Put(enc)
+	}
+	return b, err
+}
+
+//encoders are pooled by Marshal, except those whose buffer has grown beyond this size, which would
+//otherwise be held indefinitely
+const maxPooledBuffer = 64 * 1024
+
+var encoderPool = sync.Pool{
+	New: func() interface{} {
+		return NewEncoder(nil)
+	},
+}
+
+type pooledDecoder struct {
+	src bytes.Reader
+	dec Decoder
+}
+
+var decoderPool = sync.Pool{
+	New: func() interface{} {
+		pd := new(pooledDecoder)
+		pd.dec.opts.MaxDepth = DefaultMaxDepth
+		pd.dec.opts.MaxValues = DefaultMaxValues
+		return pd
+	},
+}
+
+//a decoder keeps its type and symbol tables for reuse, so it is pooled only if they are no larger
+//than this
+const maxPooledTypes = 1024
+
+func (pd *pooledDecoder) unmarshal(b []byte, data interface{}) error {
+	pd.src.Reset(b)
+	pd.dec.Reset(&pd.src, false)
+	err := pd.dec.Decode(data)
+	pd.src.Reset(nil)
+	return err
+}
+
+//release returns the decoder to the pool, unless the tables it retains have grown too large
+func (pd *pooledDecoder) release() bool {
+	d := &pd.dec
+	if cap(d.types) > maxPooledTypes || cap(d.syms) > maxPooledTypes || len(d.zeroWidths) > maxPooledTypes {
+		return false
+	}
+	decoderPool.Put(pd)
+	return true
+}
+
+//
+// MarshalCanonical - Marshal the specified data to the canonical TBin encoding, in which equal values
+// always produce identical bytes. See EncoderOptions.
+//
+func MarshalCanonical(data interface{}) ([]byte, error) {
+	enc := NewEncoderWithOptions(nil, &EncoderOptions{Canonical: true})
 	enc.Encode(data)
 	return enc.Bytes(), enc.Error()
 }
 
+//
+// Hash - return the SHA-256 digest of the canonical TBin encoding of the specified data, suitable
+// for content addressing and signing.
+//
+func Hash(data interface{}) ([]byte, error) {
+	b, err := MarshalCanonical(data)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+//
+// EncoderOptions - options for an Encoder.
+// In the canonical encoding, map entries and the fields of naked structs are written in order of their
+// keys, negative zero and NaN floats are normalized, and TBinMarshallable implementations are bypassed
+// in favor of reflection, since they cannot be relied upon to do the same. Typedefs and symbols are then
+// defined in an order determined by the value alone, so a new encoder writes equal values identically.
+//
+type EncoderOptions struct {
+	Canonical       bool        // produce the canonical encoding
+	Dictionary      *Dictionary // pre-shared types and symbols, which the stream need not define
+	FloatTimestamps bool        // encode timestamps as seconds in a double, as decoders before version 3 expect
+	Version         int         // the version of the stream. The default is the lowest the data needs, see Encoder
+}
+
 //
 // Encoder - the state for the encoder.
 //
+// The stream starts with the lowest version, which decoders of every version can read, and the
+// header is raised to the version the data needs until it is flushed: 2 for unsigned integers, and 3
+// for exact timestamps. After that, unsigned integers are an error, and timestamps are written as
+// doubles, unless EncoderOptions.Version is set high enough.
+//
 type Encoder struct {
 	out       io.Writer
 	buf       bytes.Buffer
@@ -46,15 +143,39 @@ type Encoder struct {
 	nextSymId int
 	tagged    bool
 	bytebuf   []byte
+	canonical bool
+	dict      *Dictionary
+	version   int //of the header, which limits what can be encoded
+	//the version the options set, if any. Otherwise the version is raised as the data needs, until the
+	//header is flushed.
+	optVersion   int
+	versionFixed bool
+	//timestamps are written as doubles, losing precision, for older decoders
+	floatTimestamps bool
 }
 
 // NewEncoder - create and return a new Encoder. This is a "session" for tbin, i.e. accumulated
 // state for this encoder can make repeated Marshal calls more efficient.
 func NewEncoder(w io.Writer) *Encoder {
+	return NewEncoderWithOptions(w, nil)
+}
+
+// NewEncoderWithOptions - create and return a new Encoder with the specified options.
+// A nil opts is the same as NewEncoder.
+func NewEncoderWithOptions(w io.Writer, opts *EncoderOptions) *Encoder {
 	enc := Encoder{syms: make(map[string]int, 0), tags: make(map[string]*tagDef, 0), nextTag: FirstUserTag}
+	if opts != nil {
+		enc.canonical = opts.Canonical
+		enc.dict = opts.Dictionary
+		enc.floatTimestamps = opts.FloatTimestamps
+	}
+	enc.setVersion(opts)
 	enc.out = w
 	enc.bytebuf = make([]byte, 32)
 	enc.writeHeader()
+	if enc.dict != nil {
+		enc.seed(enc.dict)
+	}
 	return &enc
 }
 
@@ -74,11 +195,55 @@ type TBinUnmarshallable interface {
 // it can, allocating substructure as needed.
 // In this, it tries to imitate the encoding/json behavior.
 func Unmarshal(b []byte, data interface{}) error {
+	pd := decoderPool.Get().(*pooledDecoder)
+	err := pd.unmarshal(b, data)
+	pd.release()
+	return err
+}
+
+//
+// UnmarshalWithOptions - like Unmarshal, but the decoding is constrained by the specified limits.
+// Use this for data from untrusted sources.
+//
+func UnmarshalWithOptions(b []byte, data interface{}, opts *DecoderOptions) error {
 	in := bytes.NewReader(b)
-	decoder := NewDecoder(in)
+	decoder := NewDecoderWithOptions(in, opts)
 	return decoder.Decode(data)
 }
 
+//
+// UnmarshalPath - decode just the parts of the TBin byte array that the path selects, such as
+// "items[*].x", skipping the rest. See Decoder.DecodePath.
+//
+func UnmarshalPath(b []byte, path string) ([]interface{}, error) {
+	in := bytes.NewReader(b)
+	decoder := NewDecoder(in)
+	return decoder.DecodePath(path)
+}
+
+//
+// DecoderOptions - resource limits for a Decoder. Malformed or malicious input that would exceed
+// one of them produces an error instead. A zero value for a limit means it is not checked, except
+// for MaxDepth and MaxValues, which then default to DefaultMaxDepth and DefaultMaxValues.
+//
+type DecoderOptions struct {
+	MaxDepth            int   // maximum nesting of values and type definitions
+	MaxCollectionLength int   // maximum element count of an array, map, or struct, and of a type definition
+	MaxBytes            int64 // maximum number of bytes read from the input
+	MaxTypes            int   // maximum number of type definitions in the stream
+	MaxSymbols          int   // maximum number of symbols in the stream
+	MaxValues           int   // maximum number of values and type definitions decoded or skipped in the stream
+
+	Dictionary *Dictionary // pre-shared types and symbols, which must be the ones the stream was encoded with
+}
+
+// DefaultMaxDepth is the nesting limit used when DecoderOptions.MaxDepth is not set.
+const DefaultMaxDepth = 10000
+
+// DefaultMaxValues is the work limit used when DecoderOptions.MaxValues is not set. Values such as
+// nulls take no bytes in the stream, so MaxBytes alone does not bound the work of decoding.
+const DefaultMaxValues = 1 << 26
+
 //
 // Decoder - the state for the decoder
 //
@@ -93,16 +258,77 @@ type Decoder struct {
 	err        error
 	pendingTag int
 	in         *bufio.Reader
+	opts       DecoderOptions
+	depth      int
+	values     int
+	zeroWidths map[*Signature]int
 }
 
 // NewDecoder - create and return a new Encoder. This is a "session" for tbin, i.e. accumulated
 // state for this encoder can make repeated Marshal calls more efficient.
 func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(r, nil)
+}
+
+// NewDecoderWithOptions - create and return a new Decoder that enforces the specified limits.
+// A nil opts is the same as NewDecoder.
+func NewDecoderWithOptions(r io.Reader, opts *DecoderOptions) *Decoder {
 	decoder := new(Decoder)
+	if opts != nil {
+		decoder.opts = *opts
+	}
+	if decoder.opts.MaxDepth <= 0 {
+		decoder.opts.MaxDepth = DefaultMaxDepth
+	}
+	if decoder.opts.MaxValues <= 0 {
+		decoder.opts.MaxValues = DefaultMaxValues
+	}
 	decoder.pendingTag = -1
 	decoder.syms = make([]string, 0)
-	decoder.in = bufio.NewReader(r)
+	decoder.setInput(r)
 	//	decoder.currentCursor = nil
 	decoder.readHeader()
 	return decoder
 }
+
+//limitedReader fails, rather than reporting EOF, when the limit is reached
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.remaining <= 0 {
+		return 0, fmt.Errorf("TBin data exceeds the limit of %d bytes", lr.limit)
+	}
+	if int64(len(p)) > lr.remaining {
+		p = p[:lr.remaining]
+	}
+	n, err := lr.r.Read(p)
+	lr.remaining -= int64(n)
+	return n, err
+}
+
+// MediaType is the media type of TBin data, for content negotiation.
+const MediaType = "application/tbin"
+
+// CodecDecoderOptions are the limits for decoding request bodies with the codec this package registers
+// with rdl, as clients are not trusted. The size of a body is limited by rdl.MaxRequestBodySize.
+var CodecDecoderOptions = &DecoderOptions{
+	MaxDepth:            1000,
+	MaxCollectionLength: 1 << 20,
+	MaxTypes:            1 << 12,
+	MaxSymbols:          1 << 16,
+}
+
+func init() {
+	//services that use this package can exchange TBin with clients that ask for it
+	rdl.RegisterCodec(&rdl.Codec{
+		MediaType: MediaType,
+		Marshal:   Marshal,
+		Unmarshal: func(b []byte, data interface{}) error {
+			return UnmarshalWithOptions(b, data, CodecDecoderOptions)
+		},
+	})
+}
@@ -0,0 +1,305 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+//every rdl base type, as a field of a struct
+type baseTypesValue struct {
+	Bool       bool           `json:"bool"`
+	Int8       int8           `json:"int8"`
+	Int16      int16          `json:"int16"`
+	Int32      int32          `json:"int32"`
+	Int64      int64          `json:"int64"`
+	Float32    float32        `json:"float32"`
+	Float64    float64        `json:"float64"`
+	Bytes      []byte         `json:"bytes"`
+	String     string         `json:"string"`
+	LongString string         `json:"longString"`
+	Timestamp  rdl.Timestamp  `json:"timestamp"`
+	Symbol     rdl.Symbol     `json:"symbol"`
+	UUID       rdl.UUID       `json:"uuid"`
+	Struct     rdl.Struct     `json:"struct"`
+	Enum       baseTypesEnum  `json:"enum"`
+	Any        interface{}    `json:"any"`
+	Optional   *int32         `json:"optional,omitempty" rdl:"optional"`
+	Union      baseTypesUnion `json:"union"`
+}
+
+//every rdl base type, as items of arrays and maps
+type baseTypesCollections struct {
+	Values   []*baseTypesValue          `json:"values"`
+	Bools    []bool                     `json:"bools"`
+	Symbols  []rdl.Symbol               `json:"symbols"`
+	Blobs    [][]byte                   `json:"blobs"`
+	UUIDs    []rdl.UUID                 `json:"uuids"`
+	Times    []rdl.Timestamp            `json:"times"`
+	Anys     []interface{}              `json:"anys"`
+	Enums    []baseTypesEnum            `json:"enums"`
+	Unions   []*baseTypesUnion          `json:"unions"`
+	ByName   map[string]*baseTypesValue `json:"byName"`
+	BySymbol map[rdl.Symbol][]byte      `json:"bySymbol"`
+	ByInt32  map[int32]rdl.UUID         `json:"byInt32"`
+	ByInt64  map[int64]rdl.Symbol       `json:"byInt64"`
+	Structs  map[string]rdl.Struct      `json:"structs"`
+}
+
+type baseTypesEnum int
+
+const (
+	_ baseTypesEnum = iota
+	baseTypesRed
+	baseTypesGreen
+)
+
+var namesBaseTypesEnum = []string{baseTypesRed: "RED", baseTypesGreen: "GREEN"}
+
+func (e baseTypesEnum) SymbolSet() []string {
+	return namesBaseTypesEnum
+}
+
+func (e baseTypesEnum) String() string {
+	return namesBaseTypesEnum[e]
+}
+
+func (e baseTypesEnum) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.String())
+}
+
+type baseTypesVariant int
+
+const (
+	_ baseTypesVariant = iota
+	baseTypesVariantBytes
+	baseTypesVariantSymbol
+	baseTypesVariantUUID
+	baseTypesVariantPoint
+)
+
+type baseTypesUnion struct {
+	Variant baseTypesVariant `rdl:"union"`
+	Bytes   []byte
+	Symbol  rdl.Symbol
+	UUID    rdl.UUID
+	Point   *Point
+}
+
+func (u baseTypesUnion) variant() interface{} {
+	switch u.Variant {
+	case baseTypesVariantBytes:
+		return u.Bytes
+	case baseTypesVariantSymbol:
+		return u.Symbol
+	case baseTypesVariantUUID:
+		return u.UUID
+	case baseTypesVariantPoint:
+		return u.Point
+	}
+	return nil
+}
+
+func (u baseTypesUnion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.variant())
+}
+
+//a map with non-string keys can only be written by a marshaller, the reflective encoder rejects it.
+type int32Names map[int32]string
+
+var int32NamesSignature = Map(Int32, String)
+
+func (m int32Names) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(int32NamesSignature)
+	enc.WriteSize(len(m))
+	for k, v := range m {
+		enc.WriteInt32(k)
+		enc.WriteString(v)
+	}
+	return enc.Error()
+}
+
+func baseTypesTimestamp(n int) rdl.Timestamp {
+	return rdl.NewTimestamp(time.Date(2015, 5, 14, 19, 53, n, 123000000, time.UTC))
+}
+
+func baseTypesUUID(n int) rdl.UUID {
+	return rdl.ParseUUID(fmt.Sprintf("1ce437b0-1dd2-11b2-81ef-00e06ed4%04x", n))
+}
+
+func newBaseTypesValue(n int) *baseTypesValue {
+	opt := int32(-n)
+	return &baseTypesValue{
+		Bool:       n%2 == 0,
+		Int8:       int8(-n),
+		Int16:      int16(1000 + n),
+		Int32:      int32(-100000 - n),
+		Int64:      int64(1)<<40 + int64(n),
+		Float32:    1.5 + float32(n),
+		Float64:    -2.25 * float64(n),
+		Bytes:      []byte{0, 1, 2, byte(n)},
+		String:     "tiny",
+		LongString: "a string that is too long to be encoded as a tiny string",
+		Timestamp:  baseTypesTimestamp(n),
+		Symbol:     rdl.Symbol(fmt.Sprintf("sym%d", n%3)),
+		UUID:       baseTypesUUID(n),
+		Struct:     rdl.Struct{"name": "foo", "count": int32(n), "when": baseTypesTimestamp(n)},
+		Enum:       baseTypesGreen,
+		Any:        []interface{}{"x", int64(n), rdl.Symbol("y"), []byte{9}},
+		Optional:   &opt,
+		Union:      baseTypesUnion{Variant: baseTypesVariantSymbol, Symbol: "variant"},
+	}
+}
+
+func newBaseTypesCollections() *baseTypesCollections {
+	return &baseTypesCollections{
+		Values:  []*baseTypesValue{newBaseTypesValue(1), newBaseTypesValue(2)},
+		Bools:   []bool{true, false},
+		Symbols: []rdl.Symbol{"one", "two", "one"},
+		Blobs:   [][]byte{{1, 2, 3}, {}, {255}},
+		UUIDs:   []rdl.UUID{baseTypesUUID(1), baseTypesUUID(2)},
+		Times:   []rdl.Timestamp{baseTypesTimestamp(1), baseTypesTimestamp(2)},
+		Anys:    []interface{}{int32(1), "two", 3.5, nil, map[string]interface{}{"four": int8(4)}},
+		Enums:   []baseTypesEnum{baseTypesRed, baseTypesGreen},
+		Unions: []*baseTypesUnion{
+			{Variant: baseTypesVariantBytes, Bytes: []byte("hello")},
+			{Variant: baseTypesVariantSymbol, Symbol: "sym0"},
+			{Variant: baseTypesVariantUUID, UUID: baseTypesUUID(3)},
+			{Variant: baseTypesVariantPoint, Point: &Point{X: 3, Y: -4}},
+		},
+		ByName:   map[string]*baseTypesValue{"three": newBaseTypesValue(3)},
+		BySymbol: map[rdl.Symbol][]byte{"a": {1}, "b": {2, 2}},
+		ByInt32:  map[int32]rdl.UUID{-1: baseTypesUUID(4), 7: baseTypesUUID(5)},
+		ByInt64:  map[int64]rdl.Symbol{1 << 40: "big", 0: "zero"},
+		Structs:  map[string]rdl.Struct{"s": {"id": baseTypesUUID(6), "sym": rdl.Symbol("sym1")}},
+	}
+}
+
+//normalizeGeneric converts maps with non-string keys so the result can be compared as JSON
+func normalizeGeneric(o interface{}) interface{} {
+	switch v := o.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalizeGeneric(item)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = normalizeGeneric(item)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, item := range v {
+			a[i] = normalizeGeneric(item)
+		}
+		return a
+	}
+	return o
+}
+
+//canonicalJSON marshals the value with struct fields in map (sorted) order
+func canonicalJSON(o interface{}) (string, error) {
+	j, err := json.Marshal(normalizeGeneric(o))
+	if err != nil {
+		return "", err
+	}
+	var generic interface{}
+	if err = json.Unmarshal(j, &generic); err != nil {
+		return "", err
+	}
+	j, err = json.Marshal(generic)
+	return string(j), err
+}
+
+func sameJSON(test *testing.T, msg string, expected interface{}, actual interface{}) {
+	j1, err := canonicalJSON(expected)
+	if err != nil {
+		test.Fatalf("%s: cannot marshal expected value to JSON: %v", msg, err)
+	}
+	j2, err := canonicalJSON(actual)
+	if err != nil {
+		test.Fatalf("%s: cannot marshal decoded value to JSON: %v", msg, err)
+	}
+	if j1 != j2 {
+		test.Errorf("%s: decoded value differs.\nexpected: %s\n  actual: %s", msg, j1, j2)
+	}
+}
+
+func roundTripBaseTypes(test *testing.T, msg string, data interface{}, reflectOnly bool) {
+	var tdata []byte
+	if reflectOnly {
+		enc := NewEncoder(nil)
+		enc.EncodeReflect(data)
+		tdata = enc.Bytes()
+		if enc.Error() != nil {
+			test.Fatalf("%s: cannot encode: %v", msg, enc.Error())
+		}
+	} else {
+		var err error
+		tdata, err = Marshal(data)
+		if err != nil {
+			test.Fatalf("%s: cannot encode: %v", msg, err)
+		}
+	}
+
+	var generic interface{}
+	if err := Unmarshal(tdata, &generic); err != nil {
+		test.Fatalf("%s: cannot decode generically: %v", msg, err)
+	}
+	sameJSON(test, msg+" (generic)", data, generic)
+
+	target := reflect.New(reflect.TypeOf(data).Elem())
+	if err := Unmarshal(tdata, target.Interface()); err != nil {
+		test.Fatalf("%s: cannot decode reflectively: %v", msg, err)
+	}
+	sameJSON(test, msg+" (reflect)", data, target.Interface())
+}
+
+func TestBaseTypesInStruct(test *testing.T) {
+	roundTripBaseTypes(test, "struct", newBaseTypesValue(5), false)
+	roundTripBaseTypes(test, "struct, reflect only", newBaseTypesValue(6), true)
+}
+
+func TestBaseTypesInCollections(test *testing.T) {
+	roundTripBaseTypes(test, "collections", newBaseTypesCollections(), false)
+	roundTripBaseTypes(test, "collections, reflect only", newBaseTypesCollections(), true)
+}
+
+func TestBaseTypesInUnion(test *testing.T) {
+	for _, u := range newBaseTypesCollections().Unions {
+		roundTripBaseTypes(test, fmt.Sprintf("union variant %d", u.Variant), u, true)
+	}
+}
+
+func TestNonStringMapKeys(test *testing.T) {
+	m := int32Names{1: "one", -2: "minus two", 300000: "many"}
+	tdata, err := Marshal(m)
+	if err != nil {
+		test.Fatalf("Cannot encode Map<Int32,String>: %v", err)
+	}
+	var generic interface{}
+	if err = Unmarshal(tdata, &generic); err != nil {
+		test.Fatalf("Cannot decode Map<Int32,String> generically: %v", err)
+	}
+	gm, ok := generic.(map[interface{}]interface{})
+	if !ok || len(gm) != len(m) || gm[int32(-2)] != "minus two" {
+		test.Errorf("Bad generic decode of Map<Int32,String>: %v", generic)
+	}
+	var m2 map[int32]string
+	if err = Unmarshal(tdata, &m2); err != nil {
+		test.Fatalf("Cannot decode Map<Int32,String> reflectively: %v", err)
+	}
+	if !reflect.DeepEqual(map[int32]string(m), m2) {
+		test.Errorf("Bad reflective decode of Map<Int32,String>: %v", m2)
+	}
+}
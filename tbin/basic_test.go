@@ -223,7 +223,10 @@ func TestMarshalSimpleTypes(test *testing.T) {
 	//timestamp
 	ts, _ := rdl.TimestampParse("2015-05-16T19:50:21.002Z")
 	tdata, err = Marshal(ts)
-	checkError(test, "timestamp", tdata, err, -1, []byte{24, 10, 65, 213, 85, 231, 223, 64, 32, 197})
+	checkError(test, "timestamp", tdata, err, -1, []byte{26, 10, 250, 253, 188, 213, 10, 128, 137, 122}) //version 3: seconds, nanoseconds
+	fenc := NewEncoderWithOptions(nil, &EncoderOptions{FloatTimestamps: true})
+	err = fenc.Encode(ts)
+	checkError(test, "float timestamp", fenc.Bytes(), err, -1, []byte{24, 10, 65, 213, 85, 231, 223, 64, 32, 197}) //version 1: seconds as a double
 
 	//uuid
 	u := rdl.ParseUUID("373ab4c4-fc05-11e4-a198-14109fe4729f")
@@ -260,7 +263,7 @@ func TestMarshalArrays(test *testing.T) {
 	checkError(test, "generic array of one int8", tdata, err, -1, []byte{24, 13, 1, 2, 46})
 	a = []interface{}{byte(23)}
 	tdata, err = Marshal(a)
-	checkError(test, "generic array of one byte", tdata, err, -1, []byte{24, 13, 1, 2, 46})
+	checkError(test, "generic array of one byte", tdata, err, -1, []byte{25, 13, 1, 25, 23})
 
 	//typed array
 	var aint32 []int32
@@ -275,6 +278,51 @@ func TestMarshalArrays(test *testing.T) {
 	checkError(test, "array of 3 string values", tdata, err, -1, []byte{24, 64, 17, 9, 64, 3, 3, 111, 110, 101, 3, 116, 119, 111, 5, 116, 104, 114, 101, 101})
 }
 
+type fixedArrays struct {
+	ID     [16]byte  `json:"id"`
+	Point  [3]int32  `json:"point"`
+	Bounds [2]*Point `json:"bounds"`
+}
+
+func TestFixedArrays(test *testing.T) {
+	f := &fixedArrays{Point: [3]int32{1, -2, 3}, Bounds: [2]*Point{{X: 1, Y: 2}, {X: 3, Y: 4}}}
+	copy(f.ID[:], "0123456789abcdef")
+	tdata, err := Marshal(f)
+	if err != nil {
+		test.Fatalf("Cannot marshal fixed arrays: %v", err)
+	}
+	var f2 fixedArrays
+	if err = Unmarshal(tdata, &f2); err != nil {
+		test.Fatalf("Cannot unmarshal fixed arrays: %v", err)
+	}
+	if f2.ID != f.ID || f2.Point != f.Point || f2.Bounds[0] == nil || *f2.Bounds[1] != *f.Bounds[1] {
+		test.Errorf("Fixed arrays were not restored: %+v", f2)
+	}
+	var x interface{}
+	if err = Unmarshal(tdata, &x); err != nil {
+		test.Fatalf("Cannot unmarshal fixed arrays generically: %v", err)
+	}
+	generic := x.(map[string]interface{})
+	if string(generic["id"].([]byte)) != "0123456789abcdef" || len(generic["point"].([]interface{})) != 3 {
+		test.Errorf("Unexpected generic fixed arrays: %v", generic)
+	}
+
+	//the lengths must match
+	var short struct {
+		ID    [8]byte  `json:"id"`
+		Point [3]int32 `json:"point"`
+	}
+	if err = Unmarshal(tdata, &short); err == nil {
+		test.Errorf("Expected an error for bytes longer than the array")
+	}
+	var long struct {
+		Point [4]int32 `json:"point"`
+	}
+	if err = Unmarshal(tdata, &long); err == nil {
+		test.Errorf("Expected an error for an array with more items than the data")
+	}
+}
+
 func TestMarshalMaps(test *testing.T) {
 	var tdata []byte
 	var err error
@@ -444,15 +492,15 @@ func TestMarshalMisc(test *testing.T) {
 	checkError(test, "Foo64", tdata, err, -1, []byte{0x18, 0x05, 0x2e})
 
 	tdata, err = Marshal(FooU8(23))
-	checkError(test, "FooU8", tdata, err, -1, []byte{0x18, 0x02, 0x2e})
+	checkError(test, "FooU8", tdata, err, -1, []byte{0x19, 0x19, 0x17})
 	tdata, err = Marshal(FooU16(23))
-	checkError(test, "FooU16", tdata, err, -1, []byte{0x18, 0x03, 0x2e})
+	checkError(test, "FooU16", tdata, err, -1, []byte{0x19, 0x1a, 0x17})
 	tdata, err = Marshal(FooU32(23))
-	checkError(test, "FooU32", tdata, err, -1, []byte{0x18, 0x04, 0x2e})
+	checkError(test, "FooU32", tdata, err, -1, []byte{0x19, 0x1b, 0x17})
 	tdata, err = Marshal(FooU(23))
-	checkError(test, "FooU", tdata, err, -1, []byte{0x18, 0x04, 0x2e})
+	checkError(test, "FooU", tdata, err, -1, []byte{0x19, 0x1c, 0x17})
 	tdata, err = Marshal(FooU64(23))
-	checkError(test, "FooU64", tdata, err, -1, []byte{0x18, 0x05, 0x2e})
+	checkError(test, "FooU64", tdata, err, -1, []byte{0x19, 0x1c, 0x17})
 
 }
 
@@ -20,6 +20,7 @@ Size:
  TBIN generic:              160 bytes
  Protobuf                   96 bytes (+ schema = 308)
  TBIN optimized:            70 bytes (- schema = 46 bytes, i.e. in a session where types are reused)
+ TBIN dictionary:           64 bytes (the schema replaced by a reference to a pre-shared dictionary)
  Avro                       46 bytes (+ schema = 230 bytes)
 
 TBin encodes the schema inline with the data (for its first occurence), unlike PB and Avro. This eliminates
@@ -45,6 +46,26 @@ Unmarshal ops/ns:
  TBIN generic:              20364
  TBIN unmarshallable:       10535   // common with RDL models
 
+BigTest data (testdata/bigtest.json), generated code vs reflection, ns/op:
+ TBIN marshal reflect:      33043
+ TBIN marshal codegen:      7484
+ TBIN unmarshal reflect:    74925
+ TBIN unmarshal codegen:    22062
+
+BigTest data with 100 records, partial decoding vs full decoding, ns/op:
+ TBIN generic:              1115767
+ TBIN unmarshal codegen:    311131
+ TBIN path stuff[*].myName: 188765
+ TBIN skip:                 96397
+
+Polyline allocations per op, with pooled or reset encoders and decoders vs new ones:
+ TBIN marshal new encoder:  26 allocs, 1344 bytes
+ TBIN marshal pooled:       20 allocs, 720 bytes
+ TBIN marshal keep types:   1 allocs, 16 bytes     // a session of streams, the types defined in the first
+ TBIN unmarshal new:        51 allocs, 5608 bytes
+ TBIN unmarshal pooled:     44 allocs, 1152 bytes
+ TBIN marshal reflect:      27 allocs (was 51)     // the reflection plan is cached by type
+
 */
 
 var _ = fmt.Println
@@ -79,8 +100,8 @@ func BenchmarkTBinMarshalReflect(b *testing.B) {
 }
 
 //The normal default, invoke the TBinMarshallable method when present (as it is in this test)
-//Note that the TBinMarshallable code in the example factors out the signature calculation, since
-//it is constant. So this ends up being not much slower than the CodeGen benchmark below.
+//The TBinMarshallable code is generated by GenerateMarshalCode, with the signature as a generated
+//variable. So this ends up being not much slower than the inlined CodeGen benchmark below.
 func BenchmarkTBinMarshalUser(b *testing.B) {
 	line := polyline()
 	var tdata []byte
@@ -120,6 +141,27 @@ func BenchmarkTBinMarshalCodeGen(b *testing.B) {
 
 }
 
+//with a pre-shared dictionary, the typedefs are replaced by a reference to it, which saves bytes for
+//each stream when the types are bigger than the reference
+func BenchmarkTBinMarshalDictionary(b *testing.B) {
+	line := polyline()
+	dict, err := NewDictionary("polyline/1", []*Signature{TypeSignature(line)}, nil)
+	if err != nil {
+		b.Fatalf("Cannot create dictionary: %v", err)
+	}
+	opts := &EncoderOptions{Dictionary: dict}
+	var tdata []byte
+	for n := 0; n < b.N; n++ {
+		enc := NewEncoderWithOptions(nil, opts)
+		enc.Encode(line)
+		tdata = enc.Bytes()
+	}
+	if len(tdata) != testDataLengthTBinDictionary {
+		b.Errorf("Expected %d bytes of TBIN encoded data, got %d", testDataLengthTBinDictionary, len(tdata))
+	}
+	b.ReportMetric(float64(testDataLengthTBinBest-len(tdata)), "saved-bytes/op")
+}
+
 //The normal default, invoke the MarshalJSON method when present (as it is in this test)
 func BenchmarkJsonMarshalGeneric(b *testing.B) {
 	line := polyline()
@@ -193,3 +235,167 @@ func BenchmarkTBinUnmarshalReflect(b *testing.B) {
 		dec.DecodeReflect(v)
 	}
 }
+
+//BigTest exercises most of the type variants, with the generated code in bigtest_tbin.go
+func BenchmarkTBinMarshalBigTestReflect(b *testing.B) {
+	bt := *loadBigTest(b)
+	for n := 0; n < b.N; n++ {
+		enc := NewEncoder(nil)
+		enc.EncodeReflect(bt)
+	}
+}
+
+func BenchmarkTBinMarshalBigTestCodeGen(b *testing.B) {
+	bt := *loadBigTest(b)
+	for n := 0; n < b.N; n++ {
+		Marshal(bt)
+	}
+}
+
+func BenchmarkTBinUnmarshalBigTestReflect(b *testing.B) {
+	tdata, _ := Marshal(*loadBigTest(b))
+	for n := 0; n < b.N; n++ {
+		var bt BigTest
+		dec := NewDecoder(bytes.NewBuffer(tdata))
+		dec.DecodeReflect(reflect.ValueOf(&bt).Elem())
+	}
+}
+
+func BenchmarkTBinUnmarshalBigTestCodeGen(b *testing.B) {
+	tdata, _ := Marshal(*loadBigTest(b))
+	for n := 0; n < b.N; n++ {
+		var bt BigTest
+		Unmarshal(tdata, &bt)
+	}
+}
+
+//BigTest data with many records, for the partial decoding benchmarks
+func bigTestRecords(b *testing.B) []byte {
+	bt := *loadBigTest(b)
+	stuff := bt.Stuff
+	for len(bt.Stuff) < 100 {
+		bt.Stuff = append(bt.Stuff, stuff...)
+	}
+	tdata, err := Marshal(bt)
+	if err != nil {
+		b.Fatalf("Cannot marshal BigTest: %v", err)
+	}
+	return tdata
+}
+
+func BenchmarkTBinDecodeBigTestGeneric(b *testing.B) {
+	tdata := bigTestRecords(b)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var generic interface{}
+		Unmarshal(tdata, &generic)
+	}
+}
+
+func BenchmarkTBinDecodeBigTestCodeGen(b *testing.B) {
+	tdata := bigTestRecords(b)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var bt BigTest
+		Unmarshal(tdata, &bt)
+	}
+}
+
+func BenchmarkTBinDecodeBigTestPath(b *testing.B) {
+	tdata := bigTestRecords(b)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		names, err := UnmarshalPath(tdata, "stuff[*].myName")
+		if err != nil || len(names) != 100 {
+			b.Fatalf("Expected 100 names, got %d (%v)", len(names), err)
+		}
+	}
+}
+
+func BenchmarkTBinDecodeBigTestSkip(b *testing.B) {
+	tdata := bigTestRecords(b)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		NewDecoder(bytes.NewReader(tdata)).Skip()
+	}
+}
+
+//Allocation reductions from pooling and resetting encoders and decoders, with the polyline test data
+
+func BenchmarkTBinMarshalNewEncoder(b *testing.B) {
+	line := polyline()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		enc := NewEncoder(nil)
+		enc.Encode(line)
+	}
+}
+
+func BenchmarkTBinMarshalPooled(b *testing.B) {
+	line := polyline()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		Marshal(line)
+	}
+}
+
+func BenchmarkTBinMarshalReset(b *testing.B) {
+	line := polyline()
+	enc := NewEncoder(nil)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		enc.Reset(nil, false)
+		enc.Encode(line)
+	}
+}
+
+//the types are only defined in the first stream of the session
+func BenchmarkTBinMarshalResetKeepTypes(b *testing.B) {
+	line := polyline()
+	enc := NewEncoder(nil)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		enc.Reset(nil, true)
+		enc.Encode(line)
+	}
+}
+
+func BenchmarkTBinUnmarshalNewDecoder(b *testing.B) {
+	tdata, _ := Marshal(polyline())
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var line2 Polyline
+		NewDecoder(bytes.NewReader(tdata)).Decode(&line2)
+	}
+}
+
+func BenchmarkTBinUnmarshalPooled(b *testing.B) {
+	tdata, _ := Marshal(polyline())
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var line2 Polyline
+		Unmarshal(tdata, &line2)
+	}
+}
+
+func BenchmarkTBinUnmarshalReset(b *testing.B) {
+	tdata, _ := Marshal(polyline())
+	src := bytes.NewReader(tdata)
+	dec := NewDecoder(src)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var line2 Polyline
+		src.Reset(tdata)
+		dec.Reset(src, false)
+		dec.Decode(&line2)
+	}
+}
+
+//with the reflection plan cache, this is just a lookup
+func BenchmarkTBinTypeSignatureCached(b *testing.B) {
+	t := reflect.TypeOf(*loadBigTest(b))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		buildTypeSignature(t)
+	}
+}
@@ -69,44 +69,3 @@ func TestTBinTypes(test *testing.T) {
 		test.Errorf("Decode doesn't match original")
 	}
 }
-
-func (bt BigTest) MarshalTBin(enc *Encoder) error {
-	signature := TypeSignature(bt)
-	enc.WriteType(signature)
-	enc.WriteSize(len(bt.Stuff))
-	for _, bs := range bt.Stuff {
-		enc.WriteString(bs.MyName)
-		enc.WriteString(bs.MyUtfname)
-		enc.WriteBool(bs.MyBool)
-		enc.WriteInt8(bs.MyByte)
-		enc.WriteInt16(bs.MyShort)
-		enc.WriteInt32(bs.MyInt)
-		enc.WriteInt64(bs.MyLong)
-		enc.WriteFloat32(bs.MyFloat)
-		enc.WriteFloat64(bs.MyDouble)
-
-		enc.WriteUnsigned(len(bs.MyIntArray))
-		for _, v := range bs.MyIntArray {
-			enc.WriteInt32(v)
-		}
-
-		enc.WriteUnsigned(len(bs.MyStringArray))
-		for _, v := range bs.MyStringArray {
-			enc.WriteString(v)
-		}
-
-		enc.WriteUnsigned(len(bs.MyMap))
-		for k, v := range bs.MyMap {
-			enc.WriteString(k)
-			enc.WriteInt32(v)
-		}
-
-		enc.WriteUUID(bs.MyUuid)
-
-		enc.WriteString(string(bs.MyStringSubtype))
-		enc.WriteInt32(int32(bs.MyInt32Subtype))
-		enc.WriteFloat64(float64(bs.MyFloat64Subtype))
-		enc.WriteTimestamp(bs.MyTime)
-	}
-	return nil
-}
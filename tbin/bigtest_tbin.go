@@ -0,0 +1,459 @@
+//
+// This file generated by tbin.GenerateMarshalCode from the "tests" schema. Do not edit.
+//
+
+package tbin
+
+import (
+	"fmt"
+)
+
+var tbinSignatureStringTest = Struct(Field("name", String, false), Field("parent", String, false), Field("names", Array(String), true), Field("enc", String, true))
+
+// MarshalTBin - encodes the StringTest as TBin, implementing TBinMarshallable
+func (o StringTest) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignatureStringTest)
+	return o.writeTBin(enc)
+}
+
+func (o *StringTest) writeTBin(enc *Encoder) error {
+	enc.WriteString(string(o.Name))
+	enc.WriteString(string(o.Parent))
+	if o.Names != nil {
+		enc.Encode(o.Names)
+	} else {
+		enc.EncodeNull()
+	}
+	if o.Enc != "" {
+		enc.Encode(o.Enc)
+	} else {
+		enc.EncodeNull()
+	}
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the StringTest, implementing TBinUnmarshallable
+func (o *StringTest) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignatureStringTest.String() {
+		return fmt.Errorf("Cannot unmarshal %v into StringTest", sig)
+	}
+	var v StringTest
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *StringTest) readTBin(dec *Decoder) error {
+	v1, _ := dec.ParseString()
+	o.Name = SimpleName(v1)
+	v2, _ := dec.ParseString()
+	o.Parent = CompoundName(v2)
+	if err := dec.DecodeAny(&o.Names); err != nil {
+		return err
+	}
+	if err := dec.DecodeAny(&o.Enc); err != nil {
+		return err
+	}
+	return dec.Error()
+}
+
+var tbinSignatureMapTest = Struct(Field("locations", Map(String, Int32), false))
+
+// MarshalTBin - encodes the MapTest as TBin, implementing TBinMarshallable
+func (o MapTest) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignatureMapTest)
+	return o.writeTBin(enc)
+}
+
+func (o *MapTest) writeTBin(enc *Encoder) error {
+	enc.WriteSize(len(o.Locations))
+	for k1, v1 := range o.Locations {
+		enc.WriteString(k1)
+		enc.WriteInt32(v1)
+	}
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the MapTest, implementing TBinUnmarshallable
+func (o *MapTest) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignatureMapTest.String() {
+		return fmt.Errorf("Cannot unmarshal %v into MapTest", sig)
+	}
+	var v MapTest
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *MapTest) readTBin(dec *Decoder) error {
+	n1 := dec.ReadSize()
+	m1 := make(map[string]int32)
+	for i1 := 0; i1 < n1 && dec.Error() == nil; i1++ {
+		v2, _ := dec.ParseString()
+		m1[v2] = int32(dec.ParseInt())
+	}
+	o.Locations = m1
+	return dec.Error()
+}
+
+var tbinSignatureMapArrayTest = Struct(Field("locations", Map(String, Array(Int32)), false))
+
+// MarshalTBin - encodes the MapArrayTest as TBin, implementing TBinMarshallable
+func (o MapArrayTest) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignatureMapArrayTest)
+	return o.writeTBin(enc)
+}
+
+func (o *MapArrayTest) writeTBin(enc *Encoder) error {
+	enc.WriteSize(len(o.Locations))
+	for k1, v1 := range o.Locations {
+		enc.WriteString(k1)
+		enc.WriteSize(len(v1))
+		for _, v2 := range v1 {
+			enc.WriteInt32(v2)
+		}
+	}
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the MapArrayTest, implementing TBinUnmarshallable
+func (o *MapArrayTest) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignatureMapArrayTest.String() {
+		return fmt.Errorf("Cannot unmarshal %v into MapArrayTest", sig)
+	}
+	var v MapArrayTest
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *MapArrayTest) readTBin(dec *Decoder) error {
+	n1 := dec.ReadSize()
+	m1 := make(map[string]ArrayOfInt)
+	for i1 := 0; i1 < n1 && dec.Error() == nil; i1++ {
+		v2, _ := dec.ParseString()
+		n3 := dec.ReadSize()
+		a3 := make(ArrayOfInt, 0)
+		for i3 := 0; i3 < n3 && dec.Error() == nil; i3++ {
+			a3 = append(a3, int32(dec.ParseInt()))
+		}
+		m1[v2] = a3
+	}
+	o.Locations = m1
+	return dec.Error()
+}
+
+var tbinSignatureIntOOBTest = Struct(Field("theyear", Int32, false))
+
+// MarshalTBin - encodes the IntOOBTest as TBin, implementing TBinMarshallable
+func (o IntOOBTest) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignatureIntOOBTest)
+	return o.writeTBin(enc)
+}
+
+func (o *IntOOBTest) writeTBin(enc *Encoder) error {
+	enc.WriteInt32(int32(o.Theyear))
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the IntOOBTest, implementing TBinUnmarshallable
+func (o *IntOOBTest) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignatureIntOOBTest.String() {
+		return fmt.Errorf("Cannot unmarshal %v into IntOOBTest", sig)
+	}
+	var v IntOOBTest
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *IntOOBTest) readTBin(dec *Decoder) error {
+	o.Theyear = Year(dec.ParseInt())
+	return dec.Error()
+}
+
+var tbinSignatureNegativeNumberTest = Struct(Field("mylatitude", Float64, false))
+
+// MarshalTBin - encodes the NegativeNumberTest as TBin, implementing TBinMarshallable
+func (o NegativeNumberTest) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignatureNegativeNumberTest)
+	return o.writeTBin(enc)
+}
+
+func (o *NegativeNumberTest) writeTBin(enc *Encoder) error {
+	enc.WriteFloat64(float64(o.Mylatitude))
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the NegativeNumberTest, implementing TBinUnmarshallable
+func (o *NegativeNumberTest) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignatureNegativeNumberTest.String() {
+		return fmt.Errorf("Cannot unmarshal %v into NegativeNumberTest", sig)
+	}
+	var v NegativeNumberTest
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *NegativeNumberTest) readTBin(dec *Decoder) error {
+	v1, _ := dec.ParseFloat64()
+	o.Mylatitude = Latitude(v1)
+	return dec.Error()
+}
+
+var tbinSignatureUUIDTest = Struct(Field("myid", UUID, false))
+
+// MarshalTBin - encodes the UUIDTest as TBin, implementing TBinMarshallable
+func (o UUIDTest) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignatureUUIDTest)
+	return o.writeTBin(enc)
+}
+
+func (o *UUIDTest) writeTBin(enc *Encoder) error {
+	enc.WriteUUID(o.Myid)
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the UUIDTest, implementing TBinUnmarshallable
+func (o *UUIDTest) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignatureUUIDTest.String() {
+		return fmt.Errorf("Cannot unmarshal %v into UUIDTest", sig)
+	}
+	var v UUIDTest
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *UUIDTest) readTBin(dec *Decoder) error {
+	v1, _ := dec.ParseUUID()
+	o.Myid = v1
+	return dec.Error()
+}
+
+var tbinSignatureTimestampTest = Struct(Field("mytime", Timestamp, false))
+
+// MarshalTBin - encodes the TimestampTest as TBin, implementing TBinMarshallable
+func (o TimestampTest) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignatureTimestampTest)
+	return o.writeTBin(enc)
+}
+
+func (o *TimestampTest) writeTBin(enc *Encoder) error {
+	enc.WriteTimestamp(o.Mytime)
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the TimestampTest, implementing TBinUnmarshallable
+func (o *TimestampTest) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignatureTimestampTest.String() {
+		return fmt.Errorf("Cannot unmarshal %v into TimestampTest", sig)
+	}
+	var v TimestampTest
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *TimestampTest) readTBin(dec *Decoder) error {
+	v1, _ := dec.ParseTimestamp()
+	o.Mytime = v1
+	return dec.Error()
+}
+
+var tbinSignatureBigStruct = Struct(Field("myName", String, false), Field("myUtfname", String, false), Field("myBool", Bool, false), Field("myByte", Int8, false), Field("myShort", Int16, false), Field("myInt", Int32, false), Field("myLong", Int64, false), Field("myFloat", Float32, false), Field("myDouble", Float64, false), Field("myIntArray", Array(Int32), false), Field("myStringArray", Array(String), false), Field("myMap", Map(String, Int32), false), Field("myUuid", UUID, false), Field("myStringSubtype", String, false), Field("myInt32Subtype", Int32, false), Field("myFloat64Subtype", Float64, false), Field("myTime", Timestamp, false))
+
+// MarshalTBin - encodes the BigStruct as TBin, implementing TBinMarshallable
+func (o BigStruct) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignatureBigStruct)
+	return o.writeTBin(enc)
+}
+
+func (o *BigStruct) writeTBin(enc *Encoder) error {
+	enc.WriteString(o.MyName)
+	enc.WriteString(o.MyUtfname)
+	enc.WriteBool(o.MyBool)
+	enc.WriteInt8(o.MyByte)
+	enc.WriteInt16(o.MyShort)
+	enc.WriteInt32(o.MyInt)
+	enc.WriteInt64(o.MyLong)
+	enc.WriteFloat32(o.MyFloat)
+	enc.WriteFloat64(o.MyDouble)
+	enc.WriteSize(len(o.MyIntArray))
+	for _, v1 := range o.MyIntArray {
+		enc.WriteInt32(v1)
+	}
+	enc.WriteSize(len(o.MyStringArray))
+	for _, v2 := range o.MyStringArray {
+		enc.WriteString(v2)
+	}
+	enc.WriteSize(len(o.MyMap))
+	for k3, v3 := range o.MyMap {
+		enc.WriteString(k3)
+		enc.WriteInt32(v3)
+	}
+	enc.WriteUUID(o.MyUuid)
+	enc.WriteString(string(o.MyStringSubtype))
+	enc.WriteInt32(int32(o.MyInt32Subtype))
+	enc.WriteFloat64(float64(o.MyFloat64Subtype))
+	enc.WriteTimestamp(o.MyTime)
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the BigStruct, implementing TBinUnmarshallable
+func (o *BigStruct) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignatureBigStruct.String() {
+		return fmt.Errorf("Cannot unmarshal %v into BigStruct", sig)
+	}
+	var v BigStruct
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *BigStruct) readTBin(dec *Decoder) error {
+	v1, _ := dec.ParseString()
+	o.MyName = v1
+	v2, _ := dec.ParseString()
+	o.MyUtfname = v2
+	o.MyBool = dec.ParseBool()
+	o.MyByte = int8(dec.ParseInt())
+	o.MyShort = int16(dec.ParseInt())
+	o.MyInt = int32(dec.ParseInt())
+	o.MyLong = dec.ParseInt64()
+	v3, _ := dec.ParseFloat32()
+	o.MyFloat = v3
+	v4, _ := dec.ParseFloat64()
+	o.MyDouble = v4
+	n5 := dec.ReadSize()
+	a5 := make([]int32, 0)
+	for i5 := 0; i5 < n5 && dec.Error() == nil; i5++ {
+		a5 = append(a5, int32(dec.ParseInt()))
+	}
+	o.MyIntArray = a5
+	n6 := dec.ReadSize()
+	a6 := make([]string, 0)
+	for i6 := 0; i6 < n6 && dec.Error() == nil; i6++ {
+		v7, _ := dec.ParseString()
+		a6 = append(a6, v7)
+	}
+	o.MyStringArray = a6
+	n8 := dec.ReadSize()
+	m8 := make(map[string]int32)
+	for i8 := 0; i8 < n8 && dec.Error() == nil; i8++ {
+		v9, _ := dec.ParseString()
+		m8[v9] = int32(dec.ParseInt())
+	}
+	o.MyMap = m8
+	v10, _ := dec.ParseUUID()
+	o.MyUuid = v10
+	v11, _ := dec.ParseString()
+	o.MyStringSubtype = azAZ(v11)
+	o.MyInt32Subtype = Year(dec.ParseInt())
+	v12, _ := dec.ParseFloat64()
+	o.MyFloat64Subtype = Pi(v12)
+	v13, _ := dec.ParseTimestamp()
+	o.MyTime = v13
+	return dec.Error()
+}
+
+var tbinSignatureBigTest = Struct(Field("stuff", Array(tbinSignatureBigStruct), false))
+
+// MarshalTBin - encodes the BigTest as TBin, implementing TBinMarshallable
+func (o BigTest) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignatureBigTest)
+	return o.writeTBin(enc)
+}
+
+func (o *BigTest) writeTBin(enc *Encoder) error {
+	enc.WriteSize(len(o.Stuff))
+	for _, v1 := range o.Stuff {
+		if v1 == nil {
+			return fmt.Errorf("Cannot marshal null pointer for required field BigTest.stuff item")
+		}
+		if err := v1.writeTBin(enc); err != nil {
+			return err
+		}
+	}
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the BigTest, implementing TBinUnmarshallable
+func (o *BigTest) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignatureBigTest.String() {
+		return fmt.Errorf("Cannot unmarshal %v into BigTest", sig)
+	}
+	var v BigTest
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *BigTest) readTBin(dec *Decoder) error {
+	n1 := dec.ReadSize()
+	a1 := make([]*BigStruct, 0)
+	for i1 := 0; i1 < n1 && dec.Error() == nil; i1++ {
+		v2 := new(BigStruct)
+		if err := v2.readTBin(dec); err != nil {
+			return err
+		}
+		a1 = append(a1, v2)
+	}
+	o.Stuff = a1
+	return dec.Error()
+}
@@ -0,0 +1,214 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+const canonicalTrials = 200
+
+func randomString(r *rand.Rand) string {
+	n := r.Intn(40) //both tiny and regular strings
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('a' + r.Intn(26))
+	}
+	return string(b)
+}
+
+// randomGeneric returns a random generic value, with maps big enough that their iteration order varies.
+func randomGeneric(r *rand.Rand, depth int) interface{} {
+	n := 7
+	if depth > 3 {
+		n = 5 //scalars only
+	}
+	switch r.Intn(n) {
+	case 0:
+		return int32(r.Int31() - r.Int31())
+	case 1:
+		return r.Int63() - r.Int63()
+	case 2:
+		return r.NormFloat64()
+	case 3:
+		return randomString(r)
+	case 4:
+		return rdl.Symbol(randomString(r))
+	case 5:
+		m := make(map[string]interface{})
+		for i := r.Intn(20); i > 0; i-- {
+			m[randomString(r)] = randomGeneric(r, depth+1)
+		}
+		return m
+	default:
+		if r.Intn(2) == 0 {
+			a := make([]interface{}, r.Intn(10))
+			for i := range a {
+				a[i] = randomGeneric(r, depth+1)
+			}
+			return a
+		}
+		st := make(rdl.Struct)
+		for i := r.Intn(20); i > 0; i-- {
+			st[rdl.Symbol(randomString(r))] = randomGeneric(r, depth+1)
+		}
+		return st
+	}
+}
+
+// copyGeneric returns an equal value, with every map rebuilt in a different insertion order.
+func copyGeneric(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(tv))
+		for _, k := range reverseSortedKeys(tv) {
+			m[k] = copyGeneric(tv[k])
+		}
+		return m
+	case rdl.Struct:
+		st := make(rdl.Struct, len(tv))
+		for k, item := range tv {
+			st[k] = copyGeneric(item)
+		}
+		return st
+	case []interface{}:
+		a := make([]interface{}, len(tv))
+		for i, item := range tv {
+			a[i] = copyGeneric(item)
+		}
+		return a
+	}
+	return v
+}
+
+func reverseSortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys))) //the opposite of the canonical order
+	return keys
+}
+
+func randomBigStruct(r *rand.Rand) *BigStruct {
+	bs := &BigStruct{
+		MyName:           randomString(r),
+		MyUtfname:        randomString(r) + "姚冀清",
+		MyBool:           r.Intn(2) == 0,
+		MyByte:           int8(r.Intn(256) - 128),
+		MyShort:          int16(r.Intn(65536) - 32768),
+		MyInt:            r.Int31() - r.Int31(),
+		MyLong:           r.Int63() - r.Int63(),
+		MyFloat:          float32(r.NormFloat64()),
+		MyDouble:         r.NormFloat64(),
+		MyMap:            make(map[string]int32),
+		MyUuid:           rdl.ParseUUID(fmt.Sprintf("%08x-a4ad-11de-0000-090000000179", r.Uint32())),
+		MyStringSubtype:  "abc",
+		MyInt32Subtype:   Year(r.Intn(3000)),
+		MyFloat64Subtype: Pi(r.Float64()),
+		MyTime:           rdl.TimestampFromEpoch(float64(r.Int63n(2000000000000)) / 1000),
+	}
+	for i := r.Intn(10); i > 0; i-- {
+		bs.MyIntArray = append(bs.MyIntArray, r.Int31())
+		bs.MyStringArray = append(bs.MyStringArray, randomString(r))
+	}
+	for i := r.Intn(30); i > 0; i-- {
+		bs.MyMap[randomString(r)] = r.Int31()
+	}
+	return bs
+}
+
+func canonical(test *testing.T, v interface{}) []byte {
+	b, err := MarshalCanonical(v)
+	if err != nil {
+		test.Fatalf("Cannot marshal canonically: %v", err)
+	}
+	return b
+}
+
+func TestCanonicalGenericDeterminism(test *testing.T) {
+	for seed := int64(0); seed < canonicalTrials; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		v := randomGeneric(r, 0)
+		expected := canonical(test, v)
+		for i := 0; i < 5; i++ {
+			if !bytes.Equal(canonical(test, v), expected) {
+				test.Fatalf("Seed %d: repeated canonical encodings differ", seed)
+			}
+		}
+		if !bytes.Equal(canonical(test, copyGeneric(v)), expected) {
+			test.Fatalf("Seed %d: canonical encodings of equal values differ", seed)
+		}
+		var decoded interface{}
+		if err := Unmarshal(expected, &decoded); err != nil {
+			test.Fatalf("Seed %d: cannot decode the canonical encoding: %v", seed, err)
+		}
+	}
+}
+
+func TestCanonicalTypedDeterminism(test *testing.T) {
+	for seed := int64(0); seed < canonicalTrials; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		bt := &BigTest{Stuff: []*BigStruct{randomBigStruct(r), randomBigStruct(r)}}
+		expected := canonical(test, bt)
+		if !bytes.Equal(canonical(test, bt), expected) {
+			test.Fatalf("Seed %d: repeated canonical encodings differ", seed)
+		}
+		//decoding to an equal value, and encoding that, is the same
+		var bt2 BigTest
+		if err := Unmarshal(expected, &bt2); err != nil {
+			test.Fatalf("Seed %d: cannot decode the canonical encoding: %v", seed, err)
+		}
+		if !bytes.Equal(canonical(test, &bt2), expected) {
+			test.Fatalf("Seed %d: canonical encoding of the decoded value differs", seed)
+		}
+		h1, _ := Hash(bt)
+		h2, _ := Hash(bt2)
+		if !bytes.Equal(h1, h2) {
+			test.Fatalf("Seed %d: hashes of equal values differ", seed)
+		}
+		bt2.Stuff[1].MyMap["another"] = 1
+		if h2, _ = Hash(bt2); bytes.Equal(h1, h2) {
+			test.Fatalf("Seed %d: hashes of different values are the same", seed)
+		}
+	}
+}
+
+func TestCanonicalEncoding(test *testing.T) {
+	//without maps, the canonical encoding is the usual one
+	line := polyline()
+	b, _ := Marshal(line)
+	if !bytes.Equal(canonical(test, line), b) {
+		test.Errorf("Canonical encoding of a Polyline differs from the default")
+	}
+	if !bytes.Equal(canonical(test, math.Copysign(0, -1)), canonical(test, 0.0)) {
+		test.Errorf("Canonical encodings of negative and positive zero differ")
+	}
+	if !bytes.Equal(canonical(test, float32(math.Copysign(0, -1))), canonical(test, float32(0))) {
+		test.Errorf("Canonical encodings of float32 negative and positive zero differ")
+	}
+	nan := math.Float64frombits(0x7ff8000000000001)
+	if !bytes.Equal(canonical(test, nan), canonical(test, math.NaN())) {
+		test.Errorf("Canonical encodings of NaN differ")
+	}
+	sum, err := Hash(line)
+	if err != nil || len(sum) != 32 {
+		test.Errorf("Expected a SHA-256 hash, got %x (%v)", sum, err)
+	}
+
+	//varints are always the shortest, even when large
+	enc := NewEncoder(nil)
+	enc.WriteUnsigned(1 << 40)
+	dec := NewDecoder(bytes.NewReader(enc.Bytes()))
+	if n := dec.ParseUnsigned64(); n != 1<<40 || len(enc.Bytes()) != 1+6 {
+		test.Errorf("Bad encoding of a large unsigned value: %v, % x", n, enc.Bytes())
+	}
+}
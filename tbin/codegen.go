@@ -0,0 +1,648 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+// GenerateMarshalCode writes Go source implementing TBinMarshallable and TBinUnmarshallable for
+// every struct and union type in the schema. The code is for package pkg, which must also hold the
+// Go model types generated for the schema. Signatures become package level variables, and values
+// are written and parsed field by field, so no reflection is needed for these types.
+func GenerateMarshalCode(out io.Writer, schema *rdl.Schema, pkg string) error {
+	g := &codeGenerator{
+		schema: schema,
+		reg:    rdl.NewTypeRegistry(schema),
+		pkg:    pkg,
+	}
+	for _, t := range schema.Types {
+		switch t.Variant {
+		case rdl.TypeVariantStructTypeDef:
+			g.generateStruct(t.StructTypeDef)
+		case rdl.TypeVariantUnionTypeDef:
+			g.generateUnion(t.UnionTypeDef)
+		}
+		if g.err != nil {
+			return g.err
+		}
+	}
+	var src bytes.Buffer
+	src.WriteString("//\n")
+	fmt.Fprintf(&src, "// This file generated by tbin.GenerateMarshalCode from the %q schema. Do not edit.\n", schema.Name)
+	src.WriteString("//\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	body := g.buf.String()
+	var imports []string
+	for _, imp := range []string{"fmt", "github.com/ardielle/ardielle-go/rdl", "github.com/ardielle/ardielle-go/tbin"} {
+		name := imp[strings.LastIndex(imp, "/")+1:]
+		if regexp.MustCompile(`(^|[^\w.])` + name + `\.`).MatchString(body) {
+			imports = append(imports, imp)
+		}
+	}
+	if len(imports) > 0 {
+		src.WriteString("import (\n")
+		for i, imp := range imports {
+			if i > 0 && strings.Contains(imp, "/") && !strings.Contains(imports[i-1], "/") {
+				src.WriteString("\n")
+			}
+			fmt.Fprintf(&src, "\t%q\n", imp)
+		}
+		src.WriteString(")\n")
+	}
+	src.WriteString(body)
+	code, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("Cannot format generated code: %v", err)
+	}
+	_, err = out.Write(code)
+	return err
+}
+
+type codeGenerator struct {
+	schema *rdl.Schema
+	reg    rdl.TypeRegistry
+	pkg    string
+	buf    bytes.Buffer
+	ntmp   int
+	err    error
+}
+
+// genType describes how a value of some RDL type is represented in the Go model
+type genType struct {
+	base   rdl.BaseType
+	goType string //the Go type of a value, i.e. "int32", "azAZ", "[]*Point"
+	name   string //the user type name for structs, unions, and enums
+	named  bool   //the Go type is a named type, so values are converted to and from the base Go type
+	ref    bool   //the Go value is a pointer to the type
+	def    *rdl.Type
+	keys   *genType
+	items  *genType
+}
+
+var codegenPrimitives = map[rdl.BaseType]struct {
+	goType string
+	write  string
+	parse  string
+}{
+	rdl.BaseTypeBool:      {"bool", "WriteBool", "ParseBool"},
+	rdl.BaseTypeInt8:      {"int8", "WriteInt8", "ParseInt"},
+	rdl.BaseTypeInt16:     {"int16", "WriteInt16", "ParseInt"},
+	rdl.BaseTypeInt32:     {"int32", "WriteInt32", "ParseInt"},
+	rdl.BaseTypeInt64:     {"int64", "WriteInt64", "ParseInt64"},
+	rdl.BaseTypeFloat32:   {"float32", "WriteFloat32", "ParseFloat32"},
+	rdl.BaseTypeFloat64:   {"float64", "WriteFloat64", "ParseFloat64"},
+	rdl.BaseTypeBytes:     {"[]byte", "WriteBytes", "ParseBytes"},
+	rdl.BaseTypeString:    {"string", "WriteString", "ParseString"},
+	rdl.BaseTypeTimestamp: {"Timestamp", "WriteTimestamp", "ParseTimestamp"},
+	rdl.BaseTypeSymbol:    {"Symbol", "WriteSymbol", "ParseSymbol"},
+	rdl.BaseTypeUUID:      {"UUID", "WriteUUID", "ParseUUID"},
+}
+
+func (g *codeGenerator) line(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+func (g *codeGenerator) fail(format string, args ...interface{}) {
+	if g.err == nil {
+		g.err = fmt.Errorf(format, args...)
+	}
+}
+
+// tmps returns names for the local variables of one generated construct, unique within the function
+func (g *codeGenerator) tmps(prefixes ...string) []string {
+	g.ntmp++
+	names := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		names[i] = fmt.Sprintf("%s%d", prefix, g.ntmp)
+	}
+	return names
+}
+
+// qualified returns the identifier as referenced from the generated package
+func (g *codeGenerator) qualified(pkg string, ident string) string {
+	if g.pkg == pkg {
+		return ident
+	}
+	return pkg + "." + ident
+}
+
+func (g *codeGenerator) tbin(ident string) string {
+	return g.qualified("tbin", ident)
+}
+
+func (g *codeGenerator) errorf(format string, args ...interface{}) string {
+	return "fmt.Errorf(" + fmt.Sprintf("%q", fmt.Sprintf(format, args...)) + ")"
+}
+
+var identifierPattern = regexp.MustCompile(`^\w+$`)
+
+func goFieldName(name rdl.Identifier) string {
+	s := string(name)
+	return strings.ToUpper(s[0:1]) + s[1:]
+}
+
+func signatureVar(name rdl.TypeName) string {
+	return "tbinSignature" + goFieldName(rdl.Identifier(name))
+}
+
+// resolve determines the Go representation of a type reference. The keys and items apply when the
+// reference is to the Array or Map base type, as with inline field declarations.
+func (g *codeGenerator) resolve(tref rdl.TypeRef, keys rdl.TypeRef, items rdl.TypeRef) *genType {
+	t := g.reg.FindType(tref)
+	if t == nil {
+		g.fail("Cannot generate TBin code for unknown type '%s'", tref)
+		return &genType{base: rdl.BaseTypeAny, goType: "interface{}"}
+	}
+	gt := &genType{base: g.reg.BaseType(t)}
+	if !g.reg.IsBaseTypeName(tref) {
+		gt.name = string(tref)
+		gt.goType = gt.name
+		gt.named = true
+		gt.def = t
+		keys, items = g.collectionTypes(t)
+	}
+	switch gt.base {
+	case rdl.BaseTypeArray:
+		gt.items = g.resolveItem(items, rdl.TypeRef("Any"))
+		if !gt.named {
+			gt.goType = "[]" + gt.items.goType
+		}
+	case rdl.BaseTypeMap:
+		gt.keys = g.resolveItem(keys, rdl.TypeRef("String"))
+		gt.items = g.resolveItem(items, rdl.TypeRef("Any"))
+		if !gt.named {
+			gt.goType = "map[" + gt.keys.goType + "]" + gt.items.goType
+		}
+	case rdl.BaseTypeStruct, rdl.BaseTypeUnion:
+		if gt.named {
+			if t.Variant != rdl.TypeVariantStructTypeDef && t.Variant != rdl.TypeVariantUnionTypeDef {
+				g.fail("Cannot generate TBin code for '%s', an alias of a struct or union type", tref)
+			}
+			gt.ref = true
+			gt.goType = "*" + gt.name
+		} else if gt.base == rdl.BaseTypeStruct {
+			gt.goType = g.qualified("rdl", "Struct")
+		} else {
+			g.fail("Cannot generate TBin code for an undefined union")
+		}
+	case rdl.BaseTypeEnum:
+		if !gt.named {
+			g.fail("Cannot generate TBin code for an undefined enum")
+		}
+	case rdl.BaseTypeAny:
+		gt.goType = "interface{}"
+	default:
+		if !gt.named {
+			prim := codegenPrimitives[gt.base]
+			gt.goType = prim.goType
+			if gt.base == rdl.BaseTypeTimestamp || gt.base == rdl.BaseTypeSymbol || gt.base == rdl.BaseTypeUUID {
+				gt.goType = g.qualified("rdl", prim.goType)
+			}
+		}
+	}
+	return gt
+}
+
+func (g *codeGenerator) resolveItem(tref rdl.TypeRef, dflt rdl.TypeRef) *genType {
+	if tref == "" {
+		tref = dflt
+	}
+	return g.resolve(tref, "", "")
+}
+
+// collectionTypes finds the key and item types of a user defined array or map type
+func (g *codeGenerator) collectionTypes(t *rdl.Type) (rdl.TypeRef, rdl.TypeRef) {
+	for i := 0; t != nil && i < len(g.schema.Types); i++ {
+		switch t.Variant {
+		case rdl.TypeVariantArrayTypeDef:
+			if t.ArrayTypeDef.Items != "" {
+				return "", t.ArrayTypeDef.Items
+			}
+		case rdl.TypeVariantMapTypeDef:
+			if t.MapTypeDef.Items != "" {
+				return t.MapTypeDef.Keys, t.MapTypeDef.Items
+			}
+		}
+		_, super, _ := rdl.TypeInfo(t)
+		t = g.reg.FindType(super)
+	}
+	return "", ""
+}
+
+func (g *codeGenerator) resolveField(f *rdl.StructFieldDef) *genType {
+	gt := g.resolve(f.Type, f.Keys, f.Items)
+	if isOptionalField(f) {
+		switch gt.base {
+		case rdl.BaseTypeBool, rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32, rdl.BaseTypeInt64,
+			rdl.BaseTypeFloat32, rdl.BaseTypeFloat64, rdl.BaseTypeTimestamp:
+			gt.ref = true
+		}
+	}
+	return gt
+}
+
+// isOptionalField is true when the Go model can omit the field, i.e. it is optional with no default
+func isOptionalField(f *rdl.StructFieldDef) bool {
+	return f.Optional && f.Default == nil
+}
+
+// structFields returns the fields of a struct type, including those of the types it derives from
+func (g *codeGenerator) structFields(td *rdl.StructTypeDef, depth int) []*rdl.StructFieldDef {
+	var fields []*rdl.StructFieldDef
+	if td.Type != "Struct" {
+		super := g.reg.FindType(td.Type)
+		if super == nil || super.Variant != rdl.TypeVariantStructTypeDef || depth > len(g.schema.Types) {
+			g.fail("Cannot generate TBin code for '%s', its supertype is not a struct", td.Name)
+			return nil
+		}
+		fields = g.structFields(super.StructTypeDef, depth+1)
+	}
+	return append(fields, td.Fields...)
+}
+
+// signature returns a Go expression for the signature of the type
+func (g *codeGenerator) signature(gt *genType) string {
+	switch gt.base {
+	case rdl.BaseTypeStruct:
+		if gt.def == nil {
+			return "&" + g.tbin("Signature") + "{Tag: " + g.tbin("StructTag") + "}"
+		}
+		return signatureVar(rdl.TypeName(gt.name))
+	case rdl.BaseTypeUnion:
+		return signatureVar(rdl.TypeName(gt.name))
+	case rdl.BaseTypeArray:
+		return g.tbin("Array") + "(" + g.signature(gt.items) + ")"
+	case rdl.BaseTypeMap:
+		return g.tbin("Map") + "(" + g.signature(gt.keys) + ", " + g.signature(gt.items) + ")"
+	case rdl.BaseTypeEnum:
+		var syms []string
+		for _, elem := range g.enumDef(gt).Elements {
+			syms = append(syms, fmt.Sprintf("%q", elem.Symbol))
+		}
+		return g.tbin("Enum") + "(" + strings.Join(syms, ", ") + ")"
+	}
+	return g.tbin(gt.base.String())
+}
+
+func (g *codeGenerator) enumDef(gt *genType) *rdl.EnumTypeDef {
+	t := gt.def
+	for i := 0; t != nil && i < len(g.schema.Types); i++ {
+		if t.Variant == rdl.TypeVariantEnumTypeDef {
+			return t.EnumTypeDef
+		}
+		_, super, _ := rdl.TypeInfo(t)
+		t = g.reg.FindType(super)
+	}
+	g.fail("Cannot find the symbols of enum type '%s'", gt.name)
+	return &rdl.EnumTypeDef{}
+}
+
+// checkRecursion fails if the signature of the type refers back to itself, as the
+// signature variables could then not be initialized
+func (g *codeGenerator) checkRecursion(name string, gt *genType, visiting map[string]bool) {
+	if gt == nil || g.err != nil {
+		return
+	}
+	if gt.def != nil && (gt.base == rdl.BaseTypeStruct || gt.base == rdl.BaseTypeUnion) {
+		if gt.name == name {
+			g.fail("Cannot generate TBin code for recursive type '%s'", name)
+			return
+		}
+		if visiting[gt.name] {
+			return
+		}
+		visiting[gt.name] = true
+		for _, sub := range g.componentTypes(gt.def) {
+			g.checkRecursion(name, sub, visiting)
+		}
+		return
+	}
+	g.checkRecursion(name, gt.keys, visiting)
+	g.checkRecursion(name, gt.items, visiting)
+}
+
+func (g *codeGenerator) componentTypes(t *rdl.Type) []*genType {
+	var types []*genType
+	switch t.Variant {
+	case rdl.TypeVariantStructTypeDef:
+		for _, f := range g.structFields(t.StructTypeDef, 0) {
+			types = append(types, g.resolveField(f))
+		}
+	case rdl.TypeVariantUnionTypeDef:
+		for _, v := range t.UnionTypeDef.Variants {
+			types = append(types, g.resolve(v, "", ""))
+		}
+	}
+	return types
+}
+
+func (g *codeGenerator) generateStruct(td *rdl.StructTypeDef) {
+	name := string(td.Name)
+	fields := g.structFields(td, 0)
+	types := make([]*genType, len(fields))
+	var sigs []string
+	for i, f := range fields {
+		types[i] = g.resolveField(f)
+		sigs = append(sigs, fmt.Sprintf("%s(%q, %s, %v)", g.tbin("Field"), f.Name, g.signature(types[i]), isOptionalField(f)))
+	}
+	for _, gt := range types {
+		g.checkRecursion(name, gt, make(map[string]bool))
+	}
+	if g.err != nil {
+		return
+	}
+	g.line("")
+	g.line("var %s = %s(%s)", signatureVar(td.Name), g.tbin("Struct"), strings.Join(sigs, ", "))
+	g.generateMarshal(name, td.Name)
+
+	g.line("")
+	g.line("func (o *%s) writeTBin(enc *%s) error {", name, g.tbin("Encoder"))
+	g.ntmp = 0
+	for i, f := range fields {
+		expr := "o." + goFieldName(f.Name)
+		if isOptionalField(f) {
+			g.line("if %s {", g.present(types[i], expr))
+			g.line("enc.Encode(%s)", expr)
+			g.line("} else {")
+			g.line("enc.EncodeNull()")
+			g.line("}")
+		} else {
+			g.writeValue(types[i], expr, name+"."+string(f.Name))
+		}
+	}
+	g.line("return enc.Error()")
+	g.line("}")
+	g.generateUnmarshal(name, td.Name)
+
+	g.line("")
+	g.line("func (o *%s) readTBin(dec *%s) error {", name, g.tbin("Decoder"))
+	g.ntmp = 0
+	for i, f := range fields {
+		expr := "o." + goFieldName(f.Name)
+		if isOptionalField(f) {
+			g.line("if err := dec.DecodeAny(&%s); err != nil {", expr)
+			g.line("return err")
+			g.line("}")
+		} else {
+			g.line("%s = %s", expr, g.readValue(types[i]))
+		}
+	}
+	g.line("return dec.Error()")
+	g.line("}")
+}
+
+func (g *codeGenerator) generateUnion(td *rdl.UnionTypeDef) {
+	name := string(td.Name)
+	types := make([]*genType, len(td.Variants))
+	var sigs []string
+	for i, v := range td.Variants {
+		types[i] = g.resolve(v, "", "")
+		types[i].ref = true //the variants are always held by pointer
+		sigs = append(sigs, g.signature(types[i]))
+	}
+	for _, gt := range types {
+		g.checkRecursion(name, gt, make(map[string]bool))
+	}
+	if g.err != nil {
+		return
+	}
+	g.line("")
+	g.line("var %s = %s(%s)", signatureVar(td.Name), g.tbin("Union"), strings.Join(sigs, ", "))
+	g.generateMarshal(name, td.Name)
+
+	g.line("")
+	g.line("func (o *%s) writeTBin(enc *%s) error {", name, g.tbin("Encoder"))
+	g.ntmp = 0
+	g.line("switch o.Variant {")
+	for i, v := range td.Variants {
+		field := goFieldName(rdl.Identifier(v))
+		g.line("case %sVariant%s:", name, field)
+		g.line("enc.WriteUnsigned(int(o.Variant))")
+		g.writeValue(types[i], "o."+field, name+"."+field)
+	}
+	g.line("default:")
+	g.line("return %s", g.errorf("Cannot marshal uninitialized union type %s", name))
+	g.line("}")
+	g.line("return enc.Error()")
+	g.line("}")
+	g.generateUnmarshal(name, td.Name)
+
+	g.line("")
+	g.line("func (o *%s) readTBin(dec *%s) error {", name, g.tbin("Decoder"))
+	g.ntmp = 0
+	g.line("o.Variant = %sVariantTag(dec.ReadUnsigned())", name)
+	g.line("switch o.Variant {")
+	for i, v := range td.Variants {
+		field := goFieldName(rdl.Identifier(v))
+		g.line("case %sVariant%s:", name, field)
+		g.line("o.%s = %s", field, g.readValue(types[i]))
+	}
+	g.line("default:")
+	g.line("if dec.Error() == nil {")
+	g.line("return fmt.Errorf(\"Union variant out of range for %s: %%d\", o.Variant)", name)
+	g.line("}")
+	g.line("}")
+	g.line("return dec.Error()")
+	g.line("}")
+}
+
+func (g *codeGenerator) generateMarshal(name string, tname rdl.TypeName) {
+	g.line("")
+	g.line("//")
+	g.line("// MarshalTBin - encodes the %s as TBin, implementing TBinMarshallable", name)
+	g.line("//")
+	g.line("func (o %s) MarshalTBin(enc *%s) error {", name, g.tbin("Encoder"))
+	g.line("enc.WriteType(%s)", signatureVar(tname))
+	g.line("return o.writeTBin(enc)")
+	g.line("}")
+}
+
+func (g *codeGenerator) generateUnmarshal(name string, tname rdl.TypeName) {
+	g.line("")
+	g.line("//")
+	g.line("// UnmarshalTBin - decodes TBin into the %s, implementing TBinUnmarshallable", name)
+	g.line("//")
+	g.line("func (o *%s) UnmarshalTBin(dec *%s) error {", name, g.tbin("Decoder"))
+	g.line("sig, err := dec.ReadType()")
+	g.line("if err != nil {")
+	g.line("return err")
+	g.line("}")
+	g.line("if sig.String() != %s.String() {", signatureVar(tname))
+	g.line("return fmt.Errorf(\"Cannot unmarshal %%v into %s\", sig)", name)
+	g.line("}")
+	g.line("var v %s", name)
+	g.line("err = v.readTBin(dec)")
+	g.line("if err == nil {")
+	g.line("*o = v")
+	g.line("}")
+	g.line("return err")
+	g.line("}")
+}
+
+// present returns a Go condition that is true when an optional field should be encoded. It matches
+// IsZero, as used by the reflective encoder.
+func (g *codeGenerator) present(gt *genType, expr string) string {
+	switch {
+	case gt.ref:
+		return expr + " != nil"
+	case gt.base == rdl.BaseTypeString || gt.base == rdl.BaseTypeSymbol:
+		return expr + ` != ""`
+	case gt.base == rdl.BaseTypeEnum:
+		return expr + " != 0"
+	}
+	return expr + " != nil"
+}
+
+func (g *codeGenerator) writeValue(gt *genType, expr string, what string) {
+	if gt.ref {
+		g.line("if %s == nil {", expr)
+		g.line("return %s", g.errorf("Cannot marshal null pointer for required field %s", what))
+		g.line("}")
+		if gt.base != rdl.BaseTypeStruct && gt.base != rdl.BaseTypeUnion {
+			expr = "*" + expr
+		}
+	}
+	switch gt.base {
+	case rdl.BaseTypeStruct:
+		if gt.def == nil {
+			t := g.tmps("k", "v")
+			k, v := t[0], t[1]
+			g.line("enc.WriteSize(len(%s))", expr)
+			g.line("for %s, %s := range %s {", k, v, expr)
+			g.line("enc.WriteSymbol(string(%s))", k)
+			g.line("enc.Encode(%s)", v)
+			g.line("}")
+			return
+		}
+		g.line("if err := %s.writeTBin(enc); err != nil {", expr)
+		g.line("return err")
+		g.line("}")
+	case rdl.BaseTypeUnion:
+		g.line("if err := %s.writeTBin(enc); err != nil {", expr)
+		g.line("return err")
+		g.line("}")
+	case rdl.BaseTypeArray:
+		v := g.tmps("v")[0]
+		g.line("enc.WriteSize(len(%s))", expr)
+		g.line("for _, %s := range %s {", v, expr)
+		g.writeValue(gt.items, v, what+" item")
+		g.line("}")
+	case rdl.BaseTypeMap:
+		t := g.tmps("k", "v")
+		k, v := t[0], t[1]
+		g.line("enc.WriteSize(len(%s))", expr)
+		g.line("for %s, %s := range %s {", k, v, expr)
+		g.writeValue(gt.keys, k, what+" key")
+		g.writeValue(gt.items, v, what+" item")
+		g.line("}")
+	case rdl.BaseTypeEnum:
+		g.line("enc.WriteInt32(int32(%s))", expr)
+	case rdl.BaseTypeAny:
+		g.line("enc.Encode(%s)", expr)
+	default:
+		prim := codegenPrimitives[gt.base]
+		switch {
+		case gt.base == rdl.BaseTypeSymbol:
+			expr = "string(" + expr + ")"
+		case gt.named:
+			goType := prim.goType
+			if gt.base == rdl.BaseTypeTimestamp || gt.base == rdl.BaseTypeUUID {
+				goType = g.qualified("rdl", goType)
+			}
+			expr = goType + "(" + expr + ")"
+		}
+		g.line("enc.%s(%s)", prim.write, expr)
+	}
+}
+
+// readValue emits the statements that parse a value of the type, and returns the expression for the value
+func (g *codeGenerator) readValue(gt *genType) string {
+	val := g.readPlainValue(gt)
+	if gt.ref && gt.base != rdl.BaseTypeStruct && gt.base != rdl.BaseTypeUnion {
+		v := g.tmps("v")[0]
+		g.line("%s := %s", v, val)
+		return "&" + v
+	}
+	return val
+}
+
+func (g *codeGenerator) readPlainValue(gt *genType) string {
+	switch gt.base {
+	case rdl.BaseTypeStruct:
+		if gt.def == nil {
+			t := g.tmps("m", "s", "k", "v")
+			m, s, k, v := t[0], t[1], t[2], t[3]
+			g.line("%s, _ := dec.DecodeStruct()", m)
+			g.line("%s := make(%s, len(%s))", s, gt.goType, m)
+			g.line("for %s, %s := range %s {", k, v, m)
+			g.line("%s[%s(%s)] = %s", s, g.qualified("rdl", "Symbol"), k, v)
+			g.line("}")
+			return s
+		}
+		fallthrough
+	case rdl.BaseTypeUnion:
+		v := g.tmps("v")[0]
+		g.line("%s := new(%s)", v, gt.name)
+		g.line("if err := %s.readTBin(dec); err != nil {", v)
+		g.line("return err")
+		g.line("}")
+		return v
+	case rdl.BaseTypeArray:
+		t := g.tmps("n", "a", "i")
+		n, a, i := t[0], t[1], t[2]
+		g.line("%s := dec.ReadSize()", n)
+		g.line("%s := make(%s, 0)", a, gt.goType)
+		g.line("for %s := 0; %s < %s && dec.Error() == nil; %s++ {", i, i, n, i)
+		g.line("%s = append(%s, %s)", a, a, g.readValue(gt.items))
+		g.line("}")
+		return a
+	case rdl.BaseTypeMap:
+		t := g.tmps("n", "m", "i", "k")
+		n, m, i, k := t[0], t[1], t[2], t[3]
+		g.line("%s := dec.ReadSize()", n)
+		g.line("%s := make(%s)", m, gt.goType)
+		g.line("for %s := 0; %s < %s && dec.Error() == nil; %s++ {", i, i, n, i)
+		key := g.readValue(gt.keys)
+		if !identifierPattern.MatchString(key) {
+			//bind the key first, so it is parsed before the item
+			g.line("%s := %s", k, key)
+			key = k
+		}
+		g.line("%s[%s] = %s", m, key, g.readValue(gt.items))
+		g.line("}")
+		return m
+	case rdl.BaseTypeEnum:
+		return gt.goType + "(dec.ParseInt())"
+	case rdl.BaseTypeAny:
+		v := g.tmps("v")[0]
+		g.line("var %s interface{}", v)
+		g.line("if err := dec.DecodeAny(&%s); err != nil {", v)
+		g.line("return err")
+		g.line("}")
+		return v
+	case rdl.BaseTypeBool, rdl.BaseTypeInt64:
+		val := "dec." + codegenPrimitives[gt.base].parse + "()"
+		if gt.named {
+			return gt.goType + "(" + val + ")"
+		}
+		return val
+	case rdl.BaseTypeInt8, rdl.BaseTypeInt16, rdl.BaseTypeInt32:
+		return gt.goType + "(dec.ParseInt())"
+	}
+	v := g.tmps("v")[0]
+	g.line("%s, _ := dec.%s()", v, codegenPrimitives[gt.base].parse)
+	if gt.named || gt.base == rdl.BaseTypeSymbol {
+		return gt.goType + "(" + v + ")"
+	}
+	return v
+}
@@ -0,0 +1,151 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+func generateTestCode(test *testing.T, rdlfile string, pkg string) string {
+	schema, err := rdl.ParseRDLFile("../testdata/"+rdlfile, false, false, true)
+	if err != nil {
+		test.Fatalf("Cannot parse %s: %v", rdlfile, err)
+	}
+	var out bytes.Buffer
+	err = GenerateMarshalCode(&out, schema, pkg)
+	if err != nil {
+		test.Fatalf("Cannot generate code for %s: %v", rdlfile, err)
+	}
+	return out.String()
+}
+
+//the generated files in this package must be what the generator currently produces
+func TestGenerateMarshalCode(test *testing.T) {
+	generated := map[string]string{"polyline.rdl": "polyline_tbin.go", "bigtest.rdl": "bigtest_tbin.go"}
+	for rdlfile, gofile := range generated {
+		code := generateTestCode(test, rdlfile, "tbin")
+		expected, err := ioutil.ReadFile(gofile)
+		if err != nil {
+			test.Fatalf("Cannot read %s: %v", gofile, err)
+		}
+		if code != string(expected) {
+			out := gofile
+			if dir, err := ioutil.TempDir("", "tbin"); err == nil {
+				out = filepath.Join(dir, gofile)
+				ioutil.WriteFile(out, []byte(code), 0644)
+			}
+			test.Errorf("Generated code for %s differs from %s. See %s", rdlfile, gofile, out)
+		}
+	}
+}
+
+func TestGenerateMarshalCodeOtherPackage(test *testing.T) {
+	code := generateTestCode(test, "u1.rdl", "model")
+	for _, s := range []string{
+		`"github.com/ardielle/ardielle-go/tbin"`,
+		"var tbinSignatureU1 = tbin.Union(tbin.String, tbinSignatureS2, tbinSignatureS3)",
+		"func (o U1) MarshalTBin(enc *tbin.Encoder) error {",
+		"func (o *U1) UnmarshalTBin(dec *tbin.Decoder) error {",
+		"case U1VariantS3:",
+	} {
+		if !strings.Contains(code, s) {
+			test.Errorf("Generated code for u1.rdl does not contain %q", s)
+		}
+	}
+	if strings.Contains(code, `"github.com/ardielle/ardielle-go/rdl"`) {
+		test.Errorf("Generated code for u1.rdl imports rdl, but doesn't use it")
+	}
+	code = generateTestCode(test, "rdl.rdl", "rdl")
+	if strings.Contains(code, "rdl.") || !strings.Contains(code, "func (o *Schema) readTBin(dec *tbin.Decoder) error {") {
+		test.Errorf("Generated code for the rdl package itself is not as expected")
+	}
+}
+
+func TestGeneratedPolyline(test *testing.T) {
+	line := polyline()
+	tdata, err := Marshal(line)
+	if err != nil {
+		test.Fatalf("Cannot marshal Polyline with generated code: %v", err)
+	}
+	enc := NewEncoder(nil)
+	enc.EncodeReflect(line)
+	if !bytes.Equal(tdata, enc.Bytes()) {
+		test.Errorf("Generated code and reflection encode Polyline differently")
+	}
+	var line2 Polyline
+	if err = Unmarshal(enc.Bytes(), &line2); err != nil {
+		test.Fatalf("Cannot unmarshal Polyline with generated code: %v", err)
+	}
+	if !Equal(line, &line2) {
+		test.Errorf("Generated unmarshal doesn't match the original: %v", line2)
+	}
+	var pt Point
+	if err = Unmarshal(tdata, &pt); err == nil {
+		test.Errorf("Expected a signature mismatch decoding Polyline data with the generated Point code")
+	}
+}
+
+//loadBigTest reads the BigTest data that exercises most of the type variants
+func loadBigTest(tb testing.TB) *BigTest {
+	var bt BigTest
+	j, err := ioutil.ReadFile("../testdata/bigtest.json")
+	if err == nil {
+		err = json.Unmarshal(j, &bt)
+	}
+	if err != nil {
+		tb.Fatalf("Cannot load bigtest.json: %v", err)
+	}
+	return &bt
+}
+
+func TestGeneratedBigTest(test *testing.T) {
+	bt := *loadBigTest(test)
+	tdata, err := Marshal(bt)
+	if err != nil {
+		test.Fatalf("Cannot marshal BigTest with generated code: %v", err)
+	}
+	var bt2 BigTest
+	if err = Unmarshal(tdata, &bt2); err != nil {
+		test.Fatalf("Cannot unmarshal BigTest with generated code: %v", err)
+	}
+	if annotated(bt) != annotated(bt2) {
+		test.Errorf("Generated unmarshal of BigTest doesn't match the original")
+	}
+	var bt3 BigTest
+	if err = NewDecoder(bytes.NewReader(tdata)).DecodeReflect(reflect.ValueOf(&bt3).Elem()); err != nil {
+		test.Fatalf("Cannot decode generated BigTest data by reflection: %v", err)
+	}
+	if annotated(bt) != annotated(bt3) {
+		test.Errorf("Reflective decode of generated BigTest data doesn't match the original")
+	}
+}
+
+func TestGeneratedOptionalFields(test *testing.T) {
+	full := &StringTest{Name: "one", Parent: "a.b", Names: []SimpleName{"x", "y"}, Enc: "%41"}
+	for _, st := range []*StringTest{full, {Name: "two", Parent: "c"}} {
+		tdata, err := Marshal(st)
+		if err != nil {
+			test.Fatalf("Cannot marshal StringTest with generated code: %v", err)
+		}
+		enc := NewEncoder(nil)
+		enc.EncodeReflect(st)
+		for _, data := range [][]byte{tdata, enc.Bytes()} {
+			var st2 StringTest
+			if err = Unmarshal(data, &st2); err != nil {
+				test.Fatalf("Cannot unmarshal StringTest with generated code: %v", err)
+			}
+			if !reflect.DeepEqual(st, &st2) {
+				test.Errorf("Optional fields did not round trip: %v -> %v", st, st2)
+			}
+		}
+	}
+}
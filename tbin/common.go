@@ -12,9 +12,11 @@ import (
 	"io"
 	"reflect"
 	"strings"
+	"sync"
 )
 
-const CurrentVersion = 1 // the first versioned version
+const CurrentVersion = 3 // 2 adds the unsigned integer tags, 3 exact timestamps
+const OldestVersion = 1  // the oldest version that can still be decoded
 
 const NullTag = 0x00      // "nil" or "null"
 const BoolTag = 0x01      // "uvarint(b? 1 : 0)"
@@ -27,6 +29,7 @@ const Float64Tag = 0x07   // "FLOAT64TAG 8_bytes"
 const BytesTag = 0x08     // "BYTESTAG uvarint(len) byte*"
 const StringTag = 0x09    // "STRINGTAG uvarint(utflen) utf8bytes*"
 const TimestampTag = 0x0a // "TIMESTAMPTAG double" - represented as seconds since epoch (1970)
+// From version 3, a timestamp is exact: "TIMESTAMPTAG varint(seconds) uvarint(nanoseconds)"
 const SymbolTag = 0x0b    // "SYMBOLTAG uvarint(id) [string(name)]" the name is only included the first occurrence
 const UUIDTag = 0x0c      // "UUIDTAG byte[16]" = written as 16 bytes, no count
 const ArrayTag = 0x0d     // "ARRAYTAG uvarint(size) value*"
@@ -55,6 +58,13 @@ const CurVersionTag = VersionTag + (CurrentVersion - 1) // "0001 1000
 const MaxVersionTag = VersionTag + VersionDataMask
 const MaxVersion = VersionDataMask + 1
 
+// The unsigned integer tags, new in version 2, share the values of the version tags, which only
+// appear at the start of a stream, never where a value or a type can.
+const Uint8Tag = 0x19  // "UINT8TAG uvarint(n)"
+const Uint16Tag = 0x1a // "UINT16TAG uvarint(n)"
+const Uint32Tag = 0x1b // "UINT32TAG uvarint(n)"
+const Uint64Tag = 0x1c // "UINT64TAG uvarint(n)"
+
 // TinyStrTag is for strings that have a length up to 31 utf8 bytes.
 // Again, this optimization has no effect on packed structs, just the generic encoding
 // (187->160 for my test data). If symbols are used instead of strings, the savings are
@@ -66,6 +76,11 @@ const TinyStrMaxlen = TinyStrDataMask
 
 const FirstUserTag = 0x40 //0x40..0x7f all fit in a single byte tag. Subsequent tags take more. The tag is an unsigned varint.
 
+// DictionaryTag follows the version tag in a stream encoded with a pre-shared Dictionary. It is the last
+// single byte user tag, which no value can start with there, as no type has yet been defined. It is
+// followed by the id and fingerprint of the dictionary.
+const DictionaryTag = 0x7f // "DICTIONARYTAG string(id) uvarint(fingerprint)"
+
 // TagName returns the tag name for the tag.
 func TagName(tag int) string {
 	if (tag & TinyStrTagMask) == TinyStrTag {
@@ -84,6 +99,14 @@ func TagName(tag int) string {
 		return "Int32"
 	case Int64Tag:
 		return "Int64"
+	case Uint8Tag:
+		return "Uint8"
+	case Uint16Tag:
+		return "Uint16"
+	case Uint32Tag:
+		return "Uint32"
+	case Uint64Tag:
+		return "Uint64"
 	case Float32Tag:
 		return "Float32"
 	case Float64Tag:
@@ -163,7 +186,11 @@ func (sig *Signature) cacheKey() string {
 			}
 			s += f.Name
 			s += ":"
-			s += f.Type.String()
+			if f.optional {
+				s += "Any" //an optional field is encoded as a tagged value, so that null can be used
+			} else {
+				s += f.Type.String()
+			}
 		}
 		return s + "}"
 	case ArrayTag:
@@ -182,16 +209,11 @@ func (sig *Signature) cacheKey() string {
 		}
 		return s + ">"
 	case EnumTag:
-		s := "Enum<"
-		for i, t := range sig.Symbols {
-			if i > 0 {
-				if i > 1 {
-					s += ","
-				}
-				s += t
-			}
+		syms := sig.Symbols
+		if len(syms) > 0 && syms[0] == "" {
+			syms = syms[1:] //a decoded signature reserves index zero, enum values start at one
 		}
-		return s + ">"
+		return "Enum<" + strings.Join(syms, ",") + ">"
 	default:
 		return TagName(sig.Tag)
 	}
@@ -227,6 +249,10 @@ var Int8 = &Signature{Tag: Int8Tag}
 var Int16 = &Signature{Tag: Int16Tag}
 var Int32 = &Signature{Tag: Int32Tag}
 var Int64 = &Signature{Tag: Int64Tag}
+var Uint8 = &Signature{Tag: Uint8Tag}
+var Uint16 = &Signature{Tag: Uint16Tag}
+var Uint32 = &Signature{Tag: Uint32Tag}
+var Uint64 = &Signature{Tag: Uint64Tag}
 var Float32 = &Signature{Tag: Float32Tag}
 var Float64 = &Signature{Tag: Float64Tag}
 var Bytes = &Signature{Tag: BytesTag}
@@ -236,6 +262,13 @@ var Symbol = &Signature{Tag: SymbolTag}
 var UUID = &Signature{Tag: UUIDTag}
 var Any = &Signature{Tag: AnyTag}
 
+func init() {
+	//these are shared, so their keys are computed up front rather than lazily by concurrent encoders
+	for _, sig := range []*Signature{Null, Bool, Int8, Int16, Int32, Int64, Uint8, Uint16, Uint32, Uint64, Float32, Float64, Bytes, String, Timestamp, Symbol, UUID, Any} {
+		sig.computeKeys()
+	}
+}
+
 // EncodeUvarint encodes the uvarint to the Writer.
 func EncodeUvarint(out io.Writer, n int) error {
 	var buf [16]byte
@@ -303,17 +336,48 @@ func DecodeVarint(in io.Reader) (int, error) {
 
 //
 // TypeSignature returns a Signature for the type of the given data. Reflection is used.
+// The result is cached for the type, and shared, so it must not be modified.
 //
 func TypeSignature(val interface{}) *Signature {
 	t := reflect.TypeOf(val)
 	return buildTypeSignature(t)
 }
 
+//the reflection plan for each type, i.e. its signature, keyed by reflect.Type. Since they are shared,
+//the cache keys of the signatures are computed before they are stored.
+var signatureCache sync.Map
+
 func buildTypeSignature(t reflect.Type) *Signature {
-	//	return buildTypeSignature2(t, false)
-	//}
+	if sig, ok := signatureCache.Load(t); ok {
+		return sig.(*Signature)
+	}
+	sig := reflectTypeSignature(t)
+	sig.computeKeys()
+	actual, _ := signatureCache.LoadOrStore(t, sig)
+	return actual.(*Signature)
+}
 
-	//func buildTypeSignature2(t reflect.Type, optional bool) *Signature {
+//computeKeys sets the cache key of the signature and all those it refers to
+func (sig *Signature) computeKeys() {
+	if sig.key != "" {
+		return
+	}
+	for _, f := range sig.Fields {
+		f.Type.computeKeys()
+	}
+	for _, v := range sig.Variants {
+		v.computeKeys()
+	}
+	if sig.Keys != nil {
+		sig.Keys.computeKeys()
+	}
+	if sig.Items != nil {
+		sig.Items.computeKeys()
+	}
+	sig.key = sig.cacheKey()
+}
+
+func reflectTypeSignature(t reflect.Type) *Signature {
 	typeName := t.String()
 	switch typeName {
 	case "rdl.UUID":
@@ -369,7 +433,10 @@ func buildTypeSignature(t reflect.Type) *Signature {
 			}
 		}
 		return Struct(fields...)
-	case reflect.Slice:
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Bytes
+		}
 		items := buildTypeSignature(t.Elem())
 		return Array(items)
 	case reflect.Ptr:
@@ -378,20 +445,28 @@ func buildTypeSignature(t reflect.Type) *Signature {
 		return String
 	case reflect.Bool:
 		return Bool
-	case reflect.Int8, reflect.Uint8:
+	case reflect.Int8:
 		return Int8
-	case reflect.Int16, reflect.Uint16:
+	case reflect.Int16:
 		return Int16
 	case reflect.Int:
 		syms := enumSymbols(t)
 		if syms != nil {
 			return Enum(syms...)
 		}
+		return Int32 //encoding a value that doesn't fit is an error
+	case reflect.Int32:
 		return Int32
-	case reflect.Int32, reflect.Uint, reflect.Uint32:
-		return Int32
-	case reflect.Int64, reflect.Uint64:
+	case reflect.Int64:
 		return Int64
+	case reflect.Uint8:
+		return Uint8
+	case reflect.Uint16:
+		return Uint16
+	case reflect.Uint32:
+		return Uint32
+	case reflect.Uint, reflect.Uint64:
+		return Uint64
 	case reflect.Float32:
 		return Float32
 	case reflect.Float64:
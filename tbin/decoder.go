@@ -8,33 +8,79 @@
 package tbin
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"math"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/ardielle/ardielle-go/rdl"
 )
 
 func (d *Decoder) readHeader() error {
+	if d.readVersion() != nil {
+		return d.err
+	}
+	return d.readDictionary()
+}
+
+func (d *Decoder) readVersion() error {
 	tag := int(d.ParseUnsigned())
 	if (tag & VersionTagMask) == VersionTag {
 		d.dataVersion = (tag & VersionDataMask) + 1
-		if d.dataVersion != CurrentVersion {
+		if d.dataVersion < OldestVersion || d.dataVersion > CurrentVersion {
 			d.err = fmt.Errorf("TBin version not supported: %d", d.dataVersion)
-			return d.err
 		}
-		d.types = make([]*Signature, 0)
-		return nil
+		return d.err
 	}
 	d.err = fmt.Errorf("not a valid tbin file")
 	return d.err
 }
 
-func (d *Decoder) Decode(data interface{}) error {
+//
+// Reset - discard the state of the current stream, and read a new one from r, so the decoder can be
+// reused. If keepTypes is true, the types and symbols defined so far remain bound, as for a stream
+// from an Encoder that was reset likewise after encoding the one this decoder has just decoded.
+//
+func (d *Decoder) Reset(r io.Reader, keepTypes bool) error {
+	d.setInput(r)
+	d.err = nil
+	d.pendingTag = -1
+	d.currentTag = 0
+	d.currentCount = 0
+	d.depth = 0
+	d.values = 0
+	if keepTypes {
+		return d.readVersion()
+	}
+	d.types = d.types[:0]
+	d.syms = d.syms[:0]
+	d.zeroWidths = nil
+	return d.readHeader()
+}
+
+func (d *Decoder) setInput(r io.Reader) {
+	if d.opts.MaxBytes > 0 {
+		r = &limitedReader{r: r, remaining: d.opts.MaxBytes, limit: d.opts.MaxBytes}
+	}
+	if d.in == nil {
+		d.in = bufio.NewReader(r)
+	} else {
+		d.in.Reset(r)
+	}
+}
+
+// maxPrealloc bounds the capacity allocated up front for a collection or byte array, so that
+// a corrupt length cannot exhaust memory before the data is found to be missing.
+const maxPrealloc = 4096
+
+func (d *Decoder) Decode(data interface{}) (err error) {
+	defer d.recoverError(&err)
 	rv := reflect.ValueOf(data)
-	if !rv.IsNil() && rv.Kind() == reflect.Ptr {
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
 		v := rv.Elem()
 		if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
 			//Empty interface, just do the generic decode to a map
@@ -65,12 +111,165 @@ func (d *Decoder) Error() error {
 	return d.err
 }
 
+// recoverError turns a panic caused by input that doesn't fit the target (for example, a value
+// of one kind being stored into a field of another) into an error.
+func (d *Decoder) recoverError(err *error) {
+	if r := recover(); r != nil {
+		if d.err == nil {
+			d.err = fmt.Errorf("Cannot decode TBin data: %v", r)
+		}
+		*err = d.err
+	}
+}
+
+// enter tracks the nesting of values and type definitions, and counts them against the MaxValues
+// limit. Each call must be paired with a deferred call to leave.
+func (d *Decoder) enter() error {
+	d.depth++
+	d.values++
+	if d.err == nil {
+		if d.depth > d.opts.MaxDepth {
+			d.err = fmt.Errorf("TBin data is nested deeper than the limit of %d", d.opts.MaxDepth)
+		} else if d.values > d.opts.MaxValues {
+			d.err = fmt.Errorf("TBin data has more values than the limit of %d", d.opts.MaxValues)
+		}
+	}
+	return d.err
+}
+
+func (d *Decoder) leave() {
+	d.depth--
+}
+
+// parseSize reads the element count of a collection, enforcing the MaxCollectionLength limit.
+func (d *Decoder) parseSize() int {
+	n := d.ParseUnsigned()
+	if d.err != nil {
+		return 0
+	}
+	if n > math.MaxInt32 || (d.opts.MaxCollectionLength > 0 && n > uint(d.opts.MaxCollectionLength)) {
+		d.err = fmt.Errorf("TBin collection length %d exceeds the limit", n)
+		return 0
+	}
+	return int(n)
+}
+
+// maxZeroWidthItems bounds collections whose items occupy no bytes in the stream (i.e. nulls,
+// or structs of them). Such a count cannot be checked against the input, so a tiny payload
+// could otherwise expand to an arbitrary number of values.
+const maxZeroWidthItems = 64
+
+// hasWidth returns true if every value of the signature occupies at least one byte.
+func hasWidth(sig *Signature) bool {
+	switch sig.Tag {
+	case NullTag:
+		return false
+	case StructTag:
+		if sig.Fields == nil {
+			return true
+		}
+		for _, f := range sig.Fields {
+			if hasWidth(f.Type) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// checkItems rejects a long collection of items that take no space in the stream.
+func (d *Decoder) checkItems(count int, items ...*Signature) error {
+	if d.err == nil && count > maxZeroWidthItems {
+		for _, sig := range items {
+			if hasWidth(sig) {
+				return nil
+			}
+		}
+		d.err = fmt.Errorf("TBin collection of %d items with no content exceeds the limit of %d", count, maxZeroWidthItems)
+	}
+	return d.err
+}
+
+// zeroWidthValues counts the values of the signature that occupy no bytes in the stream, stopping
+// once the count exceeds maxZeroWidthItems. The counts are remembered, as the fields of a type can
+// refer to the same defined type many times.
+func (d *Decoder) zeroWidthValues(sig *Signature) int {
+	switch {
+	case sig.Tag == NullTag:
+		return 1
+	case sig.Tag != StructTag || sig.Fields == nil:
+		return 0
+	}
+	if n, ok := d.zeroWidths[sig]; ok {
+		return n
+	}
+	n := 0
+	if !hasWidth(sig) {
+		n = 1
+	}
+	for _, f := range sig.Fields {
+		n += d.zeroWidthValues(f.Type)
+		if n > maxZeroWidthItems {
+			break
+		}
+	}
+	if d.zeroWidths == nil {
+		d.zeroWidths = make(map[*Signature]int)
+	}
+	d.zeroWidths[sig] = n
+	return n
+}
+
+func preallocSize(n int) int {
+	if n > maxPrealloc {
+		return maxPrealloc
+	}
+	return n
+}
+
+// defineType parses the typedef that must follow the first use of a user tag, and binds it to the tag.
+func (d *Decoder) defineType(tag uint) *Signature {
+	if d.err != nil {
+		return nil
+	}
+	idx := int(tag - FirstUserTag)
+	if idx != len(d.types) {
+		d.err = fmt.Errorf("ref to a undefined tag: 0x%02x", tag)
+		return nil
+	}
+	if d.opts.MaxTypes > 0 && len(d.types) >= d.opts.MaxTypes {
+		d.err = fmt.Errorf("TBin data defines more than the limit of %d types", d.opts.MaxTypes)
+		return nil
+	}
+	ttype := d.parseType()
+	if ttype == nil {
+		if d.err == nil {
+			d.err = fmt.Errorf("First use of a user tag must be followed by a typedef.")
+		}
+		return nil
+	}
+	if d.zeroWidthValues(ttype) > maxZeroWidthItems {
+		d.err = fmt.Errorf("TBin type definition has more than the limit of %d values with no content", maxZeroWidthItems)
+		return nil
+	}
+	d.types = append(d.types, ttype)
+	return ttype
+}
+
 func (d *Decoder) CurrentCount() int {
 	return d.currentCount
 }
 
 func (d *Decoder) parseType() *Signature {
+	defer d.leave()
+	if d.enter() != nil {
+		return nil
+	}
 	tag := d.ParseUnsigned()
+	if d.err != nil {
+		return nil
+	}
 	if tag >= FirstUserTag {
 		idx := int(tag - FirstUserTag)
 		if idx >= len(d.types) {
@@ -80,41 +279,55 @@ func (d *Decoder) parseType() *Signature {
 		return d.types[idx]
 	}
 	switch tag {
-	case ArrayTag:
-		//all arrays get typedef'd now, this code may be dead
+	case ArrayTag, DefArrayTag:
+		//all arrays get typedef'd now, so ArrayTag here may be dead
 		itemsType := d.parseType()
+		if itemsType == nil {
+			return nil
+		}
 		return Array(itemsType)
-	case MapTag:
-		//all maps get typedef'd now, this code may be dead
-		keysType := d.parseType()
-		itemsType := d.parseType()
-		return Map(keysType, itemsType)
-	case DefArrayTag:
-		itemType := d.parseType()
-		return Array(itemType)
-	case DefMapTag:
+	case MapTag, DefMapTag:
+		//all maps get typedef'd now, so MapTag here may be dead
 		keysType := d.parseType()
+		if keysType == nil {
+			return nil
+		}
 		itemsType := d.parseType()
+		if itemsType == nil {
+			return nil
+		}
 		return Map(keysType, itemsType)
 	case DefStructTag:
-		size := int(d.ParseUnsigned())
-		fields := make([]*FieldSignature, size)
+		size := d.parseSize()
+		fields := make([]*FieldSignature, 0, preallocSize(size))
 		for i := 0; i < size; i++ {
 			fname, _ := d.ParseString()
 			ftype := d.parseType()
-			fields[i] = Field(fname, ftype, (ftype == Any))
+			if ftype == nil {
+				return nil
+			}
+			fields = append(fields, Field(fname, ftype, (ftype == Any)))
+		}
+		if d.err != nil {
+			return nil
 		}
 		return Struct(fields...)
 	case DefUnionTag:
-		size := int(d.ParseUnsigned())
+		size := d.parseSize()
 		var variants []*Signature
 		for i := 0; i < size; i++ {
 			variantType := d.parseType()
+			if variantType == nil {
+				return nil
+			}
 			variants = append(variants, variantType)
 		}
+		if d.err != nil {
+			return nil
+		}
 		return Union(variants...)
 	case DefEnumTag:
-		size := int(d.ParseUnsigned())
+		size := d.parseSize()
 		syms := []string{""}
 		for i := 0; i < size; i++ {
 			sym, err := d.ParseString()
@@ -125,6 +338,8 @@ func (d *Decoder) parseType() *Signature {
 			syms = append(syms, sym)
 		}
 		return Enum(syms...)
+	case NullTag:
+		return Null
 	case BoolTag:
 		return Bool
 	case Int8Tag:
@@ -135,30 +350,53 @@ func (d *Decoder) parseType() *Signature {
 		return Int32
 	case Int64Tag:
 		return Int64
+	case Uint8Tag, Uint16Tag, Uint32Tag, Uint64Tag:
+		return d.unsignedType(tag)
 	case Float32Tag:
 		return Float32
 	case Float64Tag:
 		return Float64
+	case BytesTag:
+		return Bytes
 	case StringTag:
 		return String
+	case SymbolTag:
+		return Symbol
 	case UUIDTag:
 		return UUID
 	case TimestampTag:
 		return Timestamp
 	case AnyTag:
 		return Any
-		//	case StructTag:
-		//		//fix: it means symbol/any, the any needs a tag to parse
-		//		panic("Struct!")
+	case StructTag:
+		//a naked struct: symbol keys, and every value is tagged
+		return &Signature{Tag: StructTag}
 	default:
 		d.err = fmt.Errorf("Unexpected tag definition type in TBin stream: 0x%2x", tag)
 		return nil
 	}
 }
 
+func (d *Decoder) nextTag() uint {
+	if d.pendingTag >= 0 {
+		tag := uint(d.pendingTag)
+		d.pendingTag = -1
+		return tag
+	}
+	tag := d.ParseUnsigned()
+	if tag >= Uint8Tag && tag <= Uint64Tag && d.dataVersion < 2 {
+		d.err = fmt.Errorf("Unexpected tag value for version %d: 0x%02x", d.dataVersion, tag)
+	}
+	return tag
+}
+
 func (d *Decoder) decode() (interface{}, error) {
+	defer d.leave()
+	if d.enter() != nil {
+		return nil, d.err
+	}
 again:
-	tag := d.ParseUnsigned()
+	tag := d.nextTag()
 	if d.err != nil {
 		return nil, d.err
 	}
@@ -168,18 +406,15 @@ again:
 			ttype := d.types[idx]
 			return d.decodeType(ttype)
 		}
-		ttype := d.parseType()
-		if ttype == nil {
-			d.err = fmt.Errorf("First use of a user tag must be followed by a typedef.")
+		if d.defineType(tag) == nil {
 			return nil, d.err
 		}
-		d.types = append(d.types, ttype)
 		goto again
 	} else {
 		if (tag & TinyStrTagMask) == TinyStrTag {
 			n := tag & TinyStrDataMask
 			tinybuf := make([]byte, n)
-			d.err = d.readBytes(tinybuf)
+			d.readBytes(tinybuf)
 			return string(tinybuf), d.err
 		}
 		switch tag {
@@ -188,6 +423,7 @@ again:
 		case BoolTag:
 			n, err := d.in.ReadByte()
 			if err != nil {
+				d.err = err
 				return nil, err
 			}
 			if n != 0 {
@@ -206,6 +442,8 @@ again:
 		case Int64Tag:
 			n := d.ParseInt64()
 			return n, d.err
+		case Uint8Tag, Uint16Tag, Uint32Tag, Uint64Tag:
+			return d.decodeUnsigned(int(tag))
 		case Float32Tag:
 			return d.ParseFloat32()
 		case Float64Tag:
@@ -225,16 +463,17 @@ again:
 		case ArrayTag:
 			return d.DecodeArray()
 		case MapTag:
-			return d.DecodeMap()
+			return d.decodeGenericMap()
 		}
 	}
-	return nil, fmt.Errorf("Unexpected tag value: 0x%02x", tag)
+	d.err = fmt.Errorf("Unexpected tag value: 0x%02x", tag)
+	return nil, d.err
 }
 
 func (d *Decoder) DecodeStruct() (map[string]interface{}, error) {
-	nfields := int(d.ParseUnsigned())
-	result := make(map[string]interface{})
-	for i := 0; i < nfields; i++ {
+	nfields := d.parseSize()
+	result := make(map[string]interface{}, preallocSize(nfields))
+	for i := 0; i < nfields && d.err == nil; i++ {
 		name, _ := d.ParseSymbol()
 		val, _ := d.decode()
 		result[name] = val
@@ -243,9 +482,9 @@ func (d *Decoder) DecodeStruct() (map[string]interface{}, error) {
 }
 
 func (d *Decoder) DecodeArray() ([]interface{}, error) {
-	count := int(d.ParseUnsigned())
+	count := d.parseSize()
 	var result []interface{}
-	for i := 0; i < count; i++ {
+	for i := 0; i < count && d.err == nil; i++ {
 		val, _ := d.decode()
 		result = append(result, val)
 	}
@@ -253,9 +492,9 @@ func (d *Decoder) DecodeArray() ([]interface{}, error) {
 }
 
 func (d *Decoder) DecodeMap() (map[string]interface{}, error) {
-	count := int(d.ParseUnsigned())
-	result := make(map[string]interface{})
-	for i := 0; i < count; i++ {
+	count := d.parseSize()
+	result := make(map[string]interface{}, preallocSize(count))
+	for i := 0; i < count && d.err == nil; i++ {
 		key, _ := d.decode()
 		val, _ := d.decode()
 		skey, ok := key.(string)
@@ -268,9 +507,65 @@ func (d *Decoder) DecodeMap() (map[string]interface{}, error) {
 	return result, d.err
 }
 
+// decodeGenericMap decodes an untyped map. The result is a map[string]interface{} when all keys
+// are strings, as with DecodeMap, otherwise it is a map[interface{}]interface{}.
+func (d *Decoder) decodeGenericMap() (interface{}, error) {
+	return d.decodeGenericMapEntries(d.parseSize())
+}
+
+func (d *Decoder) decodeGenericMapEntries(count int) (interface{}, error) {
+	var result map[string]interface{}
+	var other map[interface{}]interface{}
+	for i := 0; i < count && d.err == nil; i++ {
+		key, _ := d.decode()
+		val, _ := d.decode()
+		result, other = addMapEntry(result, other, key, val)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if other != nil {
+		return other, nil
+	}
+	if result == nil {
+		result = make(map[string]interface{})
+	}
+	return result, nil
+}
+
+func addMapEntry(strmap map[string]interface{}, anymap map[interface{}]interface{}, key interface{}, val interface{}) (map[string]interface{}, map[interface{}]interface{}) {
+	if anymap == nil {
+		if skey, ok := key.(string); ok {
+			if strmap == nil {
+				strmap = make(map[string]interface{})
+			}
+			strmap[skey] = val
+			return strmap, nil
+		}
+		//the first non-string key: switch representations
+		anymap = make(map[interface{}]interface{}, len(strmap)+1)
+		for k, v := range strmap {
+			anymap[k] = v
+		}
+	}
+	anymap[key] = val
+	return nil, anymap
+}
+
 func (d *Decoder) decodeType(tt *Signature) (interface{}, error) {
+	defer d.leave()
+	if d.enter() != nil {
+		return nil, d.err
+	}
+	if tt == nil {
+		d.err = fmt.Errorf("decode of a missing type")
+		return nil, d.err
+	}
 	switch tt.Tag {
 	case StructTag:
+		if tt.Fields == nil {
+			return d.DecodeStruct()
+		}
 		result := make(map[string]interface{}, 0)
 		for _, f := range tt.Fields {
 			tmp, err := d.decodeType(f.Type)
@@ -281,13 +576,14 @@ func (d *Decoder) decodeType(tt *Signature) (interface{}, error) {
 		}
 		return result, nil
 	case MapTag:
-		mlen := int(d.ParseUnsigned())
-		if d.err != nil {
+		mlen := d.parseSize()
+		if d.checkItems(mlen, tt.Keys, tt.Items) != nil {
 			return nil, d.err
 		}
 		keys := tt.Keys
 		items := tt.Items
-		result := make(map[string]interface{}) //! I could do better
+		var result map[string]interface{}
+		var other map[interface{}]interface{}
 		for i := 0; i < mlen; i++ {
 			ke, err := d.decodeType(keys)
 			if err != nil {
@@ -297,39 +593,52 @@ func (d *Decoder) decodeType(tt *Signature) (interface{}, error) {
 			if err != nil {
 				return nil, err
 			}
-			if skey, ok := ke.(string); ok {
-				result[skey] = it
-			} else {
-				return nil, fmt.Errorf("cannot decode Map from tbin: key is non-string derived: %v", ke)
-			}
+			result, other = addMapEntry(result, other, ke, it)
+		}
+		if other != nil {
+			return other, nil
+		}
+		if result == nil {
+			result = make(map[string]interface{})
 		}
 		return result, nil
 	case ArrayTag:
-		alen := int(d.ParseUnsigned())
-		if d.err != nil {
+		alen := d.parseSize()
+		if d.checkItems(alen, tt.Items) != nil {
 			return nil, d.err
 		}
 		items := tt.Items
-		switch items.Tag {
-		default:
-			result := make([]interface{}, alen)
-			for i := 0; i < alen; i++ {
-				tmp, err := d.decodeType(items)
-				if err != nil {
-					return nil, err
-				}
-				result[i] = tmp
+		result := make([]interface{}, 0, preallocSize(alen))
+		for i := 0; i < alen; i++ {
+			tmp, err := d.decodeType(items)
+			if err != nil {
+				return nil, err
 			}
-			return result, nil
+			result = append(result, tmp)
 		}
+		return result, nil
 	case AnyTag:
 		return d.decode()
 	case EnumTag:
 		nsym := d.ParseInt()
-		return tt.Symbols[nsym], d.err
+		if d.err != nil {
+			return nil, d.err
+		}
+		if nsym < 0 || nsym >= len(tt.Symbols) {
+			d.err = fmt.Errorf("Enum value out of range: %d", nsym)
+			return nil, d.err
+		}
+		return tt.Symbols[nsym], nil
 	case UnionTag:
-		nvariant := d.ParseUnsigned() - 1
-		return d.decodeType(tt.Variants[nvariant])
+		nvariant := int(d.ParseUnsigned())
+		if d.err != nil {
+			return nil, d.err
+		}
+		if nvariant < 1 || nvariant > len(tt.Variants) {
+			d.err = fmt.Errorf("Union variant out of range: %d", nvariant)
+			return nil, d.err
+		}
+		return d.decodeType(tt.Variants[nvariant-1])
 	case Int8Tag:
 		n := int8(d.ParseInt())
 		return n, d.err
@@ -342,12 +651,18 @@ func (d *Decoder) decodeType(tt *Signature) (interface{}, error) {
 	case Int64Tag:
 		n := d.ParseInt64()
 		return n, d.err
+	case Uint8Tag, Uint16Tag, Uint32Tag, Uint64Tag:
+		return d.decodeUnsigned(tt.Tag)
 	case Float32Tag:
 		return d.ParseFloat32()
 	case Float64Tag:
 		return d.ParseFloat64()
+	case BytesTag:
+		return d.ParseBytes()
 	case StringTag:
 		return d.ParseString()
+	case SymbolTag:
+		return d.ParseSymbol()
 	case TimestampTag:
 		return d.ParseTimestamp()
 	case UUIDTag:
@@ -360,8 +675,10 @@ func (d *Decoder) decodeType(tt *Signature) (interface{}, error) {
 			}
 		}
 		return false, d.err
+	case NullTag:
+		return nil, d.err
 	}
-	d.err = fmt.Errorf("decode unhandled type (0x%02x): %v", tt.Tag, tt)
+	d.err = fmt.Errorf("decode unhandled type (0x%02x)", tt.Tag)
 	return nil, d.err
 }
 
@@ -407,19 +724,73 @@ func (d *Decoder) ParseUnsigned64() uint64 {
 	return 0
 }
 
+//unsignedType returns the signature for an unsigned integer tag, which only version 2 data has
+func (d *Decoder) unsignedType(tag uint) *Signature {
+	if d.dataVersion < 2 {
+		d.err = fmt.Errorf("Unexpected tag value for version %d: 0x%02x", d.dataVersion, tag)
+		return nil
+	}
+	switch tag {
+	case Uint8Tag:
+		return Uint8
+	case Uint16Tag:
+		return Uint16
+	case Uint32Tag:
+		return Uint32
+	}
+	return Uint64
+}
+
+//parseUnsigned parses the value of an unsigned integer type, failing if it is out of range for it
+func (d *Decoder) parseUnsigned(tag int) uint64 {
+	n := d.ParseUnsigned64()
+	max := uint64(math.MaxUint64)
+	switch tag {
+	case Uint8Tag:
+		max = math.MaxUint8
+	case Uint16Tag:
+		max = math.MaxUint16
+	case Uint32Tag:
+		max = math.MaxUint32
+	}
+	if d.err == nil && n > max {
+		d.err = fmt.Errorf("Value %d overflows %s", n, TagName(tag))
+	}
+	return n
+}
+
+func (d *Decoder) decodeUnsigned(tag int) (interface{}, error) {
+	n := d.parseUnsigned(tag)
+	switch tag {
+	case Uint8Tag:
+		return uint8(n), d.err
+	case Uint16Tag:
+		return uint16(n), d.err
+	case Uint32Tag:
+		return uint32(n), d.err
+	}
+	return n, d.err
+}
+
 func (d *Decoder) ParseSymbol() (string, error) {
 	if d.err == nil {
 		id := d.ParseUnsigned()
 		if d.err == nil {
 			if int(id) == len(d.syms) {
+				if d.opts.MaxSymbols > 0 && len(d.syms) >= d.opts.MaxSymbols {
+					d.err = fmt.Errorf("TBin data defines more than the limit of %d symbols", d.opts.MaxSymbols)
+					return "", d.err
+				}
 				name, err := d.ParseString()
 				if err == nil {
 					d.syms = append(d.syms, name)
 					return name, nil
 				}
-			} else {
+			} else if id < uint(len(d.syms)) {
 				name := d.syms[id]
 				return name, nil
+			} else {
+				d.err = fmt.Errorf("ref to an undefined symbol: %d", id)
 			}
 		}
 	}
@@ -428,6 +799,9 @@ func (d *Decoder) ParseSymbol() (string, error) {
 
 func (d *Decoder) ParseBool() bool {
 	b := false
+	if d.err != nil {
+		return b
+	}
 	n, err := d.in.ReadByte()
 	if err != nil {
 		d.err = err
@@ -443,8 +817,8 @@ func (d *Decoder) ParseInt() int {
 }
 
 func (d *Decoder) ParseInt64() int64 {
-	n := int(d.ParseUnsigned64())
-	return int64((n >> 1) ^ -(n & 1)) // back to two's-complement
+	n := d.ParseUnsigned64()
+	return int64(n>>1) ^ -int64(n&1) // back to two's-complement, shifting unsigned so that no bits are lost
 }
 
 func (d *Decoder) BoolValue() bool {
@@ -543,17 +917,12 @@ func (d *Decoder) ParseFloat64() (float64, error) {
 
 func (d *Decoder) readBytes(buf []byte) error {
 	if d.err == nil {
-		offset := 0
-		end := len(buf)
-		remaining := len(buf)
-		for remaining > 0 {
-			n, err := d.in.Read(buf[offset:end])
-			if err != nil {
-				d.err = err
-				break
+		_, err := io.ReadFull(d.in, buf)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
 			}
-			remaining -= n
-			offset += n
+			d.err = err
 		}
 	}
 	return d.err
@@ -564,9 +933,25 @@ func (d *Decoder) ParseBytes() ([]byte, error) {
 		return nil, d.err
 	}
 	n := d.ParseUnsigned()
-	buf := make([]byte, n)
-	err := d.readBytes(buf)
-	return buf, err
+	if d.err != nil {
+		return nil, d.err
+	}
+	if n <= maxPrealloc {
+		buf := make([]byte, n)
+		err := d.readBytes(buf)
+		return buf, err
+	}
+	//a large length is only trusted as far as the data actually arrives
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, d.in, int64(n))
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func (d *Decoder) ParseString() (string, error) {
@@ -578,18 +963,32 @@ func (d *Decoder) ParseString() (string, error) {
 }
 
 func (d *Decoder) ParseTimestamp() (rdl.Timestamp, error) {
-	secs, err := d.ParseFloat64()
-	if err != nil {
-		var ts rdl.Timestamp
-		return ts, err
+	var ts rdl.Timestamp
+	if d.dataVersion < 3 {
+		secs, err := d.ParseFloat64()
+		if err != nil {
+			return ts, err
+		}
+		return rdl.TimestampFromEpoch(secs), nil
 	}
-	return rdl.TimestampFromEpoch(secs), nil
+	secs := d.ParseInt64()
+	nanos := d.ParseUnsigned64()
+	if d.err == nil && nanos >= uint64(time.Second) {
+		d.err = fmt.Errorf("Bad timestamp nanoseconds: %d", nanos)
+	}
+	if d.err != nil {
+		return ts, d.err
+	}
+	return rdl.Timestamp{Time: time.Unix(secs, int64(nanos)).UTC()}, nil
 }
 
 func (d *Decoder) ParseUUID() (rdl.UUID, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
 	u := make([]byte, 16)
-	n, err := d.in.Read(u)
-	if err != nil || n != 16 {
+	_, err := io.ReadFull(d.in, u)
+	if err != nil {
 		d.err = fmt.Errorf("Bad UUID value in tbin stream")
 		return nil, d.err
 	}
@@ -604,7 +1003,7 @@ func (d *Decoder) ReadUnsigned() int {
 	return int(d.ParseUnsigned())
 }
 func (d *Decoder) ReadSize() int {
-	return d.ReadUnsigned()
+	return d.parseSize()
 }
 
 func (d *Decoder) ReadType() (*Signature, error) {
@@ -621,12 +1020,9 @@ again:
 	if idx < len(d.types) {
 		ttype = d.types[idx]
 	} else {
-		ttype = d.parseType()
-		if ttype == nil {
-			d.err = fmt.Errorf("First use of a user tag must be followed by a typedef.")
+		if d.defineType(tag) == nil {
 			return nil, d.err
 		}
-		d.types = append(d.types, ttype)
 		goto again
 	}
 	return ttype, nil
@@ -664,15 +1060,125 @@ func (d *Decoder) buildTypeSignature(t *Signature, out *bytes.Buffer) error {
 	return err
 }
 
-func (d *Decoder) DecodeReflect(v reflect.Value) error {
-again:
-	var tag int
-	if d.pendingTag >= 0 {
-		tag = d.pendingTag
-		d.pendingTag = -1
-	} else {
-		tag = int(d.ParseUnsigned())
+// setReflected stores a decoded value into v, converting it to the type of v as needed.
+func (d *Decoder) setReflected(v reflect.Value, x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	xv := reflect.ValueOf(x)
+	t := v.Type()
+	if t.Kind() == reflect.Interface {
+		if !xv.Type().Implements(t) {
+			d.err = fmt.Errorf("Cannot assign %v to %v", xv.Type(), t)
+			return d.err
+		}
+	} else if xv.Type() != t {
+		if t.Kind() == reflect.Array && xv.Kind() == reflect.Slice && d.checkArrayLength(xv.Len(), v) != nil {
+			return d.err
+		}
+		if isIntKind(t.Kind()) || isUintKind(t.Kind()) {
+			//an integer is converted only if it is in range
+			if isIntKind(xv.Kind()) {
+				return d.setInt(v, xv.Int())
+			} else if isUintKind(xv.Kind()) {
+				return d.setUint(v, xv.Uint())
+			}
+		}
+		if !xv.Type().ConvertibleTo(t) {
+			d.err = fmt.Errorf("Cannot assign %v to %v", xv.Type(), t)
+			return d.err
+		}
+		xv = xv.Convert(t)
+	}
+	v.Set(xv)
+	return nil
+}
+
+//checkArrayLength fails unless the Go array has room for exactly the count of items
+func (d *Decoder) checkArrayLength(count int, v reflect.Value) error {
+	if count != v.Len() {
+		d.err = fmt.Errorf("Cannot decode %d items into %v", count, v.Type())
+	}
+	return d.err
+}
+
+//setInt sets the integer target to n, failing rather than truncating if it is out of range
+func (d *Decoder) setInt(v reflect.Value, n int64) error {
+	switch {
+	case isIntKind(v.Kind()):
+		if v.OverflowInt(n) {
+			d.err = fmt.Errorf("Value %d overflows %v", n, v.Type())
+			return d.err
+		}
+		v.SetInt(n)
+	case isUintKind(v.Kind()):
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			d.err = fmt.Errorf("Value %d overflows %v", n, v.Type())
+			return d.err
+		}
+		v.SetUint(uint64(n))
+	default:
+		return d.setReflected(v, n)
 	}
+	return nil
+}
+
+//setUint sets the integer target to n, failing rather than truncating if it is out of range
+func (d *Decoder) setUint(v reflect.Value, n uint64) error {
+	switch {
+	case isIntKind(v.Kind()):
+		if n > math.MaxInt64 || v.OverflowInt(int64(n)) {
+			d.err = fmt.Errorf("Value %d overflows %v", n, v.Type())
+			return d.err
+		}
+		v.SetInt(int64(n))
+	case isUintKind(v.Kind()):
+		if v.OverflowUint(n) {
+			d.err = fmt.Errorf("Value %d overflows %v", n, v.Type())
+			return d.err
+		}
+		v.SetUint(n)
+	default:
+		return d.setReflected(v, n)
+	}
+	return nil
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func isEmptyInterface(v reflect.Value) bool {
+	return v.Kind() == reflect.Interface && v.NumMethod() == 0
+}
+
+func (d *Decoder) DecodeReflect(v reflect.Value) (err error) {
+	defer d.recoverError(&err)
+	return d.decodeReflect(v)
+}
+
+// DecodeAny decodes a tagged value, as written where a signature calls for Any (including optional
+// struct fields), into the value that data points to. A null leaves the target unchanged.
+func (d *Decoder) DecodeAny(data interface{}) (err error) {
+	defer d.recoverError(&err)
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("Cannot decode into this: %v", data)
+	}
+	return d.decodeTypeReflect(Any, v.Elem())
+}
+
+func (d *Decoder) decodeReflect(v reflect.Value) error {
+	defer d.leave()
+	if d.enter() != nil {
+		return d.err
+	}
+again:
+	tag := int(d.nextTag())
 	if d.err != nil {
 		return d.err
 	}
@@ -680,14 +1186,18 @@ again:
 		idx := int(tag - FirstUserTag)
 		if idx < len(d.types) {
 			ttype := d.types[idx]
+			if isEmptyInterface(v) {
+				x, err := d.decodeType(ttype)
+				if err != nil {
+					return err
+				}
+				return d.setReflected(v, x)
+			}
 			return d.decodeTypeReflect(ttype, v)
 		}
-		ttype := d.parseType()
-		if ttype == nil {
-			d.err = fmt.Errorf("First use of a user tag must be followed by a typedef.")
+		if d.defineType(uint(tag)) == nil {
 			return d.err
 		}
-		d.types = append(d.types, ttype)
 		goto again
 	} else {
 		if (tag & TinyStrTagMask) == TinyStrTag {
@@ -695,13 +1205,7 @@ again:
 			tinybuf := make([]byte, n)
 			d.err = d.readBytes(tinybuf)
 			if d.err == nil {
-				s := string(tinybuf)
-				if v.Kind() == reflect.Interface {
-					vv := reflect.New(reflect.TypeOf(s))
-					v.Set(vv)
-				} else {
-					v.SetString(s)
-				}
+				return d.setReflected(v, string(tinybuf))
 			}
 			return d.err
 		}
@@ -711,108 +1215,80 @@ again:
 			return nil
 		case BoolTag:
 			b := d.ParseBool()
-			if v.Kind() == reflect.Interface {
-				v.Set(reflect.New(reflect.TypeOf(b)))
-			} else {
-				v.SetBool(b)
+			if d.err == nil {
+				return d.setReflected(v, b)
 			}
 			return d.err
 		case Int8Tag:
 			n := int8(d.ParseInt())
 			if d.err == nil {
-				if v.Kind() == reflect.Interface {
-					v.Set(reflect.New(reflect.TypeOf(n)))
-				} else {
-					v.SetInt(int64(n))
-				}
+				return d.setReflected(v, n)
 			}
 			return d.err
 		case Int16Tag:
 			n := int16(d.ParseInt())
 			if d.err == nil {
-				if v.Kind() == reflect.Interface {
-					v.Set(reflect.New(reflect.TypeOf(n)))
-				} else {
-					v.SetInt(int64(n))
-				}
+				return d.setReflected(v, n)
 			}
 			return d.err
 		case Int32Tag:
-			n := d.ParseInt()
+			n := int32(d.ParseInt())
 			if d.err == nil {
-				if v.Kind() == reflect.Interface {
-					v.Set(reflect.New(reflect.TypeOf(n)))
-				} else {
-					v.SetInt(int64(n))
-				}
+				return d.setReflected(v, n)
 			}
 			return d.err
 		case Int64Tag:
 			n := d.ParseInt64()
 			if d.err == nil {
-				if v.Kind() == reflect.Interface {
-					v.Set(reflect.New(reflect.TypeOf(n)))
-				} else {
-					v.SetInt(n)
-				}
+				return d.setReflected(v, n)
+			}
+			return d.err
+		case Uint8Tag, Uint16Tag, Uint32Tag, Uint64Tag:
+			n, _ := d.decodeUnsigned(tag)
+			if d.err == nil {
+				return d.setReflected(v, n)
 			}
 			return d.err
 		case Float32Tag:
 			n, err := d.ParseFloat32()
 			if err == nil {
-				if v.Kind() == reflect.Interface {
-					v.Set(reflect.New(reflect.TypeOf(n)))
-				} else {
-					v.SetFloat(float64(n))
-				}
+				return d.setReflected(v, n)
 			}
 			return err
 		case Float64Tag:
 			n, err := d.ParseFloat64()
 			if err == nil {
-				if v.Kind() == reflect.Interface {
-					v.Set(reflect.New(reflect.TypeOf(n)))
-				} else {
-					v.SetFloat(n)
-				}
+				return d.setReflected(v, n)
 			}
 			return err
 		case BytesTag:
 			b, err := d.ParseBytes()
 			if err == nil {
-				if v.Kind() == reflect.Interface {
-					v.Set(reflect.New(reflect.TypeOf(b)))
-				} else {
-					v.SetBytes(b)
-				}
+				return d.setReflected(v, b)
 			}
 			return err
 		case StringTag:
 			s, err := d.ParseString()
 			if err == nil {
-				if v.Kind() == reflect.Interface {
-					v.Set(reflect.New(reflect.TypeOf(s)))
-				} else {
-					v.SetString(s)
-				}
+				return d.setReflected(v, s)
 			}
 			return err
 		case SymbolTag:
 			s, err := d.ParseSymbol()
 			if err == nil {
-				v.Set(reflect.ValueOf(rdl.Symbol(s)))
+				return d.setReflected(v, rdl.Symbol(s))
 			}
 			return err
 		case TimestampTag:
 			ts, err := d.ParseTimestamp()
 			if err == nil {
-				v.Set(reflect.ValueOf(ts))
+				return d.setReflected(v, ts)
 			}
 			return err
 		case UUIDTag:
 			u, err := d.ParseUUID()
 			if err == nil {
-				v.Set(reflect.ValueOf(u))
+				return d.setReflected(v, u)
 			}
 			return err
 		case StructTag:
@@ -823,7 +1299,8 @@ again:
 			return d.DecodeMapReflect(v)
 		}
 	}
-	return fmt.Errorf("Unexpected tag value: 0x%02x", tag)
+	d.err = fmt.Errorf("Unexpected tag value: 0x%02x", tag)
+	return d.err
 }
 
 func (d *Decoder) reflectFieldByIndex(v reflect.Value, idx int) reflect.Value {
@@ -873,25 +1350,48 @@ func (d *Decoder) reflectField(v reflect.Value, fname string) (reflect.Value, er
 }
 
 func (d *Decoder) decodeTypeReflect(tt *Signature, v reflect.Value) error {
+	defer d.leave()
+	if d.enter() != nil {
+		return d.err
+	}
+	if tt == nil {
+		d.err = fmt.Errorf("decode of a missing type")
+		return d.err
+	}
+	if v.Kind() == reflect.Ptr && tt.Tag != AnyTag && tt.Tag != NullTag {
+		if v.IsNil() {
+			if !v.CanSet() {
+				d.err = fmt.Errorf("Cannot set pointer")
+				return d.err
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if isEmptyInterface(v) && tt.Tag != AnyTag {
+		x, err := d.decodeType(tt)
+		if err != nil {
+			return err
+		}
+		return d.setReflected(v, x)
+	}
 	switch tt.Tag {
 	case StructTag:
-		if !v.CanSet() {
-			d.err = fmt.Errorf("Cannot set struct")
-			return d.err
+		if tt.Fields == nil {
+			return d.DecodeStructReflect(v)
 		}
-		t := v.Type()
-		vv := v
-		if v.Kind() == reflect.Ptr {
-			v = v.Elem()
-			t := t.Elem()
-			if !v.IsValid() {
-				v = reflect.New(t)
-			}
+		if v.Kind() != reflect.Struct {
+			d.err = fmt.Errorf("Cannot decode a struct into %v", v.Type())
+			return d.err
 		}
 		for _, f := range tt.Fields {
 			fn := f.Name
 			field, err := d.reflectField(v, fn)
 			if err != nil {
+				//not in the target, but the value must still be consumed
+				if _, err = d.decodeType(f.Type); err != nil {
+					return err
+				}
 				continue
 			}
 			if !field.CanSet() {
@@ -903,11 +1403,14 @@ func (d *Decoder) decodeTypeReflect(tt *Signature, v reflect.Value) error {
 				return err
 			}
 		}
-		vv.Set(v)
-		return nil
+		return d.err
 	case MapTag:
-		mlen := int(d.ParseUnsigned())
-		if d.err != nil {
+		mlen := d.parseSize()
+		if d.checkItems(mlen, tt.Keys, tt.Items) != nil {
+			return d.err
+		}
+		if v.Kind() != reflect.Map {
+			d.err = fmt.Errorf("Cannot decode a map into %v", v.Type())
 			return d.err
 		}
 		keys := tt.Keys
@@ -916,20 +1419,17 @@ func (d *Decoder) decodeTypeReflect(tt *Signature, v reflect.Value) error {
 		itemType := t.Elem()
 		keyType := t.Key()
 		if !v.CanSet() {
-			d.err = fmt.Errorf("Cannot set array element")
+			d.err = fmt.Errorf("Cannot set map")
 			return d.err
 		}
-		v.Set(reflect.MakeMap(reflect.MapOf(keyType, itemType)))
+		v.Set(reflect.MakeMap(t))
 		for i := 0; i < mlen; i++ {
-			keyV := reflect.New(keyType).Elem()    //we don't want a pointer
-			err := d.decodeTypeReflect(keys, keyV) //? need a reflect.Value, not a reflect.Type
+			keyV := reflect.New(keyType).Elem() //we don't want a pointer
+			err := d.decodeTypeReflect(keys, keyV)
 			if err != nil {
 				return err
 			}
-			itemV := reflect.New(itemType)
-			if itemV.Kind() != reflect.Struct {
-				itemV = itemV.Elem()
-			}
+			itemV := reflect.New(itemType).Elem()
 			err = d.decodeTypeReflect(items, itemV)
 			if err != nil {
 				return err
@@ -938,42 +1438,41 @@ func (d *Decoder) decodeTypeReflect(tt *Signature, v reflect.Value) error {
 		}
 		return nil
 	case ArrayTag:
-		alen := int(d.ParseUnsigned())
-		if d.err != nil {
+		alen := d.parseSize()
+		if d.checkItems(alen, tt.Items) != nil {
+			return d.err
+		}
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			d.err = fmt.Errorf("Cannot decode an array into %v", v.Type())
+			return d.err
+		}
+		if !v.CanSet() {
+			d.err = fmt.Errorf("Cannot set array element")
 			return d.err
 		}
 		items := tt.Items
-		switch items.Tag {
-		default:
-			t := v.Type()
-			itemType := t.Elem()
-			if !v.CanSet() {
-				d.err = fmt.Errorf("Cannot set array element")
+		if v.Kind() == reflect.Array {
+			if d.checkArrayLength(alen, v) != nil {
 				return d.err
 			}
-			v.Set(reflect.MakeSlice(reflect.SliceOf(itemType), alen, alen))
 			for i := 0; i < alen; i++ {
-				item := v.Index(i)
-				itemType := item.Type()
-				if item.Kind() == reflect.Ptr {
-					item = item.Elem()
-					itemType := itemType.Elem()
-					if !item.IsValid() {
-						item = reflect.New(itemType)
-					}
-				}
-				if itemType.Kind() == reflect.Ptr {
-					d.decodeTypeReflect(items, item.Elem()) //BUG: this bypasses the UnmarshalTBin method of the object!
-				} else {
-					d.decodeTypeReflect(items, item)
-				}
-				v.Index(i).Set(item)
-				if d.err != nil {
+				if d.decodeTypeReflect(items, v.Index(i)) != nil {
 					return d.err
 				}
 			}
 			return nil
 		}
+		itemType := v.Type().Elem()
+		result := reflect.MakeSlice(v.Type(), 0, preallocSize(alen))
+		for i := 0; i < alen; i++ {
+			item := reflect.New(itemType).Elem()
+			if d.decodeTypeReflect(items, item) != nil { //BUG: this bypasses the UnmarshalTBin method of the object!
+				return d.err
+			}
+			result = reflect.Append(result, item)
+		}
+		v.Set(result)
+		return nil
 	case BoolTag:
 		n := d.ParseUnsigned()
 		if d.err == nil {
@@ -987,13 +1486,19 @@ func (d *Decoder) decodeTypeReflect(tt *Signature, v reflect.Value) error {
 	case Int32Tag, Int8Tag, Int16Tag:
 		n := d.ParseInt()
 		if d.err == nil {
-			v.SetInt(int64(n))
+			return d.setInt(v, int64(n))
 		}
 		return d.err
 	case Int64Tag:
 		n := d.ParseInt64()
 		if d.err == nil {
-			v.SetInt(n)
+			return d.setInt(v, n)
+		}
+		return d.err
+	case Uint8Tag, Uint16Tag, Uint32Tag, Uint64Tag:
+		n := d.parseUnsigned(tt.Tag)
+		if d.err == nil {
+			return d.setUint(v, n)
 		}
 		return d.err
 	case Float32Tag:
@@ -1014,6 +1519,20 @@ func (d *Decoder) decodeTypeReflect(tt *Signature, v reflect.Value) error {
 			v.SetString(s)
 		}
 		return err
+	case SymbolTag:
+		s, err := d.ParseSymbol()
+		if err == nil {
+			return d.setReflected(v, rdl.Symbol(s))
+		}
+		return err
+	case BytesTag:
+		b, err := d.ParseBytes()
+		if err == nil {
+			return d.setReflected(v, b)
+		}
+		return err
+	case NullTag:
+		return d.err
 	case UUIDTag:
 		u, err := d.ParseUUID()
 		if err == nil {
@@ -1034,7 +1553,14 @@ func (d *Decoder) decodeTypeReflect(tt *Signature, v reflect.Value) error {
 		return d.err
 	case UnionTag:
 		n := int(d.ParseUnsigned())
-		if n > len(tt.Variants) || len(tt.Variants) != v.NumField()-1 {
+		if d.err != nil {
+			return d.err
+		}
+		if v.Kind() != reflect.Struct {
+			d.err = fmt.Errorf("Cannot decode a union into %v", v.Type())
+			return d.err
+		}
+		if n < 1 || n > len(tt.Variants) || len(tt.Variants) != v.NumField()-1 {
 			d.err = fmt.Errorf("Variant id out of range for target union type: %v -- %v == %v", v, tt, v.NumField())
 			return d.err
 		}
@@ -1059,29 +1585,64 @@ func (d *Decoder) decodeTypeReflect(tt *Signature, v reflect.Value) error {
 		peekTag := d.ParseUnsigned()
 		if d.err == nil && peekTag != NullTag {
 			d.pendingTag = int(peekTag)
+			if isEmptyInterface(v) {
+				x, err := d.decode()
+				if err != nil {
+					return err
+				}
+				return d.setReflected(v, x)
+			}
 			if v.Kind() == reflect.Ptr {
 				vv := reflect.New(v.Type().Elem())
 				v.Set(vv)
 				v = v.Elem()
 			}
-			return d.DecodeReflect(v)
+			return d.decodeReflect(v)
 		}
 		return d.err
 	}
-	d.err = fmt.Errorf("decode unhandled type (0x%02x): %v", tt.Tag, tt)
+	d.err = fmt.Errorf("decode unhandled type (0x%02x)", tt.Tag)
 	return d.err
 }
 
 func (d *Decoder) DecodeStructReflect(v reflect.Value) error {
-	count := int(d.ParseUnsigned())
-	t := v.Type()
 	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
 		v = v.Elem()
-		t := t.Elem()
-		if !v.IsValid() {
-			v = reflect.New(t)
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Map:
+		//a naked struct: rdl.Struct, or a map keyed by field name
+		st, err := d.DecodeStruct()
+		if err != nil {
+			return err
+		}
+		if v.Kind() == reflect.Interface {
+			return d.setReflected(v, st)
 		}
+		t := v.Type()
+		m := reflect.MakeMap(t)
+		for name, val := range st {
+			key := reflect.New(t.Key()).Elem()
+			if d.setReflected(key, name) != nil {
+				return d.err
+			}
+			item := reflect.New(t.Elem()).Elem()
+			if d.setReflected(item, val) != nil {
+				return d.err
+			}
+			m.SetMapIndex(key, item)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+	default:
+		d.err = fmt.Errorf("Cannot decode a struct into %v", v.Type())
+		return d.err
 	}
+	count := d.parseSize()
 	for i := 0; i < count; i++ {
 		fname, _ := d.ParseSymbol()
 		if d.err != nil {
@@ -1095,80 +1656,108 @@ func (d *Decoder) DecodeStructReflect(v reflect.Value) error {
 				d.err = fmt.Errorf("Cannot set struct field")
 				return d.err
 			}
-			d.DecodeReflect(field)
+			d.decodeReflect(field)
 		}
 	}
 	return d.err
 }
 
 func (d *Decoder) DecodeArrayReflect(v reflect.Value) error {
-	count := int(d.ParseUnsigned())
-	t := v.Type()
-	itemType := t.Elem()
-	if !v.CanSet() {
-		d.err = fmt.Errorf("Cannot set array element")
+	count := d.parseSize()
+	if d.err != nil {
 		return d.err
 	}
-	v.Set(reflect.MakeSlice(reflect.SliceOf(itemType), count, count))
-	for i := 0; i < count; i++ {
-		item := v.Index(i)
-		itemType := item.Type()
-		if item.Kind() == reflect.Ptr {
-			item = item.Elem()
-			itemType := itemType.Elem()
-			if !item.IsValid() {
-				item = reflect.New(itemType)
+	if v.Kind() == reflect.Interface {
+		//no static type, so the items are decoded generically
+		var result []interface{}
+		for i := 0; i < count; i++ {
+			item, err := d.decode()
+			if err != nil {
+				return err
 			}
+			result = append(result, item)
 		}
+		return d.setReflected(v, result)
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array || !v.CanSet() {
+		d.err = fmt.Errorf("Cannot decode an array into %v", v.Type())
+		return d.err
+	}
+	itemType := v.Type().Elem()
+	if v.Kind() == reflect.Array {
+		if d.checkArrayLength(count, v) != nil {
+			return d.err
+		}
+		for i := 0; i < count; i++ {
+			item := v.Index(i)
+			if itemType.Kind() == reflect.Ptr {
+				item.Set(reflect.New(itemType.Elem()))
+				item = item.Elem()
+			}
+			if d.decodeReflect(item) != nil {
+				return d.err
+			}
+		}
+		return nil
+	}
+	result := reflect.MakeSlice(v.Type(), 0, preallocSize(count))
+	for i := 0; i < count; i++ {
+		item := reflect.New(itemType).Elem()
 		if itemType.Kind() == reflect.Ptr {
-			d.DecodeReflect(item.Elem())
+			item.Set(reflect.New(itemType.Elem()))
+			d.decodeReflect(item.Elem())
 		} else {
-			d.DecodeReflect(item)
+			d.decodeReflect(item)
 		}
-		v.Index(i).Set(item)
 		if d.err != nil {
 			return d.err
 		}
+		result = reflect.Append(result, item)
 	}
+	v.Set(result)
 	return d.err
 }
 
 func (d *Decoder) DecodeMapReflect(v reflect.Value) error {
-	count := int(d.ParseUnsigned())
+	count := d.parseSize()
+	if d.err != nil {
+		return d.err
+	}
+	if v.Kind() == reflect.Interface {
+		x, err := d.decodeGenericMapEntries(count)
+		if err != nil {
+			return err
+		}
+		return d.setReflected(v, x)
+	}
+	if v.Kind() != reflect.Map || !v.CanSet() {
+		d.err = fmt.Errorf("Cannot decode a map into %v", v.Type())
+		return d.err
+	}
 	t := v.Type()
 	keyType := t.Key()
 	itemType := t.Elem()
-	if !v.CanSet() {
-		d.err = fmt.Errorf("Cannot set mapentry")
-		return d.err
-	}
-	v.Set(reflect.MakeMap(reflect.MapOf(keyType, itemType)))
+	v.Set(reflect.MakeMap(t))
 	for i := 0; i < count; i++ {
-		key := reflect.New(keyType)
-		d.DecodeReflect(key.Elem())
-		key = key.Elem()
+		key := reflect.New(keyType).Elem()
+		d.decodeReflect(key)
 		var item reflect.Value
-		if itemType.Kind() == reflect.Interface && itemType.NumMethod() == 0 {
+		if isEmptyInterface(reflect.New(itemType).Elem()) {
 			tmp, err := d.decode()
 			if err != nil {
 				return err
 			}
-			item = reflect.ValueOf(tmp)
-		} else {
-			//need a test case for this path
-			item = reflect.New(itemType)
-			itemType := item.Type()
-			if item.Kind() == reflect.Ptr {
-				item = item.Elem()
-				itemType := itemType.Elem()
-				if !item.IsValid() {
-					item = reflect.New(itemType)
-				}
+			item = reflect.New(itemType).Elem()
+			if tmp != nil {
+				item.Set(reflect.ValueOf(tmp))
 			}
+		} else {
+			item = reflect.New(itemType).Elem()
 			if itemType.Kind() == reflect.Ptr {
-				d.DecodeReflect(item.Elem())
+				item.Set(reflect.New(itemType.Elem()))
+				d.decodeReflect(item.Elem())
 			} else {
-				d.DecodeReflect(item)
+				d.decodeReflect(item)
 			}
 		}
 		if d.err != nil {
@@ -1178,3 +1767,172 @@ func (d *Decoder) DecodeMapReflect(v reflect.Value) error {
 	}
 	return d.err
 }
+
+// Skip passes over the next value in the stream, which is tagged, as at the top level or where a
+// signature calls for Any. Nothing is allocated, except for any type definitions and symbols the
+// value introduces, which are retained because later values may refer to them.
+func (d *Decoder) Skip() error {
+	return d.SkipType(Any)
+}
+
+// SkipType passes over a value of the specified type, as Skip does.
+func (d *Decoder) SkipType(sig *Signature) (err error) {
+	defer d.recoverError(&err)
+	return d.skipType(sig)
+}
+
+func (d *Decoder) discard(n uint) error {
+	if d.err == nil {
+		_, err := d.in.Discard(int(n))
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			d.err = err
+		}
+	}
+	return d.err
+}
+
+func (d *Decoder) skip() error {
+	defer d.leave()
+	if d.enter() != nil {
+		return d.err
+	}
+again:
+	tag := d.nextTag()
+	if d.err != nil {
+		return d.err
+	}
+	if tag >= FirstUserTag {
+		idx := int(tag - FirstUserTag)
+		if idx < len(d.types) {
+			return d.skipType(d.types[idx])
+		}
+		if d.defineType(tag) == nil {
+			return d.err
+		}
+		goto again
+	}
+	if (tag & TinyStrTagMask) == TinyStrTag {
+		return d.discard(tag & TinyStrDataMask)
+	}
+	switch tag {
+	case NullTag:
+		return nil
+	case BoolTag:
+		return d.discard(1)
+	case StructTag:
+		nfields := d.parseSize()
+		for i := 0; i < nfields && d.err == nil; i++ {
+			d.ParseSymbol()
+			d.skip()
+		}
+		return d.err
+	case ArrayTag:
+		count := d.parseSize()
+		for i := 0; i < count && d.err == nil; i++ {
+			d.skip()
+		}
+		return d.err
+	case MapTag:
+		count := d.parseSize()
+		for i := 0; i < count && d.err == nil; i++ {
+			d.skip()
+			d.skip()
+		}
+		return d.err
+	case Int8Tag, Int16Tag, Int32Tag, Int64Tag, Uint8Tag, Uint16Tag, Uint32Tag, Uint64Tag:
+		return d.skipType(Int64)
+	case Float32Tag:
+		return d.skipType(Float32)
+	case Float64Tag:
+		return d.skipType(Float64)
+	case TimestampTag:
+		return d.skipType(Timestamp)
+	case BytesTag, StringTag:
+		return d.skipType(Bytes)
+	case SymbolTag:
+		return d.skipType(Symbol)
+	case UUIDTag:
+		return d.skipType(UUID)
+	}
+	d.err = fmt.Errorf("Unexpected tag value: 0x%02x", tag)
+	return d.err
+}
+
+func (d *Decoder) skipType(tt *Signature) error {
+	defer d.leave()
+	if d.enter() != nil {
+		return d.err
+	}
+	if tt == nil {
+		d.err = fmt.Errorf("skip of a missing type")
+		return d.err
+	}
+	switch tt.Tag {
+	case StructTag:
+		if tt.Fields == nil {
+			d.pendingTag = StructTag
+			return d.skip()
+		}
+		for _, f := range tt.Fields {
+			if d.skipType(f.Type) != nil {
+				break
+			}
+		}
+	case MapTag:
+		count := d.parseSize()
+		if d.checkItems(count, tt.Keys, tt.Items) != nil {
+			return d.err
+		}
+		for i := 0; i < count && d.err == nil; i++ {
+			d.skipType(tt.Keys)
+			d.skipType(tt.Items)
+		}
+	case ArrayTag:
+		count := d.parseSize()
+		if d.checkItems(count, tt.Items) != nil {
+			return d.err
+		}
+		for i := 0; i < count && d.err == nil; i++ {
+			d.skipType(tt.Items)
+		}
+	case AnyTag:
+		return d.skip()
+	case UnionTag:
+		nvariant := int(d.ParseUnsigned())
+		if d.err != nil {
+			return d.err
+		}
+		if nvariant < 1 || nvariant > len(tt.Variants) {
+			d.err = fmt.Errorf("Union variant out of range: %d", nvariant)
+			return d.err
+		}
+		return d.skipType(tt.Variants[nvariant-1])
+	case EnumTag, BoolTag, Int8Tag, Int16Tag, Int32Tag, Int64Tag, Uint8Tag, Uint16Tag, Uint32Tag, Uint64Tag:
+		d.ParseUnsigned64()
+	case Float32Tag:
+		d.discard(4)
+	case Float64Tag:
+		d.discard(8)
+	case TimestampTag:
+		if d.dataVersion < 3 {
+			d.discard(8)
+		} else {
+			d.ParseUnsigned64()
+			d.ParseUnsigned64()
+		}
+	case UUIDTag:
+		d.discard(16)
+	case BytesTag, StringTag:
+		n := d.ParseUnsigned()
+		d.discard(n)
+	case SymbolTag:
+		d.ParseSymbol()
+	case NullTag:
+	default:
+		d.err = fmt.Errorf("skip unhandled type (0x%02x)", tt.Tag)
+	}
+	return d.err
+}
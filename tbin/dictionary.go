@@ -0,0 +1,177 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+//
+// Go implementation of the tbin encoding format
+//
+
+package tbin
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+)
+
+//
+// Dictionary - a pre-shared set of type signatures and symbols, identified by a version id.
+// An Encoder and a Decoder seeded with the same dictionary start with its user tags and symbols
+// already bound, so that a stream need not define them. This saves the typedef overhead of every
+// new session, which dominates short messages.
+//
+// A stream encoded with a dictionary identifies it after the version header, by its id and a
+// fingerprint of its content, so that decoding with a missing or different dictionary fails.
+//
+type Dictionary struct {
+	ID          string
+	data        []byte
+	types       []*Signature
+	symbols     []string
+	fingerprint uint
+}
+
+//
+// NewDictionary - create a dictionary with the specified id, signatures, and symbols. The signatures
+// must be of types that are bound to user tags: structs, arrays, maps, enums, and unions. The types
+// they refer to are included as well.
+//
+func NewDictionary(id string, signatures []*Signature, symbols []string) (*Dictionary, error) {
+	if id == "" {
+		return nil, fmt.Errorf("A dictionary must have an id")
+	}
+	enc := NewEncoder(nil)
+	enc.WriteString(id)
+	enc.WriteSize(len(signatures))
+	for _, sig := range signatures {
+		if !hasUserTag(sig) {
+			return nil, fmt.Errorf("Dictionary signature is not a user type: %v", sig)
+		}
+		enc.WriteType(sig)
+	}
+	enc.WriteSize(len(symbols))
+	for _, sym := range symbols {
+		enc.WriteSymbol(sym)
+	}
+	if enc.Error() != nil {
+		return nil, enc.Error()
+	}
+	return ParseDictionary(enc.Bytes())
+}
+
+func hasUserTag(sig *Signature) bool {
+	switch sig.Tag {
+	case StructTag:
+		return sig.Fields != nil
+	case ArrayTag:
+		return sig.Items != nil
+	case MapTag:
+		return sig.Keys != nil || sig.Items != nil
+	case EnumTag, UnionTag:
+		return true
+	}
+	return false
+}
+
+//
+// ParseDictionary - parse the serialized form of a dictionary, as returned by its Bytes method.
+//
+func ParseDictionary(data []byte) (*Dictionary, error) {
+	d := NewDecoder(bytes.NewReader(data))
+	id, _ := d.ParseString()
+	n := d.parseSize()
+	for i := 0; i < n && d.err == nil; i++ {
+		d.ReadType()
+	}
+	nsyms := d.parseSize()
+	for i := 0; i < nsyms && d.err == nil; i++ {
+		d.ParseSymbol()
+	}
+	if d.err == nil {
+		if _, err := d.in.ReadByte(); err == nil {
+			d.err = fmt.Errorf("Unexpected data after the dictionary")
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("Cannot parse the dictionary: %v", d.err)
+	}
+	if id == "" {
+		return nil, fmt.Errorf("A dictionary must have an id")
+	}
+	//the signatures are shared by the encoders and decoders seeded with the dictionary, so their
+	//cache keys are computed now, and only read after
+	for _, sig := range d.types {
+		sig.computeKeys()
+	}
+	return &Dictionary{ID: id, data: data, types: d.types, symbols: d.syms, fingerprint: uint(crc32.ChecksumIEEE(data))}, nil
+}
+
+//
+// Bytes - return the serialized form of the dictionary, for sharing.
+//
+func (dict *Dictionary) Bytes() []byte {
+	return dict.data
+}
+
+//
+// Signatures - return the signatures the dictionary binds, in order of their user tags.
+//
+func (dict *Dictionary) Signatures() []*Signature {
+	return dict.types
+}
+
+//
+// Symbols - return the symbols the dictionary defines, in order of their ids.
+//
+func (dict *Dictionary) Symbols() []string {
+	return dict.symbols
+}
+
+// seed binds the dictionary's tags and symbols in the encoder, and identifies it in the stream.
+func (enc *Encoder) seed(dict *Dictionary) error {
+	for i, sig := range dict.types {
+		key := sig.String()
+		if _, ok := enc.tags[key]; !ok {
+			enc.tags[key] = &tagDef{tag: FirstUserTag + i}
+		}
+	}
+	enc.nextTag = FirstUserTag + len(dict.types)
+	for i, sym := range dict.symbols {
+		enc.syms[sym] = i
+	}
+	enc.nextSymId = len(dict.symbols)
+	enc.writeUnsigned(DictionaryTag)
+	enc.WriteString(dict.ID)
+	return enc.WriteUnsigned(int(dict.fingerprint))
+}
+
+// readDictionary checks the dictionary the stream identifies, if any, against the one the decoder
+// was seeded with, then binds its tags and symbols.
+func (d *Decoder) readDictionary() error {
+	id := ""
+	var fingerprint uint
+	if b, err := d.in.Peek(1); err == nil && b[0] == DictionaryTag {
+		d.in.ReadByte()
+		id, _ = d.ParseString()
+		fingerprint = d.ParseUnsigned()
+		if d.err != nil {
+			return d.err
+		}
+	}
+	dict := d.opts.Dictionary
+	switch {
+	case dict == nil:
+		if id != "" {
+			d.err = fmt.Errorf("TBin data requires the dictionary %q", id)
+		}
+	case id == "":
+		d.err = fmt.Errorf("TBin data was not encoded with the dictionary %q", dict.ID)
+	case id != dict.ID:
+		d.err = fmt.Errorf("TBin data was encoded with the dictionary %q, not %q", id, dict.ID)
+	case fingerprint != dict.fingerprint:
+		d.err = fmt.Errorf("TBin data was encoded with a different version of the dictionary %q", id)
+	default:
+		d.types = append(d.types, dict.types...)
+		d.syms = append(d.syms, dict.symbols...)
+	}
+	return d.err
+}
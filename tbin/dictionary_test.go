@@ -0,0 +1,171 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+func marshalWithDictionary(test *testing.T, data interface{}, dict *Dictionary) []byte {
+	enc := NewEncoderWithOptions(nil, &EncoderOptions{Dictionary: dict})
+	if err := enc.Encode(data); err != nil {
+		test.Fatalf("Cannot encode with the dictionary: %v", err)
+	}
+	return enc.Bytes()
+}
+
+func TestDictionary(test *testing.T) {
+	line := polyline()
+	dict, err := NewDictionary("polyline/1", []*Signature{TypeSignature(line)}, nil)
+	if err != nil {
+		test.Fatalf("Cannot create dictionary: %v", err)
+	}
+	if len(dict.Signatures()) != 3 || dict.Signatures()[2].String() != TypeSignature(line).String() {
+		test.Errorf("Unexpected dictionary signatures: %v", dict.Signatures())
+	}
+	plain, _ := Marshal(line)
+	tdata := marshalWithDictionary(test, line, dict)
+	//the 23 bytes of typedefs, after the header, are replaced by the 17 byte dictionary reference
+	ref := append([]byte{DictionaryTag, byte(len(dict.ID))}, dict.ID...)
+	if !bytes.Equal(tdata[1:len(ref)+1], ref) || !bytes.Equal(tdata[len(tdata)-46:], plain[24:]) || len(tdata) != testDataLengthTBinDictionary {
+		test.Errorf("Expected the typedefs to be replaced by the dictionary reference:\n% x\n% x", plain, tdata)
+	}
+	test.Logf("polyline is %d bytes with a dictionary, %d without", len(tdata), len(plain))
+
+	opts := &DecoderOptions{Dictionary: dict}
+	var line2 Polyline
+	if err = UnmarshalWithOptions(tdata, &line2, opts); err != nil {
+		test.Fatalf("Cannot decode with the dictionary: %v", err)
+	}
+	if !Equal(line, &line2) {
+		test.Errorf("Decoded data doesn't match the original: %v", line2)
+	}
+	var generic interface{}
+	if err = UnmarshalWithOptions(tdata, &generic, opts); err != nil {
+		test.Errorf("Cannot decode generically with the dictionary: %v", err)
+	}
+
+	//the savings grow with the size of the types
+	bt := loadBigTest(test)
+	btdict, _ := NewDictionary("bigtest/1", []*Signature{TypeSignature(bt)}, nil)
+	btplain, _ := Marshal(bt)
+	btdata := marshalWithDictionary(test, bt, btdict)
+	if len(btplain)-len(btdata) < 150 {
+		test.Errorf("Expected the dictionary to save the BigTest typedefs, got %d bytes rather than %d", len(btdata), len(btplain))
+	}
+	test.Logf("BigTest is %d bytes with a dictionary, %d without", len(btdata), len(btplain))
+
+	//types not in the dictionary are defined in the stream, after those in it
+	drawing := &Drawing{Shapes: []*Shape{rectShape(rect(1, 2, 3, 4)), lineShape(line)}}
+	tdata = marshalWithDictionary(test, drawing, dict)
+	var drawing2 Drawing
+	if err = UnmarshalWithOptions(tdata, &drawing2, opts); err != nil || !Equal(drawing, &drawing2) {
+		test.Errorf("Cannot decode a type not in the dictionary: %v", err)
+	}
+
+	//the serialized form shares the dictionary
+	dict2, err := ParseDictionary(dict.Bytes())
+	if err != nil {
+		test.Fatalf("Cannot parse the dictionary: %v", err)
+	}
+	if dict2.ID != dict.ID || len(dict2.Signatures()) != len(dict.Signatures()) {
+		test.Errorf("Parsed dictionary differs from the original")
+	}
+	tdata = marshalWithDictionary(test, line, dict2)
+	if err = UnmarshalWithOptions(tdata, &line2, opts); err != nil || !Equal(line, &line2) {
+		test.Errorf("Cannot decode with the original dictionary data encoded with the parsed one: %v", err)
+	}
+}
+
+//a dictionary is shared by the encoders and decoders of concurrent sessions
+func TestDictionaryConcurrent(test *testing.T) {
+	line := polyline()
+	dict, err := NewDictionary("polyline/1", []*Signature{TypeSignature(line)}, nil)
+	if err != nil {
+		test.Fatalf("Cannot create dictionary: %v", err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			enc := NewEncoderWithOptions(nil, &EncoderOptions{Dictionary: dict})
+			err := enc.Encode(line)
+			var line2 Polyline
+			if err == nil {
+				err = UnmarshalWithOptions(enc.Bytes(), &line2, &DecoderOptions{Dictionary: dict})
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		test.Errorf("Cannot encode concurrently with the dictionary: %v", err)
+	}
+}
+
+func TestDictionarySymbols(test *testing.T) {
+	dict, err := NewDictionary("symbols/1", []*Signature{Map(String, Int32)}, []string{"name", "count"})
+	if err != nil {
+		test.Fatalf("Cannot create dictionary: %v", err)
+	}
+	st := rdl.Struct{"name": "foo"}
+	plain, _ := Marshal(st)
+	tdata := marshalWithDictionary(test, st, dict)
+	if bytes.Contains(tdata, []byte("name")) || !bytes.Contains(plain, []byte("name")) {
+		test.Errorf("Expected the dictionary to define the symbol")
+	}
+	var generic interface{}
+	if err = UnmarshalWithOptions(tdata, &generic, &DecoderOptions{Dictionary: dict}); err != nil {
+		test.Fatalf("Cannot decode with the dictionary: %v", err)
+	}
+	if m, ok := generic.(map[string]interface{}); !ok || m["name"] != "foo" {
+		test.Errorf("Unexpected decoded value: %v", generic)
+	}
+}
+
+func TestDictionaryMismatch(test *testing.T) {
+	line := polyline()
+	dict, _ := NewDictionary("polyline/1", []*Signature{TypeSignature(line)}, nil)
+	other, _ := NewDictionary("polyline/2", []*Signature{TypeSignature(line)}, nil)
+	changed, _ := NewDictionary("polyline/1", []*Signature{TypeSignature(line)}, []string{"x"})
+	tdata := marshalWithDictionary(test, line, dict)
+	plain, _ := Marshal(line)
+	mismatches := []struct {
+		data     []byte
+		dict     *Dictionary
+		expected string
+	}{
+		{tdata, nil, `requires the dictionary "polyline/1"`},
+		{tdata, other, `encoded with the dictionary "polyline/1", not "polyline/2"`},
+		{tdata, changed, `different version of the dictionary "polyline/1"`},
+		{plain, dict, `not encoded with the dictionary "polyline/1"`},
+	}
+	for _, m := range mismatches {
+		var line2 Polyline
+		err := UnmarshalWithOptions(m.data, &line2, &DecoderOptions{Dictionary: m.dict})
+		if err == nil || !strings.Contains(err.Error(), m.expected) {
+			test.Errorf("Expected an error containing %q, got %v", m.expected, err)
+		}
+	}
+
+	if _, err := NewDictionary("", []*Signature{TypeSignature(line)}, nil); err == nil {
+		test.Errorf("Expected an error for a dictionary without an id")
+	}
+	if _, err := NewDictionary("ints", []*Signature{Int32}, nil); err == nil {
+		test.Errorf("Expected an error for a dictionary signature that is not a user type")
+	}
+	if _, err := ParseDictionary(tdata); err == nil {
+		test.Errorf("Expected an error parsing data that is not a dictionary")
+	}
+}
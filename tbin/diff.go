@@ -0,0 +1,70 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+//
+// Go implementation of the tbin encoding format
+//
+
+package tbin
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+//
+// DiffStreams - compare two TBin streams record by record, decoding each value generically, and
+// return where they differ, as rdl.Diff does. The path of each difference starts with the index of
+// the record, for example "[2].points[0].x". A record that only one of the streams has is added or
+// removed as a whole.
+//
+func DiffStreams(a io.Reader, b io.Reader) ([]rdl.Difference, error) {
+	da, db := NewDecoder(a), NewDecoder(b)
+	var diffs []rdl.Difference
+	for i := 0; ; i++ {
+		va, oka, err := nextRecord(da)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot decode record %d of the first stream: %v", i, err)
+		}
+		vb, okb, err := nextRecord(db)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot decode record %d of the second stream: %v", i, err)
+		}
+		prefix := fmt.Sprintf("[%d]", i)
+		switch {
+		case !oka && !okb:
+			return diffs, nil
+		case !okb:
+			diffs = append(diffs, rdl.Difference{Path: prefix, Kind: rdl.Removed, A: va})
+		case !oka:
+			diffs = append(diffs, rdl.Difference{Path: prefix, Kind: rdl.Added, B: vb})
+		default:
+			for _, d := range rdl.Diff(va, vb) {
+				if d.Path == "" || strings.HasPrefix(d.Path, "[") {
+					d.Path = prefix + d.Path
+				} else {
+					d.Path = prefix + "." + d.Path
+				}
+				diffs = append(diffs, d)
+			}
+		}
+	}
+}
+
+//nextRecord decodes the next value of the stream, if there is one
+func nextRecord(d *Decoder) (interface{}, bool, error) {
+	if d.err != nil {
+		return nil, false, d.err
+	}
+	if _, err := d.in.Peek(1); err == io.EOF {
+		return nil, false, nil
+	}
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
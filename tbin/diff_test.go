@@ -0,0 +1,76 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+func encodeRecords(test *testing.T, values ...interface{}) []byte {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			test.Fatalf("Cannot encode %v: %v", v, err)
+		}
+	}
+	enc.Flush()
+	return buf.Bytes()
+}
+
+func TestDiffStreams(test *testing.T) {
+	line := polyline()
+	drawing := &Drawing{Shapes: []*Shape{rectShape(rect(1, 2, 3, 4)), lineShape(polyline())}}
+	a := encodeRecords(test, line, drawing, loadBigTest(test))
+	diffs, err := DiffStreams(bytes.NewReader(a), bytes.NewReader(a))
+	if err != nil || len(diffs) != 0 {
+		test.Errorf("Expected no differences between identical streams, got %v (%v)", diffs, err)
+	}
+
+	line2 := polyline()
+	line2.Points[3].Y = 101
+	drawing2 := &Drawing{Shapes: []*Shape{rectShape(rect(1, 2, 3, 5)), lineShape(polyline())}}
+	b := encodeRecords(test, line2, drawing2)
+	diffs, err = DiffStreams(bytes.NewReader(a), bytes.NewReader(b))
+	if err != nil {
+		test.Fatalf("Cannot diff streams: %v", err)
+	}
+	expected := []string{"[0].points[3].y", "[1].shapes[0].p2.y", "[2]"}
+	if len(diffs) != len(expected) {
+		test.Fatalf("Expected %d differences, got %v", len(expected), diffs)
+	}
+	for i, d := range diffs {
+		if d.Path != expected[i] {
+			test.Errorf("Expected a difference at %s, got %v", expected[i], d)
+		}
+	}
+	if diffs[0].Kind != rdl.Changed || diffs[0].A != int32(100) || diffs[0].B != int32(101) || diffs[2].Kind != rdl.Removed {
+		test.Errorf("Unexpected differences: %v", diffs)
+	}
+
+	//map keys with dots are quoted in the paths
+	a = encodeRecords(test, map[string]int32{"a.b": 1}, map[string]int32{"a": 1})
+	b = encodeRecords(test, map[string]int32{"a.b": 2}, map[string]int32{"a": 2})
+	diffs, _ = DiffStreams(bytes.NewReader(a), bytes.NewReader(b))
+	if len(diffs) != 2 || diffs[0].Path != `[0]["a.b"]` || diffs[1].Path != "[1].a" {
+		test.Errorf("Expected the map keys to be distinguished in the paths, got %v", diffs)
+	}
+
+	//a decoded record compares with the model it was encoded from
+	var generic interface{}
+	tdata, _ := Marshal(drawing)
+	Unmarshal(tdata, &generic)
+	if d := rdl.Diff(drawing, generic); len(d) != 0 {
+		test.Errorf("Expected a decoded record to match its model, got %v", d)
+	}
+
+	a = encodeRecords(test, line)
+	b = encodeRecords(test, line2, drawing2)
+	if _, err = DiffStreams(bytes.NewReader(a), bytes.NewReader(b[:len(b)-3])); err == nil {
+		test.Errorf("Expected an error diffing a truncated stream")
+	}
+}
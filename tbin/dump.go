@@ -0,0 +1,279 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+//
+// Go implementation of the tbin encoding format
+//
+
+package tbin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+// dumpHexWidth is the number of bytes shown on each line of a hex listing.
+const dumpHexWidth = 8
+
+//
+// Dump - write an annotated listing of the TBin data to out, for inspecting a stream. Each line
+// shows the offset of an element, and describes the version header, a typedef with its
+// user tag and signature, the definition of a symbol, or a value. If hex is true, the bytes of each
+// element are shown beside it. If the data cannot be decoded, the listing ends with a line that
+// flags the offset of the failure, and the error is returned.
+//
+func Dump(out io.Writer, data []byte, hex bool) (err error) {
+	src := bytes.NewReader(data)
+	dp := &dumper{data: data, src: src, out: out, hex: hex}
+	dp.d = NewDecoder(src)
+	defer dp.d.recoverError(&err)
+	if dp.d.err != nil {
+		return dp.fail(0, 0)
+	}
+	dp.line(0, 0, "version %d", dp.d.dataVersion)
+	for dp.d.err == nil && dp.werr == nil {
+		if _, err := dp.d.in.Peek(1); err == io.EOF {
+			break
+		}
+		dp.dumpTagged(dp.offset(), 0, "")
+	}
+	if dp.werr != nil {
+		return dp.werr
+	}
+	return dp.d.err
+}
+
+type dumper struct {
+	d    *Decoder
+	data []byte
+	src  *bytes.Reader
+	out  io.Writer
+	hex  bool
+	werr error
+}
+
+// offset returns the position in the data of the next byte the decoder will read.
+func (dp *dumper) offset() int {
+	return len(dp.data) - dp.src.Len() - dp.d.in.Buffered()
+}
+
+// line writes the description of the element that started at the given offset, and ends here.
+func (dp *dumper) line(start int, depth int, format string, args ...interface{}) {
+	if dp.werr != nil {
+		return
+	}
+	text := strings.TrimRight(strings.Repeat("  ", depth)+fmt.Sprintf(format, args...), " ")
+	if !dp.hex {
+		_, dp.werr = fmt.Fprintf(dp.out, "%08x  %s\n", start, text)
+		return
+	}
+	b := dp.data[start:dp.offset()]
+	for first := true; first || len(b) > 0; first = false {
+		n := len(b)
+		if n > dumpHexWidth {
+			n = dumpHexWidth
+		}
+		if first {
+			_, dp.werr = fmt.Fprintf(dp.out, "%08x  %-*s  %s\n", start, dumpHexWidth*3-1, fmt.Sprintf("% x", b[:n]), text)
+		} else {
+			_, dp.werr = fmt.Fprintf(dp.out, "%08x  % x\n", start, b[:n])
+		}
+		start += n
+		b = b[n:]
+	}
+}
+
+// fail flags the element that could not be decoded, and notes how much of the data remains.
+func (dp *dumper) fail(start int, depth int) error {
+	dp.line(start, depth, "*** decoding failed at offset 0x%08x: %v", dp.offset(), dp.d.err)
+	if rest := len(dp.data) - dp.offset(); rest > 0 {
+		dp.line(dp.offset(), depth, "*** %d bytes not decoded", rest)
+	}
+	return dp.d.err
+}
+
+// symbol reads a symbol, listing its definition if this is its first occurrence. The returned
+// offset is where the rest of the element starts.
+func (dp *dumper) symbol(start int, depth int) (string, int) {
+	nsyms := len(dp.d.syms)
+	name, _ := dp.d.ParseSymbol()
+	if len(dp.d.syms) > nsyms {
+		dp.line(start, depth, "symbol #%d %q", nsyms, name)
+		start = dp.offset()
+	}
+	return name, start
+}
+
+func (dp *dumper) dumpTagged(start int, depth int, label string) error {
+	d := dp.d
+	defer d.leave()
+	if d.enter() != nil {
+		return dp.fail(start, depth)
+	}
+again:
+	tag := d.nextTag()
+	if d.err != nil {
+		return dp.fail(start, depth)
+	}
+	if tag >= FirstUserTag {
+		idx := int(tag - FirstUserTag)
+		if idx < len(d.types) {
+			return dp.dumpType(start, depth, fmt.Sprintf("%s0x%02x ", label, tag), d.types[idx])
+		}
+		sig := d.defineType(tag)
+		if sig == nil {
+			return dp.fail(start, depth)
+		}
+		dp.line(start, depth, "typedef 0x%02x %s", tag, sig)
+		start = dp.offset()
+		goto again
+	}
+	switch tag {
+	case StructTag:
+		nfields := d.parseSize()
+		if d.err != nil {
+			return dp.fail(start, depth)
+		}
+		dp.line(start, depth, "%sStruct, %d fields", label, nfields)
+		for i := 0; i < nfields && d.err == nil; i++ {
+			name, fstart := dp.symbol(dp.offset(), depth+1)
+			if d.err != nil {
+				return dp.fail(fstart, depth+1)
+			}
+			dp.dumpTagged(fstart, depth+1, name+": ")
+		}
+	case ArrayTag:
+		return dp.dumpArray(start, depth, label, Any)
+	case MapTag:
+		return dp.dumpMap(start, depth, label, Any, Any)
+	case SymbolTag:
+		name, vstart := dp.symbol(start, depth)
+		if d.err != nil {
+			return dp.fail(vstart, depth)
+		}
+		dp.line(vstart, depth, "%sSymbol %s", label, name)
+	default:
+		d.pendingTag = int(tag)
+		v, err := d.decode()
+		if err != nil {
+			return dp.fail(start, depth)
+		}
+		dp.line(start, depth, "%s%s %s", label, TagName(int(tag)), formatDumpValue(v))
+	}
+	return d.err
+}
+
+func (dp *dumper) dumpType(start int, depth int, label string, tt *Signature) error {
+	d := dp.d
+	defer d.leave()
+	if d.enter() != nil {
+		return dp.fail(start, depth)
+	}
+	switch tt.Tag {
+	case StructTag:
+		if tt.Fields == nil {
+			d.pendingTag = StructTag
+			return dp.dumpTagged(start, depth, label)
+		}
+		dp.line(start, depth, "%sStruct", label)
+		for _, f := range tt.Fields {
+			if dp.dumpType(dp.offset(), depth+1, f.Name+": ", f.Type) != nil {
+				break
+			}
+		}
+	case ArrayTag:
+		return dp.dumpArray(start, depth, label, tt.Items)
+	case MapTag:
+		return dp.dumpMap(start, depth, label, tt.Keys, tt.Items)
+	case AnyTag:
+		return dp.dumpTagged(start, depth, label)
+	case UnionTag:
+		nvariant := int(d.ParseUnsigned())
+		if d.err == nil && (nvariant < 1 || nvariant > len(tt.Variants)) {
+			d.err = fmt.Errorf("Union variant out of range: %d", nvariant)
+		}
+		if d.err != nil {
+			return dp.fail(start, depth)
+		}
+		return dp.dumpType(start, depth, fmt.Sprintf("%svariant %d ", label, nvariant), tt.Variants[nvariant-1])
+	case SymbolTag:
+		name, vstart := dp.symbol(start, depth)
+		if d.err != nil {
+			return dp.fail(vstart, depth)
+		}
+		dp.line(vstart, depth, "%sSymbol %s", label, name)
+	default:
+		v, err := d.decodeType(tt)
+		if err != nil {
+			return dp.fail(start, depth)
+		}
+		if tt.Tag == EnumTag {
+			dp.line(start, depth, "%sEnum %s", label, v)
+		} else {
+			dp.line(start, depth, "%s%s %s", label, TagName(tt.Tag), formatDumpValue(v))
+		}
+	}
+	return d.err
+}
+
+func (dp *dumper) dumpArray(start int, depth int, label string, items *Signature) error {
+	d := dp.d
+	count := d.parseSize()
+	if d.checkItems(count, items) != nil {
+		return dp.fail(start, depth)
+	}
+	dp.line(start, depth, "%sArray, %d items", label, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		dp.dumpType(dp.offset(), depth+1, fmt.Sprintf("[%d] ", i), items)
+	}
+	return d.err
+}
+
+// dumpMap lists a map. A scalar key labels its value, otherwise the key and the value each get a line.
+func (dp *dumper) dumpMap(start int, depth int, label string, keys *Signature, items *Signature) error {
+	d := dp.d
+	count := d.parseSize()
+	if d.checkItems(count, keys, items) != nil {
+		return dp.fail(start, depth)
+	}
+	dp.line(start, depth, "%sMap, %d entries", label, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		kstart := dp.offset()
+		switch keys.Tag {
+		case StructTag, ArrayTag, MapTag, UnionTag, AnyTag:
+			if dp.dumpType(kstart, depth+1, "key: ", keys) == nil {
+				dp.dumpType(dp.offset(), depth+1, "value: ", items)
+			}
+		default:
+			key, err := d.decodeType(keys)
+			if err != nil {
+				return dp.fail(kstart, depth+1)
+			}
+			dp.dumpType(kstart, depth+1, formatDumpValue(key)+": ", items)
+		}
+	}
+	return d.err
+}
+
+func formatDumpValue(v interface{}) string {
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return fmt.Sprintf("%q", tv)
+	case []byte:
+		if len(tv) > 32 {
+			return fmt.Sprintf("[%d bytes] %x...", len(tv), tv[:32])
+		}
+		return fmt.Sprintf("[%d bytes] %x", len(tv), tv)
+	case rdl.UUID:
+		return tv.String()
+	case rdl.Timestamp:
+		return tv.String()
+	}
+	return fmt.Sprint(v)
+}
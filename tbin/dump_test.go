@@ -0,0 +1,74 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+func checkDump(test *testing.T, tdata []byte, hex bool, expected ...string) string {
+	var out bytes.Buffer
+	if err := Dump(&out, tdata, hex); err != nil {
+		test.Errorf("Cannot dump: %v", err)
+	}
+	listing := out.String()
+	for _, s := range expected {
+		if !strings.Contains(listing, s) {
+			test.Errorf("Dump does not contain %q:\n%s", s, listing)
+		}
+	}
+	return listing
+}
+
+func TestDump(test *testing.T) {
+	tdata, _ := Marshal(polyline())
+	checkDump(test, tdata, false,
+		"00000000  version 1\n",
+		"00000001  typedef 0x40 Struct{x:Int32,y:Int32}\n",
+		"0000000d  typedef 0x42 Struct{points:Array<Struct{x:Int32,y:Int32}>}\n",
+		"00000018  0x42 Struct\n",
+		"00000019    points: Array, 13 items\n",
+		"0000001a      [0] Struct\n",
+		"0000001b        y: Int32 11\n")
+	checkDump(test, tdata, true,
+		"00000000  18                       version 1\n",
+		"00000001  40 13 02 01 78 04 01 79  typedef 0x40 Struct{x:Int32,y:Int32}\n00000009  04\n",
+		"0000001b  16                             y: Int32 11\n")
+
+	drawing := &Drawing{Shapes: []*Shape{rectShape(rect(1, 2, 3, 4))}}
+	tdata, _ = Marshal(drawing)
+	checkDump(test, tdata, false, "[0] variant 2 Struct\n", "p1: Struct\n", "x: Int32 1\n")
+
+	tdata, _ = Marshal(rdl.Struct{"name": rdl.Symbol("sym")})
+	checkDump(test, tdata, false, "Struct, 1 fields\n", "symbol #0 \"name\"\n", "symbol #1 \"sym\"\n", "name: Symbol sym\n")
+
+	tdata, _ = Marshal([]interface{}{"tiny", nil, true, []byte{1, 2}})
+	checkDump(test, tdata, false, "[0] String \"tiny\"\n", "[1] Null\n", "[2] Bool true\n", "[3] Bytes [2 bytes] 0102\n")
+}
+
+func TestDumpFailure(test *testing.T) {
+	tdata, _ := Marshal(polyline())
+	var out bytes.Buffer
+	if err := Dump(&out, tdata[:40], false); err == nil {
+		test.Errorf("Expected an error dumping truncated data")
+	}
+	if !strings.HasSuffix(out.String(), "*** decoding failed at offset 0x00000028: EOF\n") {
+		test.Errorf("Expected the dump to flag the failure:\n%s", out.String())
+	}
+	out.Reset()
+	if err := Dump(&out, []byte{CurVersionTag, Int32Tag, 2, 0x1f, 1, 2}, false); err == nil {
+		test.Errorf("Expected an error dumping malformed data")
+	}
+	if !strings.Contains(out.String(), "00000003  *** decoding failed at offset 0x00000004: Unexpected tag value: 0x1f\n00000004  *** 2 bytes not decoded\n") {
+		test.Errorf("Expected the dump to flag the failure:\n%s", out.String())
+	}
+	out.Reset()
+	if err := Dump(&out, []byte{0x01}, false); err == nil || !strings.Contains(out.String(), "***") {
+		test.Errorf("Expected a flagged error for a bad header:\n%s", out.String())
+	}
+}
@@ -11,8 +11,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"reflect"
+	"sort"
 
 	"github.com/ardielle/ardielle-go/rdl"
 )
@@ -36,6 +38,9 @@ func (enc *Encoder) EncodeReflect(data interface{}) error {
 }
 
 func (enc *Encoder) encode(data interface{}, useMarshallable bool) error {
+	if enc.canonical {
+		useMarshallable = false //a marshaller cannot be relied upon to produce the canonical encoding
+	}
 	if useMarshallable {
 		m, ok := data.(TBinMarshallable)
 		if ok {
@@ -60,10 +65,39 @@ func (enc *Encoder) Flush() error {
 	if enc.err == nil && enc.out != nil {
 		enc.out.Write(enc.buf.Bytes())
 		enc.buf.Reset()
+		enc.versionFixed = true
 	}
 	return enc.err
 }
 
+//
+// Reset - discard any encoded data and error, and direct further output to w, so the encoder can be
+// reused for a new stream. If keepTypes is true, the types and symbols defined so far remain bound and
+// are not defined again, so the new stream continues the session: it can only be decoded by a Decoder
+// that has decoded the previous one, and is reset likewise.
+//
+func (enc *Encoder) Reset(w io.Writer, keepTypes bool) {
+	enc.out = w
+	enc.buf.Reset()
+	enc.err = nil
+	enc.tagged = false
+	if !keepTypes {
+		for k := range enc.tags {
+			delete(enc.tags, k)
+		}
+		for k := range enc.syms {
+			delete(enc.syms, k)
+		}
+		enc.nextTag = FirstUserTag
+		enc.nextSymId = 0
+	}
+	enc.resetVersion()
+	enc.writeHeader()
+	if !keepTypes && enc.dict != nil {
+		enc.seed(enc.dict)
+	}
+}
+
 //
 // Bytes - return the encoded data as a byte array
 //
@@ -92,19 +126,19 @@ func (enc *Encoder) encodeData(data interface{}, useMarshallable bool) error {
 	case int32:
 		return enc.EncodeInt32(d)
 	case int:
-		return enc.EncodeInt32(int32(d))
+		return enc.encodeInt(int64(d))
 	case int64:
 		return enc.EncodeInt64(d)
 	case uint8:
-		return enc.EncodeInt8(int8(d))
+		return enc.EncodeUint8(d)
 	case uint16:
-		return enc.EncodeInt16(int16(d))
+		return enc.EncodeUint16(d)
 	case uint32:
-		return enc.EncodeInt32(int32(d))
+		return enc.EncodeUint32(d)
 	case uint:
-		return enc.EncodeInt32(int32(d))
+		return enc.EncodeUint64(uint64(d))
 	case uint64:
-		return enc.EncodeInt64(int64(d))
+		return enc.EncodeUint64(d)
 	case float32:
 		return enc.EncodeFloat32(d)
 	case float64:
@@ -164,19 +198,19 @@ func (enc *Encoder) encodeData(data interface{}, useMarshallable bool) error {
 		if s1 != s2 {
 			return enc.encodeReflectedEnum(v, useMarshallable)
 		}
-		fallthrough
+		return enc.encodeInt(n)
 	case reflect.Int32:
 		return enc.EncodeInt32(int32(v.Int()))
 	case reflect.Int64:
 		return enc.EncodeInt64(v.Int())
 	case reflect.Uint8:
-		return enc.EncodeInt8(int8(v.Uint()))
+		return enc.EncodeUint8(uint8(v.Uint()))
 	case reflect.Uint16:
-		return enc.EncodeInt16(int16(v.Uint()))
-	case reflect.Uint, reflect.Uint32:
-		return enc.EncodeInt32(int32(v.Uint()))
-	case reflect.Uint64:
-		return enc.EncodeInt64(int64(v.Uint()))
+		return enc.EncodeUint16(uint16(v.Uint()))
+	case reflect.Uint32:
+		return enc.EncodeUint32(uint32(v.Uint()))
+	case reflect.Uint, reflect.Uint64:
+		return enc.EncodeUint64(v.Uint())
 	case reflect.Float32:
 		return enc.EncodeFloat32(float32(v.Float()))
 	case reflect.Float64:
@@ -218,6 +252,46 @@ func (enc *Encoder) EncodeInt64(val int64) error {
 	return enc.WriteInt64(val)
 }
 
+//encodeInt encodes an int as an Int32 if it fits, as it usually does, and otherwise as an Int64
+func (enc *Encoder) encodeInt(n int64) error {
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return enc.EncodeInt64(n)
+	}
+	return enc.EncodeInt32(int32(n))
+}
+
+func (enc *Encoder) EncodeUint8(val uint8) error {
+	if enc.requireVersion(2, "unsigned integers") != nil {
+		return enc.err
+	}
+	enc.writeUnsigned(Uint8Tag)
+	return enc.WriteUint64(uint64(val))
+}
+
+func (enc *Encoder) EncodeUint16(val uint16) error {
+	if enc.requireVersion(2, "unsigned integers") != nil {
+		return enc.err
+	}
+	enc.writeUnsigned(Uint16Tag)
+	return enc.WriteUint64(uint64(val))
+}
+
+func (enc *Encoder) EncodeUint32(val uint32) error {
+	if enc.requireVersion(2, "unsigned integers") != nil {
+		return enc.err
+	}
+	enc.writeUnsigned(Uint32Tag)
+	return enc.WriteUint64(uint64(val))
+}
+
+func (enc *Encoder) EncodeUint64(val uint64) error {
+	if enc.requireVersion(2, "unsigned integers") != nil {
+		return enc.err
+	}
+	enc.writeUnsigned(Uint64Tag)
+	return enc.WriteUint64(val)
+}
+
 func (enc *Encoder) EncodeFloat32(val float32) error {
 	enc.writeUnsigned(Float32Tag)
 	return enc.WriteFloat32(val)
@@ -285,21 +359,21 @@ func (enc *Encoder) EncodeStruct(val rdl.Struct, useMarshallable bool) error {
 	n := len(val)
 	enc.writeUnsigned(StructTag)
 	enc.writeUnsigned(n)
-	for k, v := range val {
-		enc.WriteSymbol(string(k))
-		enc.encode(v, useMarshallable)
+	for _, k := range enc.structKeys(val) {
+		enc.WriteSymbol(k)
+		enc.encode(val[rdl.Symbol(k)], useMarshallable)
 	}
 	return enc.err
 }
 
 func (enc *Encoder) encodeReflectedStruct(v reflect.Value, useMarshallable bool) error {
-	signature := TypeSignature(v.Interface())
+	signature := buildTypeSignature(v.Type())
 	enc.WriteType(signature) //usually just writes the tag, but may write typedefs as a side-effect
 	return enc.encodeValue(v, useMarshallable)
 }
 
 func (enc *Encoder) encodeReflectedEnum(v reflect.Value, useMarshallable bool) error {
-	signature := TypeSignature(v.Interface())
+	signature := buildTypeSignature(v.Type())
 	enc.WriteType(signature) //usually just writes the tag, but may write typedefs as a side-effect
 	return enc.encodeValue(v, useMarshallable)
 }
@@ -315,7 +389,12 @@ func (enc *Encoder) encodeValue(v reflect.Value, useMarshallable bool) error {
 			data := v.Interface()
 			m, ok := data.(TBinMarshallable)
 			if ok {
-				return m.MarshalTBin(enc) //burden on the app, but can be faster
+				//the type is already implied by the enclosing signature, so the WriteType call
+				//the marshaller makes must not emit anything
+				enc.tagged = true
+				err := m.MarshalTBin(enc) //burden on the app, but can be faster
+				enc.tagged = false
+				return err
 			}
 		}
 	}
@@ -329,8 +408,14 @@ func (enc *Encoder) encodeValue(v reflect.Value, useMarshallable bool) error {
 	case "rdl.Symbol":
 		return enc.WriteSymbol(string(v.Interface().(rdl.Symbol)))
 	case "rdl.Struct":
-		//return enc.EncodeStruct(d, useMarshallable)
-		panic("rdl.Struct -> fix me")
+		//a naked struct has no typedef, so each field value carries its own tag
+		st := v.Interface().(rdl.Struct)
+		enc.writeUnsigned(len(st))
+		for _, k := range enc.structKeys(st) {
+			enc.WriteSymbol(k)
+			enc.encode(st[rdl.Symbol(k)], useMarshallable)
+		}
+		return enc.err
 	}
 	var err error
 	switch k {
@@ -344,11 +429,10 @@ func (enc *Encoder) encodeValue(v reflect.Value, useMarshallable bool) error {
 				nvar := int(v.Field(0).Int())
 				enc.WriteUnsigned(nvar)
 				//note: an uninitialized union has its tag set to zero. Emit nothing after the tag in that case.
-				if nvar > 0 {
-					enc.tagged = true //ensure the next WriteType doesn't actually do anything
+				if nvar > 0 && nvar < nfields {
 					return enc.encodeValue(v.Field(nvar), useMarshallable)
 				}
-				enc.err = fmt.Errorf("Cannot marshal uninitialized union type %v in %v", t.Name, v)
+				enc.err = fmt.Errorf("Cannot marshal uninitialized union type %v in %v", t.Name(), v)
 				return enc.err
 			}
 		}
@@ -379,12 +463,21 @@ func (enc *Encoder) encodeValue(v reflect.Value, useMarshallable bool) error {
 	case reflect.Map:
 		n := v.Len()
 		enc.WriteUnsigned(n)
-		for _, k := range v.MapKeys() {
+		for _, k := range enc.mapKeys(v) {
 			enc.encodeValue(k, useMarshallable)
 			enc.encodeValue(v.MapIndex(k), useMarshallable)
 		}
 		err = enc.err
-	case reflect.Slice:
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if k == reflect.Array {
+				//an array that is not addressable has no Bytes, so it is copied
+				b := make([]byte, v.Len())
+				reflect.Copy(reflect.ValueOf(b), v)
+				return enc.WriteBytes(b)
+			}
+			return enc.WriteBytes(v.Bytes())
+		}
 		n := v.Len()
 		enc.WriteUnsigned(n)
 		for i := 0; i < n; i++ {
@@ -396,22 +489,29 @@ func (enc *Encoder) encodeValue(v reflect.Value, useMarshallable bool) error {
 		}
 		err = enc.err
 	case reflect.Ptr:
-		//without context (of a field def, for example), we must assume "optional" for any pointer, because it can be nil
+		//the signature of a pointer is the signature of what it points to, so the value is not tagged
 		if v.IsNil() {
-			err = enc.EncodeNull()
-		} else {
-			err = enc.encode(v.Elem().Interface(), useMarshallable)
+			enc.err = fmt.Errorf("Cannot marshal null pointer for non-optional value of type %v", t)
+			return enc.err
 		}
+		return enc.encodeValue(v.Elem(), useMarshallable)
 	case reflect.Int8:
 		return enc.WriteInt8(int8(v.Int()))
 	case reflect.Int16:
 		return enc.WriteInt16(int16(v.Int()))
 	case reflect.Int:
-		return enc.WriteInt32(int32(v.Int()))
+		n := v.Int()
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			enc.err = fmt.Errorf("Value %d of %v overflows Int32", n, t)
+			return enc.err
+		}
+		return enc.WriteInt32(int32(n))
 	case reflect.Int32:
 		return enc.WriteInt32(int32(v.Int()))
 	case reflect.Int64:
 		return enc.WriteInt64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return enc.WriteUint64(v.Uint())
 	case reflect.Float32:
 		return enc.WriteFloat32(float32(v.Float()))
 	case reflect.Float64:
@@ -501,7 +601,7 @@ func (enc *Encoder) encodeReflectedMap(v reflect.Value, useMarshallable bool) er
 	case reflect.Interface:
 		enc.writeUnsigned(MapTag)
 		enc.writeUnsigned(mlen)
-		for _, k := range v.MapKeys() {
+		for _, k := range enc.mapKeys(v) {
 			enc.encode(k.Interface(), useMarshallable)
 			enc.encode(v.MapIndex(k).Interface(), useMarshallable)
 		}
@@ -512,6 +612,36 @@ func (enc *Encoder) encodeReflectedMap(v reflect.Value, useMarshallable bool) er
 	return enc.encodeValue(v, useMarshallable)
 }
 
+// mapKeys returns the keys of the map, sorted if the encoding is canonical.
+func (enc *Encoder) mapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	if enc.canonical {
+		sort.Slice(keys, func(i, j int) bool {
+			return mapKeyString(keys[i]) < mapKeyString(keys[j])
+		})
+	}
+	return keys
+}
+
+func mapKeyString(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+// structKeys returns the field names of the naked struct, sorted if the encoding is canonical.
+func (enc *Encoder) structKeys(st rdl.Struct) []string {
+	keys := make([]string, 0, len(st))
+	for k := range st {
+		keys = append(keys, string(k))
+	}
+	if enc.canonical {
+		sort.Strings(keys)
+	}
+	return keys
+}
+
 //------------------------- low level encoding
 
 type tagDef struct {
@@ -663,6 +793,10 @@ func (enc *Encoder) compileSignatureOptional(sig *Signature, ref *bytes.Buffer,
 				}
 			}
 			reftag = def.tag
+		case Uint8Tag, Uint16Tag, Uint32Tag, Uint64Tag:
+			if enc.requireVersion(2, "unsigned integers") != nil {
+				return enc.err
+			}
 		default:
 			//reftag is already sig.Tag, which we want
 		}
@@ -697,28 +831,15 @@ func (enc *Encoder) writeByte(b byte) error {
 }
 
 func (enc *Encoder) writeUnsigned(n int) error {
+	//always the shortest encoding, which a canonical encoding depends on
+	u := uint64(n)
 	i := 0
-	if (n & 0xffffff80) != 0 {
-		enc.bytebuf[i] = byte((n | 0x80) & 0xff)
+	for u >= 0x80 {
+		enc.bytebuf[i] = byte(u) | 0x80
 		i++
-		n >>= 7
-		if (n & 0xffffff80) != 0 {
-			enc.bytebuf[i] = byte((n | 0x80) & 0xff)
-			i++
-			n >>= 7
-			if (n & 0xffffff80) != 0 {
-				enc.bytebuf[i] = byte((n | 0x80) & 0xff)
-				i++
-				n >>= 7
-				if (n & 0xffffff80) != 0 {
-					enc.bytebuf[i] = byte((n | 0x80) & 0xff)
-					i++
-					n >>= 7
-				}
-			}
-		}
+		u >>= 7
 	}
-	enc.bytebuf[i] = byte(n)
+	enc.bytebuf[i] = byte(u)
 	i++
 	_, err := enc.buf.Write(enc.bytebuf[:i])
 	if err == nil {
@@ -784,8 +905,33 @@ func (enc *Encoder) WriteInt64(nn int64) error {
 	return enc.err
 }
 
+// WriteUint8 - writes the unsigned 8 bit integer as a uvarint
+func (enc *Encoder) WriteUint8(val uint8) error {
+	return enc.WriteUint64(uint64(val))
+}
+
+// WriteUint16 - writes the unsigned 16 bit integer as a uvarint
+func (enc *Encoder) WriteUint16(val uint16) error {
+	return enc.WriteUint64(uint64(val))
+}
+
+// WriteUint32 - writes the unsigned 32 bit integer as a uvarint
+func (enc *Encoder) WriteUint32(val uint32) error {
+	return enc.WriteUint64(uint64(val))
+}
+
+// WriteUint64 - writes the unsigned 64 bit integer as a uvarint
+func (enc *Encoder) WriteUint64(val uint64) error {
+	n := binary.PutUvarint(enc.bytebuf, val)
+	_, enc.err = enc.buf.Write(enc.bytebuf[:n])
+	return enc.err
+}
+
 // WriteFloat32 - writes the signed 32 bit float as a varint
 func (enc *Encoder) WriteFloat32(n float32) error {
+	if enc.canonical {
+		n = float32(canonicalFloat(float64(n)))
+	}
 	bits := math.Float32bits(n)
 	enc.err = enc.buf.WriteByte(byte(bits >> 24))
 	if enc.err == nil {
@@ -802,6 +948,9 @@ func (enc *Encoder) WriteFloat32(n float32) error {
 
 // WriteFloat64 - writes the signed 64 bit float as a varint
 func (enc *Encoder) WriteFloat64(n float64) error {
+	if enc.canonical {
+		n = canonicalFloat(n)
+	}
 	bits := math.Float64bits(n)
 	enc.err = enc.buf.WriteByte(byte(bits >> 56))
 	if enc.err == nil {
@@ -828,6 +977,17 @@ func (enc *Encoder) WriteFloat64(n float64) error {
 	return enc.err
 }
 
+// canonicalFloat maps the values that compare equal, or are all not a number, to a single representation.
+func canonicalFloat(n float64) float64 {
+	if n == 0 {
+		return 0
+	}
+	if math.IsNaN(n) {
+		return math.NaN()
+	}
+	return n
+}
+
 func (enc *Encoder) WriteUUID(u rdl.UUID) error {
 	if enc.err == nil {
 		_, enc.err = enc.buf.Write([]byte(u))
@@ -835,8 +995,14 @@ func (enc *Encoder) WriteUUID(u rdl.UUID) error {
 	return enc.err
 }
 
+// WriteTimestamp - writes the timestamp exactly, as seconds and nanoseconds since the epoch, unless the
+// encoder is for older decoders, which get seconds as a double, to microsecond precision.
 func (enc *Encoder) WriteTimestamp(val rdl.Timestamp) error {
-	return enc.WriteFloat64(val.SecondsSinceEpoch())
+	if enc.floatTimestamps || !enc.raiseVersion(3) {
+		return enc.WriteFloat64(val.SecondsSinceEpoch())
+	}
+	enc.WriteInt64(val.Unix())
+	return enc.WriteUint64(uint64(val.Nanosecond()))
 }
 
 func (enc *Encoder) WriteBytes(b []byte) error {
@@ -883,9 +1049,48 @@ func (enc *Encoder) WriteSymbol(name string) error {
 	return enc.err
 }
 
+//setVersion decides the version of the header before it is written: the one the options set, or
+//else the oldest, to be raised as the data needs
+func (enc *Encoder) setVersion(opts *EncoderOptions) {
+	if opts != nil && opts.Version != 0 {
+		enc.optVersion = opts.Version
+		if enc.optVersion < OldestVersion || enc.optVersion > CurrentVersion {
+			enc.err = fmt.Errorf("TBin version not supported: %d", enc.optVersion)
+		}
+	}
+	enc.resetVersion()
+}
+
+func (enc *Encoder) resetVersion() {
+	enc.version = enc.optVersion
+	enc.versionFixed = enc.optVersion != 0
+	if !enc.versionFixed {
+		enc.version = OldestVersion
+	}
+}
+
 func (enc *Encoder) writeHeader() error {
-	if enc.err == nil {
-		enc.writeUnsigned(CurVersionTag)
+	return enc.writeUnsigned(VersionTag + (enc.version - 1))
+}
+
+//raiseVersion rewrites the header, which is the first byte of the buffer until it is flushed, with the
+//later version, if the version is not fixed
+func (enc *Encoder) raiseVersion(version int) bool {
+	if enc.version >= version {
+		return true
+	}
+	if enc.versionFixed || enc.buf.Len() == 0 {
+		return false
+	}
+	enc.version = version
+	enc.buf.Bytes()[0] = byte(VersionTag + (version - 1))
+	return true
+}
+
+//requireVersion checks that the version of the stream has what the data needs: 2 for unsigned integers
+func (enc *Encoder) requireVersion(version int, what string) error {
+	if !enc.raiseVersion(version) && enc.err == nil {
+		enc.err = fmt.Errorf("Cannot encode %s in version %d TBin data", what, enc.version)
 	}
 	return enc.err
 }
@@ -0,0 +1,60 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+func TestHTTPNegotiation(test *testing.T) {
+	line := polyline()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", MediaType+", application/json;q=0.9")
+	w := httptest.NewRecorder()
+	rdl.Response(w, r, 200, line)
+	expected, _ := Marshal(line)
+	if w.Header().Get("Content-Type") != MediaType || !bytes.Equal(w.Body.Bytes(), expected) {
+		test.Errorf("Expected a TBin response, got %q: % x", w.Header().Get("Content-Type"), w.Body.Bytes())
+	}
+
+	//request bodies
+	r = httptest.NewRequest("POST", "/", bytes.NewReader(expected))
+	r.Header.Set("Content-Type", MediaType)
+	var line2 Polyline
+	if err := rdl.ReadRequestBody(r, &line2); err != nil || !Equal(line, &line2) {
+		test.Errorf("Cannot read a TBin request body: %v", err)
+	}
+	r = httptest.NewRequest("POST", "/", bytes.NewReader(expected[:20]))
+	r.Header.Set("Content-Type", MediaType)
+	if err, ok := rdl.ReadRequestBody(r, &line2).(*rdl.ResourceError); !ok || err.Code != 400 {
+		test.Errorf("Expected a 400 ResourceError for a truncated body, got %v", err)
+	}
+
+	//untrusted bodies are decoded within the limits
+	nested := []interface{}{"deep"}
+	for i := 0; i < CodecDecoderOptions.MaxDepth; i++ {
+		nested = []interface{}{nested}
+	}
+	tdata, _ := Marshal(nested)
+	r = httptest.NewRequest("POST", "/", bytes.NewReader(tdata))
+	r.Header.Set("Content-Type", MediaType)
+	var generic interface{}
+	if err, ok := rdl.ReadRequestBody(r, &generic).(*rdl.ResourceError); !ok || err.Code != 400 || !strings.Contains(err.Message, "limit") {
+		test.Errorf("Expected a 400 ResourceError for a body nested too deeply, got %v", err)
+	}
+
+	//errors are encoded the same way
+	r.Header.Set("Accept", MediaType)
+	w = httptest.NewRecorder()
+	rdl.Response(w, r, 404, &rdl.ResourceError{Code: 404, Message: "Not Found"})
+	var rerr rdl.ResourceError
+	if err := Unmarshal(w.Body.Bytes(), &rerr); err != nil || rerr.Code != 404 || rerr.Message != "Not Found" {
+		test.Errorf("Cannot decode a TBin ResourceError: %v (%v)", rerr, err)
+	}
+}
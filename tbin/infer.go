@@ -0,0 +1,151 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+//
+// Go implementation of the tbin encoding format
+//
+
+package tbin
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+//
+// InferSchema - derive an RDL schema from the type definitions embedded in the TBin stream. Every
+// struct, array, map, enum, and union signature seen becomes a named type. TBin does not carry type
+// names, so they are made up from the kind of type and the order of appearance (Struct1, Array1,
+// and so on), and are meant to be edited. Optional fields are encoded as tagged values, so their
+// type is not known, and they are declared as optional Any fields. Unsigned integers have no RDL
+// equivalent, and are declared as ranges of a wide enough signed type. The keys of RDL maps are
+// strings, so maps with other keys are declared with String keys, and a comment.
+//
+func InferSchema(r io.Reader) (*rdl.Schema, error) {
+	d := NewDecoder(r)
+	for d.err == nil {
+		if _, err := d.in.Peek(1); err == io.EOF {
+			break
+		}
+		d.Skip()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	inf := &inferrer{
+		sb:     rdl.NewSchemaBuilder("inferred").Comment("Inferred from the type definitions of a TBin stream"),
+		names:  make(map[string]string),
+		counts: make(map[string]int),
+	}
+	for _, sig := range d.types {
+		inf.typeName(sig)
+	}
+	return inf.sb.Build(), nil
+}
+
+type inferrer struct {
+	sb     *rdl.SchemaBuilder
+	names  map[string]string //type names by signature key, so each signature is only defined once
+	counts map[string]int    //number of types defined of each kind, to name the next one
+}
+
+//typeName returns the name of the RDL type for the signature, defining it, and the types it refers to
+//before it, the first time it is seen.
+func (inf *inferrer) typeName(sig *Signature) string {
+	switch sig.Tag {
+	case NullTag, AnyTag:
+		return "Any"
+	case Uint8Tag:
+		return inf.unsignedName(sig, "Int16", int64(0), int64(math.MaxUint8))
+	case Uint16Tag:
+		return inf.unsignedName(sig, "Int32", int64(0), int64(math.MaxUint16))
+	case Uint32Tag:
+		return inf.unsignedName(sig, "Int64", int64(0), int64(math.MaxUint32))
+	case Uint64Tag:
+		return inf.unsignedName(sig, "Int64", int64(0), nil)
+	case StructTag:
+		if sig.Fields == nil {
+			return "Struct" //a naked struct
+		}
+	case ArrayTag, MapTag, EnumTag, UnionTag:
+	default:
+		return TagName(sig.Tag)
+	}
+	key := sig.String()
+	if name, ok := inf.names[key]; ok {
+		return name
+	}
+	name := inf.newName(sig)
+	inf.names[key] = name
+	var t *rdl.Type
+	switch sig.Tag {
+	case StructTag:
+		tb := rdl.NewStructTypeBuilder("Struct", name)
+		for _, f := range sig.Fields {
+			if f.optional {
+				tb.Field(f.Name, "Any", true, nil, "")
+			} else {
+				tb.Field(f.Name, inf.typeName(f.Type), false, nil, "")
+			}
+		}
+		t = tb.Build()
+	case ArrayTag:
+		t = rdl.NewArrayTypeBuilder("Array", name).Items(inf.typeName(sig.Items)).Build()
+	case MapTag:
+		tb := rdl.NewMapTypeBuilder("Map", name)
+		keys := inf.typeName(sig.Keys)
+		if keys != "String" {
+			tb.Comment(fmt.Sprintf("The keys are %s in the TBin data", keys))
+			keys = "String"
+		}
+		t = tb.Keys(keys).Items(inf.typeName(sig.Items)).Build()
+	case EnumTag:
+		tb := rdl.NewEnumTypeBuilder("Enum", name)
+		for _, sym := range sig.Symbols {
+			if sym != "" { //a decoded enum reserves index zero
+				tb.Element(sym, "")
+			}
+		}
+		t = tb.Build()
+	case UnionTag:
+		tb := rdl.NewUnionTypeBuilder("Union", name)
+		for _, v := range sig.Variants {
+			tb.Variant(inf.typeName(v))
+		}
+		t = tb.Build()
+	}
+	inf.sb.AddType(t) //after the types it refers to
+	return name
+}
+
+//unsignedName defines the unsigned type as a range of a signed supertype that holds all of its
+//values, except for Uint64, which is only bounded below. The bounds are Int64 numbers, as the parser
+//makes them for integer types.
+func (inf *inferrer) unsignedName(sig *Signature, supertype string, min interface{}, max interface{}) string {
+	name := TagName(sig.Tag)
+	if _, ok := inf.names[name]; ok {
+		return name
+	}
+	tb := rdl.NewNumberTypeBuilder(supertype, name).Min(min)
+	if max != nil {
+		tb.Max(max)
+	} else {
+		tb.Comment(fmt.Sprintf("Values above %d cannot be represented", int64(math.MaxInt64)))
+	}
+	inf.names[name] = name
+	inf.sb.AddType(tb.Build())
+	return name
+}
+
+//newName makes up a name for a type from its kind, numbered in order of appearance
+func (inf *inferrer) newName(sig *Signature) string {
+	kind := "Union"
+	if sig.Tag != UnionTag {
+		kind = TagName(sig.Tag)
+	}
+	inf.counts[kind]++
+	return fmt.Sprintf("%s%d", kind, inf.counts[kind])
+}
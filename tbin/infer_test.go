@@ -0,0 +1,220 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+// asJSON returns the generic form of the value, as encoding/json produces it, which is what rdl.Validate expects.
+func asJSON(test *testing.T, v interface{}) interface{} {
+	j, err := json.Marshal(v)
+	if err != nil {
+		test.Fatalf("Cannot marshal to JSON: %v", err)
+	}
+	var generic interface{}
+	if err = json.Unmarshal(j, &generic); err != nil {
+		test.Fatalf("Cannot unmarshal JSON: %v", err)
+	}
+	return generic
+}
+
+func inferredType(schema *rdl.Schema, name string) *rdl.Type {
+	for _, t := range schema.Types {
+		if tname, _, _ := rdl.TypeInfo(t); string(tname) == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func TestInferSchema(test *testing.T) {
+	tdata, _ := Marshal(polyline())
+	schema, err := InferSchema(bytes.NewReader(tdata))
+	if err != nil {
+		test.Fatalf("Cannot infer a schema: %v", err)
+	}
+	var names []string
+	for _, t := range schema.Types {
+		tname, _, _ := rdl.TypeInfo(t)
+		names = append(names, string(tname))
+	}
+	if strings.Join(names, ",") != "Struct1,Array1,Struct2" {
+		test.Errorf("Unexpected inferred types: %v", names)
+	}
+	line := inferredType(schema, "Struct2")
+	if line == nil || len(line.StructTypeDef.Fields) != 1 || line.StructTypeDef.Fields[0].Type != "Array1" {
+		test.Errorf("Unexpected inferred struct: %v", line)
+	}
+
+	//the schema is itself valid data for the RDL schema, and survives a round trip through JSON
+	if v := rdl.Validate(rdl.RdlSchema(), "Schema", asJSON(test, schema)); v.Error != "" {
+		test.Errorf("The inferred schema is not a valid Schema: %v", v)
+	}
+	j, _ := json.Marshal(schema)
+	var schema2 rdl.Schema
+	if err = json.Unmarshal(j, &schema2); err != nil || rdl.CompareSchemas(schema, &schema2) != "" {
+		test.Errorf("The inferred schema did not round trip through JSON: %v", err)
+	}
+
+	//and the data is valid for it
+	var generic interface{}
+	Unmarshal(tdata, &generic)
+	if v := rdl.Validate(&schema2, "Struct2", asJSON(test, generic)); v.Error != "" {
+		test.Errorf("The data is not valid for its inferred schema: %v", v)
+	}
+	bad := map[string]interface{}{"points": []interface{}{map[string]interface{}{"x": "one", "y": 2.0}}}
+	if v := rdl.Validate(&schema2, "Struct2", bad); v.Error == "" {
+		test.Errorf("Expected invalid data to fail validation against the inferred schema")
+	}
+}
+
+func TestInferSchemaKinds(test *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	drawing := &Drawing{Shapes: []*Shape{rectShape(rect(1, 2, 3, 4)), lineShape(polyline())}}
+	values := []interface{}{loadBigTest(test), &Counters{Hits: math.MaxUint64, Small: 7}, map[string]int32{"a": 1}, drawing, newBaseTypesCollections()}
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			test.Fatalf("Cannot encode %v: %v", v, err)
+		}
+	}
+	enc.Flush()
+	schema, err := InferSchema(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		test.Fatalf("Cannot infer a schema: %v", err)
+	}
+	kinds := make(map[rdl.TypeVariantTag]int)
+	seen := make(map[rdl.TypeName]bool)
+	for _, t := range schema.Types {
+		kinds[t.Variant]++
+		tname, _, _ := rdl.TypeInfo(t)
+		if seen[tname] {
+			test.Errorf("Type %s is defined more than once", tname)
+		}
+		seen[tname] = true
+	}
+	for _, k := range []rdl.TypeVariantTag{rdl.TypeVariantStructTypeDef, rdl.TypeVariantArrayTypeDef, rdl.TypeVariantMapTypeDef, rdl.TypeVariantEnumTypeDef, rdl.TypeVariantUnionTypeDef, rdl.TypeVariantNumberTypeDef} {
+		if kinds[k] == 0 {
+			test.Errorf("Expected an inferred %v type, got %v", k, kinds)
+		}
+	}
+	if u := inferredType(schema, "Uint8"); u == nil || *u.NumberTypeDef.Max.Int64 != math.MaxUint8 {
+		test.Errorf("Expected Uint8 to be inferred as a range of Int16: %v", u)
+	}
+	if v := rdl.Validate(rdl.RdlSchema(), "Schema", asJSON(test, schema)); v.Error != "" {
+		test.Errorf("The inferred schema is not a valid Schema: %v", v)
+	}
+
+	//each value in the stream is valid for one of the inferred types. The validator expects a union to be
+	//wrapped in the name of its variant, which tbin data is not, so the last values are not checked.
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	for range values[:3] {
+		var generic interface{}
+		if err = dec.Decode(&generic); err != nil {
+			test.Fatalf("Cannot decode: %v", err)
+		}
+		if v := rdl.Validate(schema, "", asJSON(test, generic)); v.Error != "" {
+			test.Errorf("The data is not valid for its inferred schema: %v", v)
+		}
+	}
+
+	if _, err = InferSchema(bytes.NewReader(buf.Bytes()[:40])); err == nil {
+		test.Errorf("Expected an error inferring a schema from truncated data")
+	}
+}
+
+//inferredRDL renders the kinds of types that InferSchema produces as RDL source
+func inferredRDL(schema *rdl.Schema) string {
+	var b strings.Builder
+	number := func(n *rdl.Number) string {
+		switch {
+		case n.Int16 != nil:
+			return fmt.Sprint(*n.Int16)
+		case n.Int32 != nil:
+			return fmt.Sprint(*n.Int32)
+		case n.Int64 != nil:
+			return fmt.Sprint(*n.Int64)
+		}
+		return "?"
+	}
+	fmt.Fprintf(&b, "// %s\nname %s;\n\n", schema.Comment, schema.Name)
+	for _, t := range schema.Types {
+		switch t.Variant {
+		case rdl.TypeVariantNumberTypeDef:
+			td := t.NumberTypeDef
+			if td.Comment != "" {
+				fmt.Fprintf(&b, "// %s\n", td.Comment)
+			}
+			fmt.Fprintf(&b, "type %s %s (min=%s", td.Name, td.Type, number(td.Min))
+			if td.Max != nil {
+				fmt.Fprintf(&b, ", max=%s", number(td.Max))
+			}
+			b.WriteString(");\n")
+		case rdl.TypeVariantStructTypeDef:
+			td := t.StructTypeDef
+			fmt.Fprintf(&b, "type %s %s {\n", td.Name, td.Type)
+			for _, f := range td.Fields {
+				if f.Optional {
+					fmt.Fprintf(&b, "    %s %s (optional);\n", f.Type, f.Name)
+				} else {
+					fmt.Fprintf(&b, "    %s %s;\n", f.Type, f.Name)
+				}
+			}
+			b.WriteString("}\n")
+		case rdl.TypeVariantArrayTypeDef:
+			fmt.Fprintf(&b, "type %s %s<%s>;\n", t.ArrayTypeDef.Name, t.ArrayTypeDef.Type, t.ArrayTypeDef.Items)
+		case rdl.TypeVariantMapTypeDef:
+			if t.MapTypeDef.Comment != "" {
+				fmt.Fprintf(&b, "// %s\n", t.MapTypeDef.Comment)
+			}
+			fmt.Fprintf(&b, "type %s %s<%s,%s>;\n", t.MapTypeDef.Name, t.MapTypeDef.Type, t.MapTypeDef.Keys, t.MapTypeDef.Items)
+		case rdl.TypeVariantEnumTypeDef:
+			fmt.Fprintf(&b, "type %s %s {\n", t.EnumTypeDef.Name, t.EnumTypeDef.Type)
+			for _, e := range t.EnumTypeDef.Elements {
+				fmt.Fprintf(&b, "    %s\n", e.Symbol)
+			}
+			b.WriteString("}\n")
+		case rdl.TypeVariantUnionTypeDef:
+			var variants []string
+			for _, v := range t.UnionTypeDef.Variants {
+				variants = append(variants, string(v))
+			}
+			fmt.Fprintf(&b, "type %s %s<%s>;\n", t.UnionTypeDef.Name, t.UnionTypeDef.Type, strings.Join(variants, ","))
+		default:
+			fmt.Fprintf(&b, "unexpected type %v\n", t)
+		}
+	}
+	return b.String()
+}
+
+//the inferred schema can be written as RDL source, to be edited, and parsed again
+func TestInferSchemaRDL(test *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	drawing := &Drawing{Shapes: []*Shape{rectShape(rect(1, 2, 3, 4)), lineShape(polyline())}}
+	for _, v := range []interface{}{loadBigTest(test), &Counters{Hits: math.MaxUint64, Small: 7}, map[string]int32{"a": 1}, drawing, newBaseTypesCollections()} {
+		enc.Encode(v)
+	}
+	enc.Flush()
+	schema, err := InferSchema(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		test.Fatalf("Cannot infer a schema: %v", err)
+	}
+	src := inferredRDL(schema)
+	parsed, err := rdl.ParseRDLWithOptions("inferred.rdl", strings.NewReader(src), &rdl.ParseOptions{NoWarn: true})
+	if err != nil {
+		test.Fatalf("Cannot parse the inferred schema: %v\n%s", err, src)
+	}
+	if diff := rdl.CompareSchemas(schema, parsed); diff != "" {
+		test.Errorf("The parsed schema differs from the inferred one: %s\n%s", diff, src)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+var fuzzOptions = &DecoderOptions{
+	MaxDepth:            64,
+	MaxCollectionLength: 10000,
+	MaxBytes:            1 << 20,
+	MaxTypes:            256,
+	MaxSymbols:          1024,
+}
+
+func expectDecodeError(test *testing.T, msg string, data []byte, target interface{}, opts *DecoderOptions) {
+	err := UnmarshalWithOptions(data, target, opts)
+	if err == nil {
+		test.Errorf("%s: expected a decoding error", msg)
+	}
+}
+
+func TestDecoderLimits(test *testing.T) {
+	var generic interface{}
+
+	nested := []interface{}{[]interface{}{[]interface{}{[]interface{}{"deep"}}}}
+	tdata, _ := Marshal(nested)
+	expectDecodeError(test, "MaxDepth", tdata, &generic, &DecoderOptions{MaxDepth: 3})
+	if err := UnmarshalWithOptions(tdata, &generic, &DecoderOptions{MaxDepth: 10}); err != nil {
+		test.Errorf("MaxDepth: unexpected error within the limit: %v", err)
+	}
+
+	tdata, _ = Marshal([]int32{1, 2, 3, 4, 5})
+	expectDecodeError(test, "MaxCollectionLength", tdata, &generic, &DecoderOptions{MaxCollectionLength: 4})
+	var ai []int32
+	expectDecodeError(test, "MaxCollectionLength (reflect)", tdata, &ai, &DecoderOptions{MaxCollectionLength: 4})
+
+	tdata, _ = Marshal(polyline())
+	expectDecodeError(test, "MaxBytes", tdata, &generic, &DecoderOptions{MaxBytes: int64(len(tdata) - 1)})
+	if err := UnmarshalWithOptions(tdata, &generic, &DecoderOptions{MaxBytes: int64(len(tdata))}); err != nil {
+		test.Errorf("MaxBytes: unexpected error within the limit: %v", err)
+	}
+
+	tdata, _ = Marshal(newBaseTypesCollections())
+	expectDecodeError(test, "MaxTypes", tdata, &generic, &DecoderOptions{MaxTypes: 2})
+
+	tdata, _ = Marshal([]interface{}{rdl.Symbol("a"), rdl.Symbol("b"), rdl.Symbol("c")})
+	expectDecodeError(test, "MaxSymbols", tdata, &generic, &DecoderOptions{MaxSymbols: 2})
+}
+
+func TestDecoderMalformed(test *testing.T) {
+	malformed := []struct {
+		msg  string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"bad header", []byte{0x01}},
+		{"truncated int", []byte{CurVersionTag, Int32Tag, 0x80}},
+		{"truncated string", []byte{CurVersionTag, StringTag, 0x10, 'a'}},
+		{"truncated tiny string", []byte{CurVersionTag, TinyStrTag | 5, 'a'}},
+		{"huge string length", []byte{CurVersionTag, StringTag, 0xff, 0xff, 0xff, 0xff, 0x0f}},
+		{"truncated uuid", []byte{CurVersionTag, UUIDTag, 1, 2, 3}},
+		{"huge array length", []byte{CurVersionTag, ArrayTag, 0xff, 0xff, 0xff, 0xff, 0x0f}},
+		{"huge struct length", []byte{CurVersionTag, StructTag, 0xff, 0xff, 0xff, 0xff, 0x07}},
+		{"undefined symbol", []byte{CurVersionTag, SymbolTag, 5}},
+		{"undefined user tag", []byte{CurVersionTag, FirstUserTag + 3, DefArrayTag, Int32Tag}},
+		{"user tag without typedef", []byte{CurVersionTag, FirstUserTag, Int32Tag}},
+		{"typedef referencing itself", []byte{CurVersionTag, FirstUserTag, DefArrayTag, FirstUserTag}},
+		{"bad enum index", []byte{CurVersionTag, FirstUserTag, DefEnumTag, 1, 1, 'A', FirstUserTag, 20}},
+		{"negative enum index", []byte{CurVersionTag, FirstUserTag, DefEnumTag, 1, 1, 'A', FirstUserTag, 3}},
+		{"zero union variant", []byte{CurVersionTag, FirstUserTag, DefUnionTag, 1, Int32Tag, FirstUserTag, 0}},
+		{"bad union variant", []byte{CurVersionTag, FirstUserTag, DefUnionTag, 1, Int32Tag, FirstUserTag, 9, 2}},
+		{"bad typedef tag", []byte{CurVersionTag, FirstUserTag, DefStructTag, 1, 1, 'x', 0x3f, FirstUserTag, 2}},
+		{"unknown tag", []byte{CurVersionTag, 0x1f}},
+		{"many items with no content", []byte{CurVersionTag, FirstUserTag, DefArrayTag, NullTag, FirstUserTag, 0x8f, 0x4e}},
+	}
+	for _, m := range malformed {
+		var generic interface{}
+		expectDecodeError(test, m.msg+" (generic)", m.data, &generic, nil)
+		var drawing Drawing
+		expectDecodeError(test, m.msg+" (reflect)", m.data, &drawing, nil)
+	}
+
+	//structurally valid data that doesn't fit the target type
+	tdata, _ := Marshal(rect(1, 2, 3, 4))
+	var line Polyline
+	expectDecodeError(test, "mismatched target", tdata, &line, nil)
+	var n int32
+	expectDecodeError(test, "mismatched scalar target", tdata, &n, nil)
+	tdata, _ = Marshal("a string")
+	expectDecodeError(test, "string into int", tdata, &n, nil)
+}
+
+//zeroWidthBomb defines type 0 as Struct{a:Null, b:Null}, and each type k after it as
+//Struct{a:k-1, b:k-1}, then has a value of the last type, which takes no bytes but has 2^60 values.
+func zeroWidthBomb(types int) []byte {
+	data := []byte{CurVersionTag}
+	for k := 0; k < types; k++ {
+		ftype := byte(NullTag)
+		if k > 0 {
+			ftype = byte(FirstUserTag + k - 1)
+		}
+		data = append(data, byte(FirstUserTag+k), DefStructTag, 2, 1, 'a', ftype, 1, 'b', ftype)
+	}
+	return append(data, byte(FirstUserTag+types-1))
+}
+
+func TestDecoderZeroWidthBomb(test *testing.T) {
+	data := zeroWidthBomb(60)
+	if len(data) != 542 {
+		test.Fatalf("Wrong size of the input: %d", len(data))
+	}
+	var generic interface{}
+	expectDecodeError(test, "zero width bomb (generic)", data, &generic, fuzzOptions)
+	expectDecodeError(test, "zero width bomb (generic, no options)", data, &generic, nil)
+	var drawing Drawing
+	expectDecodeError(test, "zero width bomb (reflect)", data, &drawing, fuzzOptions)
+	if _, err := UnmarshalPath(data, "a.b"); err == nil {
+		test.Errorf("zero width bomb (path): expected a decoding error")
+	}
+
+	//a few levels are within the limit of values with no content
+	if err := UnmarshalWithOptions(zeroWidthBomb(4), &generic, fuzzOptions); err != nil {
+		test.Errorf("Unexpected error for a small struct of nulls: %v", err)
+	}
+}
+
+func TestDecoderMaxValues(test *testing.T) {
+	var generic interface{}
+	tdata, _ := Marshal(polyline())
+	expectDecodeError(test, "MaxValues", tdata, &generic, &DecoderOptions{MaxValues: 5})
+	if err := UnmarshalWithOptions(tdata, &generic, &DecoderOptions{MaxValues: 1000}); err != nil {
+		test.Errorf("MaxValues: unexpected error within the limit: %v", err)
+	}
+}
+
+func fuzzSeeds() [][]byte {
+	var seeds [][]byte
+	for _, name := range []string{"test.tbin", "test_generic.tbin", "test_rect.tbin", "rdl_schema.tbin"} {
+		if data, err := ioutil.ReadFile("../testdata/" + name); err == nil {
+			seeds = append(seeds, data)
+		}
+	}
+	drawing := &Drawing{Shapes: []*Shape{rectShape(rect(1, 2, 3, 4)), lineShape(polyline())}}
+	for _, o := range []interface{}{polyline(), drawing, newBaseTypesCollections(), rdl.Struct{"a": int32(1)}} {
+		if data, err := Marshal(o); err == nil {
+			seeds = append(seeds, data)
+		}
+	}
+	return append(seeds, zeroWidthBomb(60))
+}
+
+func FuzzDecodeGeneric(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var generic interface{}
+		UnmarshalWithOptions(data, &generic, fuzzOptions)
+	})
+}
+
+func FuzzDecodeReflect(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var drawing Drawing
+		UnmarshalWithOptions(data, &drawing, fuzzOptions)
+		var collections baseTypesCollections
+		UnmarshalWithOptions(data, &collections, fuzzOptions)
+		var schema rdl.Schema
+		UnmarshalWithOptions(data, &schema, fuzzOptions)
+	})
+}
@@ -4,38 +4,11 @@
 package tbin
 
 import (
-	"fmt"
 	"io/ioutil"
 	"testing"
 )
 
-func (line *Polyline) UnmarshalTBin(dec *Decoder) error {
-	//the signature is calculated via reflection, and is pretty slow.
-	//signature := TypeSignature(line)
-	//but can be generated with other code:
-	//signature := Struct(Field("points", Array(Struct(Field("x", Int32), Field("y", Int32)))))
-	signature := polylineSignature
-	refSig, err := dec.ReadType()
-	if err != nil {
-		return err
-	}
-	if signature.String() != refSig.String() {
-		return fmt.Errorf("Signature mismatch on decode: %v vs %v", signature, refSig)
-	}
-	size := dec.ReadSize()
-	if dec.Error() == nil {
-		proto := Polyline{}
-		proto.Points = make([]*Point, size)
-		for i := 0; i < size; i++ {
-			x := dec.ReadInt32()
-			y := dec.ReadInt32()
-			proto.Points[i] = &Point{x, y}
-		}
-		*line = proto
-		return nil
-	}
-	return dec.Error()
-}
+//the TBinUnmarshallable implementation for Polyline is generated in polyline_tbin.go
 
 func TestTBinMarshallableDecode(test *testing.T) {
 	var line Polyline
@@ -10,26 +10,9 @@ import (
 	"testing"
 )
 
-//the model is generated in polyline_model.go. Here we add methods to the Polyline type
-
-//this is constant, once calculated, so could be produced from code generation
-var polylineSignature = Struct(Field("points", Array(Struct(Field("x", Int32, false), Field("y", Int32, false))), false))
-
-func (line Polyline) MarshalTBin(enc *Encoder) error {
-	//the signature is calculated via reflection, and is pretty slow.
-	//signature := TypeSignature(line)
-	//But it is constant for the type, so the following code could be generated instead
-	//signature := Struct(Field("points", Array(Struct(Field("x", Int32), Field("y", Int32)))))
-	//even that has sizable cost, so have a global to hold it is quite a bit better:
-	signature := polylineSignature
-	enc.WriteType(signature)
-	enc.WriteSize(len(line.Points)) //the array length
-	for _, pt := range line.Points {
-		enc.WriteInt32(pt.X) //and the data
-		enc.WriteInt32(pt.Y) //for each item, packed
-	}
-	return enc.Error()
-}
+//the model is generated in polyline_model.go, and its TBinMarshallable implementation
+//in polyline_tbin.go, by GenerateMarshalCode. The signature is a generated variable, and the
+//points are written packed, with no per-item tags.
 
 func TestTBinMarshallableEncode(test *testing.T) {
 	line := polyline()
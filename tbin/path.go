@@ -0,0 +1,310 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+//
+// Go implementation of the tbin encoding format
+//
+
+package tbin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//
+// A path selects sub-values of a value, so that only those parts of a stream need to be decoded.
+// It is a sequence of steps: field names separated by dots, and bracketed array indices. For
+// example, "items[*].x" selects the x field of every element of the items array. The "*" step
+// selects every element of an array, every value of a map, and every field of a struct, and can
+// be written either as a name or in brackets. A name step also selects the value of the map entry
+// with that key. The empty path selects the whole value.
+//
+type pathStep struct {
+	name  string
+	index int //the array index for a bracketed step, else -1
+	wild  bool
+}
+
+func parsePath(path string) ([]pathStep, error) {
+	var steps []pathStep
+	i := 0
+	for i < len(path) {
+		if path[i] == '[' {
+			j := strings.IndexByte(path[i:], ']')
+			if j < 0 {
+				return nil, fmt.Errorf("Bad tbin path %q: unterminated '['", path)
+			}
+			sel := path[i+1 : i+j]
+			if sel == "*" {
+				steps = append(steps, pathStep{index: -1, wild: true})
+			} else {
+				n, err := strconv.Atoi(sel)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("Bad tbin path %q: bad array index %q", path, sel)
+				}
+				steps = append(steps, pathStep{index: n})
+			}
+			i += j + 1
+			continue
+		}
+		if len(steps) > 0 {
+			if path[i] != '.' {
+				return nil, fmt.Errorf("Bad tbin path %q: expected '.' or '[' at offset %d", path, i)
+			}
+			i++
+		}
+		j := i
+		for j < len(path) && path[j] != '.' && path[j] != '[' {
+			j++
+		}
+		name := path[i:j]
+		if name == "" {
+			return nil, fmt.Errorf("Bad tbin path %q: missing name at offset %d", path, i)
+		}
+		steps = append(steps, pathStep{name: name, index: -1, wild: name == "*"})
+		i = j
+	}
+	return steps, nil
+}
+
+//a pathCursor is a path that has matched so far, with the steps that remain to be matched
+type pathCursor struct {
+	steps  []pathStep
+	result *[]interface{}
+}
+
+// advance returns the cursors whose next step matches a struct field or map key with the given
+// name, or, when index is not negative, an array element with that index.
+func advance(cursors []pathCursor, name string, index int) []pathCursor {
+	var next []pathCursor
+	for _, c := range cursors {
+		s := c.steps[0]
+		if s.wild || (index >= 0 && s.index == index) || (index < 0 && s.index < 0 && s.name == name) {
+			next = append(next, pathCursor{steps: c.steps[1:], result: c.result})
+		}
+	}
+	return next
+}
+
+func hasIndexStep(cursors []pathCursor) bool {
+	for _, c := range cursors {
+		if c.steps[0].index >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func keyName(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}
+
+// DecodePath decodes only the parts of the next value in the stream that the path selects, and
+// skips the rest. The selected values are returned in stream order, as the generic values that
+// Decode produces for an interface{} target.
+func (d *Decoder) DecodePath(path string) ([]interface{}, error) {
+	results, err := d.Project(path)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// Project is like DecodePath, but selects with several paths in a single pass over the value.
+// The values each path selects are returned at the same index in the result.
+func (d *Decoder) Project(paths ...string) (results [][]interface{}, err error) {
+	defer d.recoverError(&err)
+	if d.err != nil {
+		return nil, d.err
+	}
+	results = make([][]interface{}, len(paths))
+	cursors := make([]pathCursor, 0, len(paths))
+	for i, path := range paths {
+		steps, err := parsePath(path)
+		if err != nil {
+			return nil, err
+		}
+		cursors = append(cursors, pathCursor{steps: steps, result: &results[i]})
+	}
+	if d.project(Any, cursors) != nil {
+		return nil, d.err
+	}
+	return results, nil
+}
+
+func (d *Decoder) project(tt *Signature, cursors []pathCursor) error {
+	if len(cursors) == 0 {
+		return d.skipType(tt)
+	}
+	for _, c := range cursors {
+		if len(c.steps) == 0 {
+			//a selected value: decode it, then find anything the other paths select within it
+			v, err := d.decodeType(tt)
+			if err != nil {
+				return err
+			}
+			for _, c := range cursors {
+				selectValue(v, c.steps, c.result)
+			}
+			return nil
+		}
+	}
+	defer d.leave()
+	if d.enter() != nil {
+		return d.err
+	}
+	if tt == nil {
+		d.err = fmt.Errorf("decode of a missing type")
+		return d.err
+	}
+	switch tt.Tag {
+	case AnyTag:
+		return d.projectTagged(cursors)
+	case StructTag:
+		if tt.Fields == nil {
+			d.pendingTag = StructTag
+			return d.projectTagged(cursors)
+		}
+		for _, f := range tt.Fields {
+			if d.project(f.Type, advance(cursors, f.Name, -1)) != nil {
+				break
+			}
+		}
+		return d.err
+	case ArrayTag:
+		return d.projectArray(tt.Items, cursors)
+	case MapTag:
+		return d.projectMap(tt.Keys, tt.Items, cursors)
+	case UnionTag:
+		nvariant := int(d.ParseUnsigned())
+		if d.err != nil {
+			return d.err
+		}
+		if nvariant < 1 || nvariant > len(tt.Variants) {
+			d.err = fmt.Errorf("Union variant out of range: %d", nvariant)
+			return d.err
+		}
+		return d.project(tt.Variants[nvariant-1], cursors)
+	}
+	//nothing can be selected within a scalar
+	return d.skipType(tt)
+}
+
+func (d *Decoder) projectTagged(cursors []pathCursor) error {
+again:
+	tag := d.nextTag()
+	if d.err != nil {
+		return d.err
+	}
+	if tag >= FirstUserTag {
+		idx := int(tag - FirstUserTag)
+		if idx < len(d.types) {
+			return d.project(d.types[idx], cursors)
+		}
+		if d.defineType(tag) == nil {
+			return d.err
+		}
+		goto again
+	}
+	switch tag {
+	case StructTag:
+		nfields := d.parseSize()
+		for i := 0; i < nfields && d.err == nil; i++ {
+			name, _ := d.ParseSymbol()
+			d.project(Any, advance(cursors, name, -1))
+		}
+		return d.err
+	case ArrayTag:
+		return d.projectArray(Any, cursors)
+	case MapTag:
+		return d.projectMap(Any, Any, cursors)
+	}
+	d.pendingTag = int(tag)
+	return d.skip()
+}
+
+func (d *Decoder) projectArray(items *Signature, cursors []pathCursor) error {
+	count := d.parseSize()
+	if d.checkItems(count, items) != nil {
+		return d.err
+	}
+	indexed := hasIndexStep(cursors)
+	var next []pathCursor
+	if !indexed {
+		next = advance(cursors, "", 0)
+	}
+	for i := 0; i < count && d.err == nil; i++ {
+		if indexed {
+			next = advance(cursors, "", i)
+		}
+		d.project(items, next)
+	}
+	return d.err
+}
+
+func (d *Decoder) projectMap(keys *Signature, items *Signature, cursors []pathCursor) error {
+	count := d.parseSize()
+	if d.checkItems(count, keys, items) != nil {
+		return d.err
+	}
+	for i := 0; i < count && d.err == nil; i++ {
+		key, err := d.decodeType(keys)
+		if err != nil {
+			return err
+		}
+		d.project(items, advance(cursors, keyName(key), -1))
+	}
+	return d.err
+}
+
+// selectValue applies the remaining steps of a path to an already decoded value. The fields of
+// a decoded struct are unordered, so a "*" step selects them in the order of their names.
+func selectValue(v interface{}, steps []pathStep, result *[]interface{}) {
+	if len(steps) == 0 {
+		*result = append(*result, v)
+		return
+	}
+	s := steps[0]
+	switch tv := v.(type) {
+	case []interface{}:
+		for i, item := range tv {
+			if s.wild || s.index == i {
+				selectValue(item, steps[1:], result)
+			}
+		}
+	case map[string]interface{}:
+		if !s.wild {
+			if item, ok := tv[s.name]; ok && s.index < 0 {
+				selectValue(item, steps[1:], result)
+			}
+			return
+		}
+		keys := make([]string, 0, len(tv))
+		for k := range tv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			selectValue(tv[k], steps[1:], result)
+		}
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(tv))
+		items := make(map[string]interface{}, len(tv))
+		for k, item := range tv {
+			if s.wild || (s.index < 0 && keyName(k) == s.name) {
+				keys = append(keys, keyName(k))
+				items[keyName(k)] = item
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			selectValue(items[k], steps[1:], result)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+func TestSkip(test *testing.T) {
+	drawing := &Drawing{Shapes: []*Shape{rectShape(rect(1, 2, 3, 4)), lineShape(polyline())}}
+	generic := map[string]interface{}{"a": []interface{}{int32(1), "two", 3.0}, "b": rdl.Symbol("sym"), "c": nil, "d": true}
+	values := []interface{}{polyline(), drawing, loadBigTest(test), newBaseTypesCollections(), generic, rdl.Struct{"x": rdl.Symbol("sym")}}
+	for _, o := range values {
+		//the same type and symbols again after the skipped value, so it must define them for later use
+		enc := NewEncoder(nil)
+		enc.Encode(o)
+		enc.Encode(o)
+		enc.Encode("end")
+		if enc.Error() != nil {
+			test.Fatalf("Cannot encode %T: %v", o, enc.Error())
+		}
+		dec := NewDecoder(bytes.NewReader(enc.Bytes()))
+		if err := dec.Skip(); err != nil {
+			test.Errorf("Cannot skip %T: %v", o, err)
+			continue
+		}
+		v := reflect.Indirect(reflect.ValueOf(o))
+		o2 := reflect.New(v.Type())
+		if err := dec.Decode(o2.Interface()); err != nil {
+			test.Errorf("Cannot decode %T after skipping one: %v", o, err)
+		} else if j1, j2 := Pretty(v.Interface()), Pretty(o2.Elem().Interface()); j1 != j2 {
+			test.Errorf("Decoded %T after skipping one is not the same as the original", o)
+		}
+		if err := dec.Skip(); err != nil {
+			test.Errorf("Cannot skip a string after %T: %v", o, err)
+		}
+		if err := dec.Skip(); err == nil {
+			test.Errorf("Expected an error skipping past the end of the data")
+		}
+	}
+	tdata, _ := Marshal(polyline())
+	if err := NewDecoder(bytes.NewReader(tdata[:len(tdata)-3])).Skip(); err == nil {
+		test.Errorf("Expected an error skipping truncated data")
+	}
+}
+
+func TestSkipType(test *testing.T) {
+	line := polyline()
+	enc := NewEncoder(nil)
+	enc.Encode(line)
+	enc.Encode(int32(23))
+	dec := NewDecoder(bytes.NewReader(enc.Bytes()))
+	sig, err := dec.ReadType()
+	if err != nil {
+		test.Fatalf("Cannot read the type: %v", err)
+	}
+	if err = dec.SkipType(sig); err != nil {
+		test.Fatalf("Cannot skip Polyline: %v", err)
+	}
+	var n int32
+	if err = dec.Decode(&n); err != nil || n != 23 {
+		test.Errorf("Expected 23 after the skipped value, got %v (%v)", n, err)
+	}
+}
+
+func checkPath(test *testing.T, tdata []byte, path string, expected ...interface{}) {
+	result, err := UnmarshalPath(tdata, path)
+	if err != nil {
+		test.Errorf("Cannot decode path %q: %v", path, err)
+	} else if !reflect.DeepEqual(result, expected) {
+		test.Errorf("Path %q selected %v, expected %v", path, result, expected)
+	}
+}
+
+func TestDecodePath(test *testing.T) {
+	tdata, _ := Marshal(polyline())
+	checkPath(test, tdata, "points[*].x", int32(1), int32(2), int32(3), int32(10), int32(-23), int32(-23), int32(10), int32(103), int32(300), int32(1234), int32(12345678), int32(321321321), int32(1))
+	checkPath(test, tdata, "points[3].y", int32(100))
+	checkPath(test, tdata, "points[3]", map[string]interface{}{"x": int32(10), "y": int32(100)})
+	checkPath(test, tdata, "points[99].y")
+	checkPath(test, tdata, "nothing")
+	checkPath(test, tdata, "points.x")
+
+	bt := loadBigTest(test)
+	tdata, _ = Marshal(bt)
+	checkPath(test, tdata, "stuff[*].myName", " I am a string", "Blah blah")
+	checkPath(test, tdata, "stuff[1].myMap.four", int32(4))
+	checkPath(test, tdata, "stuff[0].myStringArray[*]", "abc", "def", "gcg")
+	checkPath(test, tdata, "*[0].myBool", false)
+
+	//the generic encoding, with tagged values and naked structs
+	tdata, _ = Marshal(map[string]interface{}{"items": []interface{}{rdl.Struct{"x": int32(1)}, rdl.Struct{"x": "two"}}})
+	checkPath(test, tdata, "items[*].x", int32(1), "two")
+	checkPath(test, tdata, "items[1].*", "two")
+	checkPath(test, tdata, "", map[string]interface{}{"items": []interface{}{map[string]interface{}{"x": int32(1)}, map[string]interface{}{"x": "two"}}})
+
+	//a selected value is decoded whole, so paths within it are applied to the decoded value
+	tdata, _ = Marshal(polyline())
+	dec := NewDecoder(bytes.NewReader(tdata))
+	results, err := dec.Project("points[12]", "points[*].y", "points[12].x")
+	if err != nil {
+		test.Fatalf("Cannot project: %v", err)
+	}
+	if len(results) != 3 || len(results[0]) != 1 || len(results[1]) != 13 || !reflect.DeepEqual(results[2], []interface{}{int32(1)}) {
+		test.Errorf("Unexpected projection results: %v", results)
+	}
+}
+
+func TestDecodePathErrors(test *testing.T) {
+	tdata, _ := Marshal(polyline())
+	for _, path := range []string{".points", "points.", "points[", "points[x]", "points[-1]", "points[*]x", "points..x"} {
+		if _, err := UnmarshalPath(tdata, path); err == nil {
+			test.Errorf("Expected an error for the bad path %q", path)
+		}
+	}
+	if _, err := UnmarshalPath(tdata[:len(tdata)-3], "points[*].x"); err == nil {
+		test.Errorf("Expected an error for truncated data")
+	}
+	if _, err := UnmarshalPath([]byte{CurVersionTag, 0x1f}, "x"); err == nil {
+		test.Errorf("Expected an error for malformed data")
+	}
+}
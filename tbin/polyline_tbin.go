@@ -0,0 +1,98 @@
+//
+// This file generated by tbin.GenerateMarshalCode from the "test" schema. Do not edit.
+//
+
+package tbin
+
+import (
+	"fmt"
+)
+
+var tbinSignaturePoint = Struct(Field("x", Int32, false), Field("y", Int32, false))
+
+// MarshalTBin - encodes the Point as TBin, implementing TBinMarshallable
+func (o Point) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignaturePoint)
+	return o.writeTBin(enc)
+}
+
+func (o *Point) writeTBin(enc *Encoder) error {
+	enc.WriteInt32(o.X)
+	enc.WriteInt32(o.Y)
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the Point, implementing TBinUnmarshallable
+func (o *Point) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignaturePoint.String() {
+		return fmt.Errorf("Cannot unmarshal %v into Point", sig)
+	}
+	var v Point
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *Point) readTBin(dec *Decoder) error {
+	o.X = int32(dec.ParseInt())
+	o.Y = int32(dec.ParseInt())
+	return dec.Error()
+}
+
+var tbinSignaturePolyline = Struct(Field("points", Array(tbinSignaturePoint), false))
+
+// MarshalTBin - encodes the Polyline as TBin, implementing TBinMarshallable
+func (o Polyline) MarshalTBin(enc *Encoder) error {
+	enc.WriteType(tbinSignaturePolyline)
+	return o.writeTBin(enc)
+}
+
+func (o *Polyline) writeTBin(enc *Encoder) error {
+	enc.WriteSize(len(o.Points))
+	for _, v1 := range o.Points {
+		if v1 == nil {
+			return fmt.Errorf("Cannot marshal null pointer for required field Polyline.points item")
+		}
+		if err := v1.writeTBin(enc); err != nil {
+			return err
+		}
+	}
+	return enc.Error()
+}
+
+// UnmarshalTBin - decodes TBin into the Polyline, implementing TBinUnmarshallable
+func (o *Polyline) UnmarshalTBin(dec *Decoder) error {
+	sig, err := dec.ReadType()
+	if err != nil {
+		return err
+	}
+	if sig.String() != tbinSignaturePolyline.String() {
+		return fmt.Errorf("Cannot unmarshal %v into Polyline", sig)
+	}
+	var v Polyline
+	err = v.readTBin(dec)
+	if err == nil {
+		*o = v
+	}
+	return err
+}
+
+func (o *Polyline) readTBin(dec *Decoder) error {
+	n1 := dec.ReadSize()
+	a1 := make([]*Point, 0)
+	for i1 := 0; i1 < n1 && dec.Error() == nil; i1++ {
+		v2 := new(Point)
+		if err := v2.readTBin(dec); err != nil {
+			return err
+		}
+		a1 = append(a1, v2)
+	}
+	o.Points = a1
+	return dec.Error()
+}
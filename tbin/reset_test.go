@@ -0,0 +1,160 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+func TestEncoderReset(test *testing.T) {
+	line := polyline()
+	expected, _ := Marshal(line)
+	enc := NewEncoder(nil)
+	enc.Encode(rect(1, 2, 3, 4))
+	enc.Reset(nil, false)
+	enc.Encode(line)
+	if !bytes.Equal(enc.Bytes(), expected) {
+		test.Errorf("Reset encoder does not match a new one:\n% x\n% x", enc.Bytes(), expected)
+	}
+
+	//with the types kept, the second stream is just the header and the value
+	var out bytes.Buffer
+	enc.Reset(&out, true)
+	enc.Encode(line)
+	enc.Flush()
+	if out.Len() != 1+46 {
+		test.Errorf("Expected the typedefs to be omitted, got %d bytes", out.Len())
+	}
+	dec := NewDecoder(bytes.NewReader(expected))
+	var line2 Polyline
+	if err := dec.Decode(&line2); err != nil {
+		test.Fatalf("Cannot decode: %v", err)
+	}
+	if err := dec.Reset(&out, true); err != nil {
+		test.Fatalf("Cannot reset the decoder: %v", err)
+	}
+	line2 = Polyline{}
+	if err := dec.Decode(&line2); err != nil || !Equal(line, &line2) {
+		test.Errorf("Cannot decode a stream continuing the session: %v", err)
+	}
+	//a decoder that did not keep the types cannot
+	if err := Unmarshal(out.Bytes(), &line2); err == nil {
+		test.Errorf("Expected an error decoding a continued stream without its types")
+	}
+
+	//a reset encoder reseeds its dictionary
+	dict, _ := NewDictionary("polyline/1", []*Signature{TypeSignature(line)}, nil)
+	enc = NewEncoderWithOptions(nil, &EncoderOptions{Dictionary: dict})
+	enc.Encode(line)
+	first := append([]byte(nil), enc.Bytes()...)
+	enc.Reset(nil, false)
+	enc.Encode(line)
+	if !bytes.Equal(enc.Bytes(), first) {
+		test.Errorf("Reset encoder does not match a new one with the dictionary")
+	}
+}
+
+func TestDecoderReset(test *testing.T) {
+	line := polyline()
+	tdata, _ := Marshal(line)
+	dec := NewDecoderWithOptions(bytes.NewReader(tdata[:20]), &DecoderOptions{MaxBytes: int64(len(tdata))})
+	var line2 Polyline
+	if err := dec.Decode(&line2); err == nil {
+		test.Errorf("Expected an error decoding truncated data")
+	}
+	//the error and the partial typedefs are discarded, the limits are kept
+	if err := dec.Reset(bytes.NewReader(tdata), false); err != nil {
+		test.Fatalf("Cannot reset the decoder: %v", err)
+	}
+	if err := dec.Decode(&line2); err != nil || !Equal(line, &line2) {
+		test.Errorf("Cannot decode after a reset: %v", err)
+	}
+	big, _ := Marshal(&Drawing{Shapes: []*Shape{lineShape(line), lineShape(line)}})
+	dec.Reset(bytes.NewReader(big), false)
+	var drawing Drawing
+	if err := dec.Decode(&drawing); err == nil {
+		test.Errorf("Expected the byte limit to apply after a reset")
+	}
+	if err := dec.Reset(bytes.NewReader([]byte{0x01}), false); err == nil {
+		test.Errorf("Expected an error for a bad header")
+	}
+}
+
+func TestPooledMarshalConcurrently(test *testing.T) {
+	values := []interface{}{polyline(), rect(1, 2, 3, 4), &Drawing{Shapes: []*Shape{lineShape(polyline())}}, map[string]interface{}{"a": "b"}}
+	expected := make([][]byte, len(values))
+	for i, v := range values {
+		expected[i], _ = unpooledMarshal(v)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 200; n++ {
+				i := (g + n) % len(values)
+				b, err := Marshal(values[i])
+				if err == nil && !bytes.Equal(b, expected[i]) {
+					err = fmt.Errorf("pooled encoding of %T differs", values[i])
+				}
+				var generic interface{}
+				if err == nil {
+					err = Unmarshal(b, &generic)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		test.Error(err)
+	}
+}
+
+//a decoder that has decoded many types or symbols is not pooled, as it would hold their tables
+func TestPooledDecoderRelease(test *testing.T) {
+	small, _ := Marshal(polyline())
+	st := make(rdl.Struct)
+	for i := 0; i <= maxPooledTypes; i++ {
+		st[rdl.Symbol(fmt.Sprintf("field%d", i))] = int32(i)
+	}
+	large, _ := Marshal(st)
+	pd := decoderPool.New().(*pooledDecoder)
+	var x interface{}
+	if err := pd.unmarshal(small, &x); err != nil || !pd.release() {
+		test.Errorf("Expected a decoder to be pooled after small data (%v)", err)
+	}
+	pd = decoderPool.New().(*pooledDecoder)
+	if err := pd.unmarshal(large, &x); err != nil || pd.release() {
+		test.Errorf("Expected a decoder not to be pooled after %d symbols (%v)", len(pd.dec.syms), err)
+	}
+}
+
+//unpooledMarshal encodes with a new encoder, rather than a pooled one
+func unpooledMarshal(v interface{}) ([]byte, error) {
+	enc := NewEncoder(nil)
+	err := enc.Encode(v)
+	return enc.Bytes(), err
+}
+
+func TestTypeSignatureCache(test *testing.T) {
+	line := polyline()
+	sig := TypeSignature(line)
+	if TypeSignature(line) != sig || TypeSignature(*line) != sig {
+		test.Errorf("Expected the signature to be cached")
+	}
+	if sig.key == "" || sig.Fields[0].Type.Items.key == "" {
+		test.Errorf("Expected the cached signature keys to be computed")
+	}
+}
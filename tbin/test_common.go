@@ -18,6 +18,7 @@ const testDataJSON = `{"points":[{"x":1,"y":11},{"x":2,"y":22},{"x":3,"y":33},{"
 const testDataLengthJSON = 250
 const testDataLengthTBinGeneric = 160
 const testDataLengthTBinBest = 70
+const testDataLengthTBinDictionary = 64
 
 func polyline() *Polyline {
 	var line Polyline
@@ -0,0 +1,136 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ardielle/ardielle-go/rdl"
+)
+
+const timestampTrials = 2000
+
+// randomTime returns a time anywhere from year 1 to 9999, in a random location, with random nanoseconds.
+func randomTime(r *rand.Rand) time.Time {
+	const first, last = -62135596800, 253402300799
+	secs := first + r.Int63n(last-first)
+	switch r.Intn(4) {
+	case 0:
+		secs = r.Int63n(2000000000) //near the present
+	case 1:
+		secs = -r.Int63n(2000000000) //before 1970
+	}
+	t := time.Unix(secs, r.Int63n(int64(time.Second)))
+	return t.In(time.FixedZone("", 60*(r.Intn(24*60)-12*60)))
+}
+
+func sameInstant(t1, t2 time.Time) bool {
+	return t1.Unix() == t2.Unix() && t1.Nanosecond() == t2.Nanosecond()
+}
+
+func TestTimestampRoundTrip(test *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < timestampTrials; i++ {
+		ts := rdl.Timestamp{Time: randomTime(r)}
+		tt := &TimestampTest{Mytime: ts}
+
+		//generated code
+		tdata, err := Marshal(tt)
+		if err != nil {
+			test.Fatalf("Cannot marshal %v: %v", ts, err)
+		}
+		var tt2 TimestampTest
+		if err = Unmarshal(tdata, &tt2); err != nil || !sameInstant(tt2.Mytime.Time, ts.Time) {
+			test.Fatalf("Timestamp %v did not round trip: %v (%v)", ts, tt2.Mytime, err)
+		}
+
+		//reflection, and generically
+		tdata, _ = Marshal(ts)
+		var ts2 rdl.Timestamp
+		if err = Unmarshal(tdata, &ts2); err != nil || !sameInstant(ts2.Time, ts.Time) {
+			test.Fatalf("Timestamp %v did not round trip by reflection: %v (%v)", ts, ts2, err)
+		}
+		var generic interface{}
+		if err = Unmarshal(tdata, &generic); err != nil || !sameInstant(generic.(rdl.Timestamp).Time, ts.Time) {
+			test.Fatalf("Timestamp %v did not round trip generically: %v (%v)", ts, generic, err)
+		}
+		if err = NewDecoder(bytes.NewReader(tdata)).Skip(); err != nil {
+			test.Fatalf("Cannot skip timestamp %v: %v", ts, err)
+		}
+	}
+}
+
+func TestTimestampFloatCompatibility(test *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < timestampTrials; i++ {
+		//the double form has microsecond precision for present dates
+		ts := rdl.Timestamp{Time: time.Unix(r.Int63n(2000000000), r.Int63n(1000000)*1000).UTC()}
+		enc := NewEncoderWithOptions(nil, &EncoderOptions{FloatTimestamps: true})
+		enc.Encode(&TimestampTest{Mytime: ts})
+		tdata := enc.Bytes()
+		if tdata[0] != VersionTag {
+			test.Fatalf("Expected version 1 data for float timestamps")
+		}
+		var tt TimestampTest
+		if err := Unmarshal(tdata, &tt); err != nil || tt.Mytime.Micros() != ts.Micros() {
+			test.Fatalf("Float timestamp %v did not round trip: %v (%v)", ts, tt.Mytime, err)
+		}
+		if err := NewDecoder(bytes.NewReader(tdata)).Skip(); err != nil {
+			test.Fatalf("Cannot skip float timestamp %v: %v", ts, err)
+		}
+	}
+
+	//out of range nanoseconds are malformed
+	bad := []byte{VersionTag + 2, TimestampTag, 0, 0x80, 0x94, 0xeb, 0xdc, 0x03}
+	var ts rdl.Timestamp
+	if err := Unmarshal(bad, &ts); err == nil {
+		test.Errorf("Expected an error for out of range nanoseconds")
+	}
+}
+
+func TestTimestampStreaming(test *testing.T) {
+	//a stream that is to have exact timestamps after it is flushed sets its version
+	ts := rdl.Timestamp{Time: time.Unix(1431805821, 2345678).UTC()}
+	var out bytes.Buffer
+	enc := NewEncoderWithOptions(&out, &EncoderOptions{Version: CurrentVersion})
+	enc.Encode("first")
+	enc.Flush()
+	if err := enc.Encode(ts); err != nil {
+		test.Fatalf("Cannot encode a timestamp after the header was flushed: %v", err)
+	}
+	enc.Flush()
+	dec := NewDecoder(&out)
+	var s string
+	var ts2 rdl.Timestamp
+	if err := dec.Decode(&s); err != nil || s != "first" {
+		test.Errorf("Cannot decode the value before the flush: %q (%v)", s, err)
+	}
+	if err := dec.Decode(&ts2); err != nil || !sameInstant(ts2.Time, ts.Time) {
+		test.Errorf("Timestamp %v after the flush did not round trip: %v (%v)", ts, ts2, err)
+	}
+
+	//otherwise the header that was flushed is version 1, which has timestamps as doubles
+	out.Reset()
+	enc = NewEncoder(&out)
+	enc.Encode("first")
+	enc.Flush()
+	enc.Encode(ts)
+	enc.Flush()
+	header := out.Bytes()[0]
+	dec = NewDecoder(&out)
+	dec.Decode(&s)
+	if err := dec.Decode(&ts2); err != nil || header != VersionTag || ts2.Micros() != ts.Micros() {
+		test.Errorf("Expected a double timestamp in a version 1 stream: %v (%v)", ts2, err)
+	}
+
+	//streams for decoders before version 3 have timestamps as doubles
+	enc = NewEncoderWithOptions(nil, &EncoderOptions{Version: 2})
+	enc.Encode(ts)
+	if enc.Error() != nil || enc.Bytes()[0] != VersionTag+1 || len(enc.Bytes()) != 10 {
+		test.Errorf("Expected a version 2 stream with a double timestamp: % x (%v)", enc.Bytes(), enc.Error())
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2015 Yahoo Inc.
+// Licensed under the terms of the Apache version 2.0 license. See LICENSE file for terms.
+
+package tbin
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+type Counters struct {
+	Hits   uint64 `json:"hits"`
+	Hash   uint64 `json:"hash"`
+	Small  uint8  `json:"small"`
+	Medium uint16 `json:"medium"`
+	Large  uint32 `json:"large"`
+	Count  uint   `json:"count"`
+	Total  int    `json:"total"`
+}
+
+type SmallCounters struct {
+	Hits  uint8 `json:"hits"`
+	Hash  int64 `json:"hash"`
+	Small int8  `json:"small"`
+}
+
+func TestUnsignedRoundTrip(test *testing.T) {
+	c := &Counters{Hits: math.MaxUint64, Hash: 1<<63 + 5, Small: math.MaxUint8, Medium: math.MaxUint16, Large: math.MaxUint32, Count: 7, Total: -3}
+	tdata, err := Marshal(c)
+	if err != nil {
+		test.Fatalf("Cannot marshal unsigned fields: %v", err)
+	}
+	if tdata[0] != VersionTag+1 {
+		test.Errorf("Expected a version 2 header for unsigned data, got 0x%02x", tdata[0])
+	}
+	var c2 Counters
+	if err = Unmarshal(tdata, &c2); err != nil {
+		test.Fatalf("Cannot unmarshal unsigned fields: %v", err)
+	}
+	if c2 != *c {
+		test.Errorf("Unsigned fields were not restored: %+v", c2)
+	}
+
+	var generic interface{}
+	if err = Unmarshal(tdata, &generic); err != nil {
+		test.Fatalf("Cannot unmarshal unsigned fields generically: %v", err)
+	}
+	m := generic.(map[string]interface{})
+	if m["hits"] != uint64(math.MaxUint64) || m["small"] != uint8(math.MaxUint8) || m["medium"] != uint16(math.MaxUint16) || m["large"] != uint32(math.MaxUint32) {
+		test.Errorf("Unexpected generic unsigned values: %v", m)
+	}
+
+	//tagged values, and skipping them
+	values := []interface{}{uint8(200), uint16(60000), uint32(4000000000), uint64(math.MaxUint64), uint(9)}
+	tdata, _ = Marshal(values)
+	var values2 []interface{}
+	if err = Unmarshal(tdata, &values2); err != nil || len(values2) != len(values) || values2[3] != uint64(math.MaxUint64) || values2[4] != uint64(9) {
+		test.Errorf("Tagged unsigned values were not restored: %v (%v)", values2, err)
+	}
+	if err = NewDecoder(bytes.NewReader(tdata)).Skip(); err != nil {
+		test.Errorf("Cannot skip unsigned values: %v", err)
+	}
+
+	//a wide int is not truncated
+	tdata, _ = Marshal(int(1) << 40)
+	var n int64
+	if err = Unmarshal(tdata, &n); err != nil || n != 1<<40 {
+		test.Errorf("Expected an int to keep its value, got %d (%v)", n, err)
+	}
+}
+
+func TestUnsignedVersion(test *testing.T) {
+	//data without unsigned values stays version 1, for older decoders
+	tdata, _ := Marshal(polyline())
+	if tdata[0] != VersionTag {
+		test.Errorf("Expected a version 1 header, got 0x%02x", tdata[0])
+	}
+	//which can be required
+	enc := NewEncoderWithOptions(nil, &EncoderOptions{Version: 1})
+	enc.Encode(polyline())
+	if enc.Error() != nil || enc.Bytes()[0] != VersionTag {
+		test.Errorf("Expected a version 1 header, got 0x%02x (%v)", enc.Bytes()[0], enc.Error())
+	}
+	if err := enc.Encode(uint32(1)); err == nil || !strings.Contains(err.Error(), "version 1") {
+		test.Errorf("Expected an error encoding an unsigned value in version 1 data, got %v", err)
+	}
+	//which older decoders don't know
+	if err := Unmarshal([]byte{VersionTag, Uint8Tag, 1}, new(interface{})); err == nil {
+		test.Errorf("Expected an error for an unsigned tag in version 1 data")
+	}
+	if err := Unmarshal([]byte{VersionTag + CurrentVersion, NullTag}, new(interface{})); err == nil {
+		test.Errorf("Expected an error for an unsupported version")
+	}
+	enc = NewEncoderWithOptions(nil, &EncoderOptions{Version: CurrentVersion + 1})
+	if enc.Error() == nil {
+		test.Errorf("Expected an error for an unsupported version to encode")
+	}
+
+	//the header cannot be raised once it is flushed
+	var out bytes.Buffer
+	enc = NewEncoder(&out)
+	enc.Encode(int32(1))
+	enc.Flush()
+	if err := enc.Encode(uint32(7)); err == nil || !strings.Contains(err.Error(), "version 1") {
+		test.Errorf("Expected an error encoding an unsigned value after a version 1 header was flushed, got %v", err)
+	}
+
+	//so a stream that is to have unsigned values after it is flushed sets its version
+	out.Reset()
+	enc = NewEncoderWithOptions(&out, &EncoderOptions{Version: 2})
+	enc.Encode(int32(1))
+	enc.Flush()
+	if err := enc.Encode(uint32(7)); err != nil {
+		test.Fatalf("Cannot encode an unsigned value after the header was flushed: %v", err)
+	}
+	enc.Flush()
+	dec := NewDecoder(&out)
+	var n int32
+	var u uint32
+	if err := dec.Decode(&n); err != nil || n != 1 {
+		test.Errorf("Cannot decode the value before the flush: %d (%v)", n, err)
+	}
+	if err := dec.Decode(&u); err != nil || u != 7 {
+		test.Errorf("Cannot decode the unsigned value after the flush: %d (%v)", u, err)
+	}
+}
+
+func TestIntegerOverflow(test *testing.T) {
+	c := &Counters{Hits: 300, Hash: 1 << 63}
+	tdata, _ := Marshal(c)
+	var small SmallCounters
+	err := Unmarshal(tdata, &small)
+	if err == nil || !strings.Contains(err.Error(), "overflows uint8") {
+		test.Errorf("Expected an overflow error, got %v", err)
+	}
+
+	c = &Counters{Hits: 3, Hash: 1 << 63}
+	tdata, _ = Marshal(c)
+	if err = Unmarshal(tdata, &small); err == nil || !strings.Contains(err.Error(), "overflows int64") {
+		test.Errorf("Expected an overflow error, got %v", err)
+	}
+
+	tdata, _ = Marshal(int64(-1))
+	var u uint32
+	if err = Unmarshal(tdata, &u); err == nil {
+		test.Errorf("Expected an error decoding a negative value into an unsigned target")
+	}
+	tdata, _ = Marshal(int32(1000))
+	var i8 int8
+	if err = Unmarshal(tdata, &i8); err == nil {
+		test.Errorf("Expected an error decoding a wide value into a narrow target")
+	}
+	var i16 int16
+	if err = Unmarshal(tdata, &i16); err != nil || i16 != 1000 {
+		test.Errorf("Expected a value in range to be decoded, got %d (%v)", i16, err)
+	}
+
+	//a malformed value out of range for its type
+	if err = Unmarshal([]byte{VersionTag + 1, Uint8Tag, 0xac, 0x02}, new(interface{})); err == nil {
+		test.Errorf("Expected an error for a Uint8 value out of range")
+	}
+
+	//an int field is an Int32, which a wider value cannot be encoded as
+	if _, err = Marshal(&Counters{Total: 1 << 40}); err == nil || !strings.Contains(err.Error(), "overflows Int32") {
+		test.Errorf("Expected an error encoding a wide int field, got %v", err)
+	}
+}